@@ -0,0 +1,14 @@
+// Package api holds the API descriptions shipped with the server.
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document of the HTTP API
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Docs is a Swagger UI page rendering OpenAPI
+//
+//go:embed docs.html
+var Docs []byte
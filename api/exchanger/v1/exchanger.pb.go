@@ -0,0 +1,1081 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: exchanger/v1/exchanger.proto
+
+package exchangerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Currency struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Code  string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Sign  string                 `protobuf:"bytes,4,opt,name=sign,proto3" json:"sign,omitempty"`
+	// version is bumped by every change of the currency
+	Version       int64 `protobuf:"varint,5,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Currency) Reset() {
+	*x = Currency{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Currency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Currency) ProtoMessage() {}
+
+func (x *Currency) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Currency.ProtoReflect.Descriptor instead.
+func (*Currency) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Currency) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Currency) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Currency) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Currency) GetSign() string {
+	if x != nil {
+		return x.Sign
+	}
+	return ""
+}
+
+func (x *Currency) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type ExchangeRate struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	BaseCurrency   *Currency              `protobuf:"bytes,2,opt,name=base_currency,json=baseCurrency,proto3" json:"base_currency,omitempty"`
+	TargetCurrency *Currency              `protobuf:"bytes,3,opt,name=target_currency,json=targetCurrency,proto3" json:"target_currency,omitempty"`
+	Rate           float64                `protobuf:"fixed64,4,opt,name=rate,proto3" json:"rate,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// version is bumped by every change of the rate, send it as if-match metadata to UpdateExchangeRate
+	Version       int64 `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExchangeRate) Reset() {
+	*x = ExchangeRate{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExchangeRate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExchangeRate) ProtoMessage() {}
+
+func (x *ExchangeRate) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExchangeRate.ProtoReflect.Descriptor instead.
+func (*ExchangeRate) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExchangeRate) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ExchangeRate) GetBaseCurrency() *Currency {
+	if x != nil {
+		return x.BaseCurrency
+	}
+	return nil
+}
+
+func (x *ExchangeRate) GetTargetCurrency() *Currency {
+	if x != nil {
+		return x.TargetCurrency
+	}
+	return nil
+}
+
+func (x *ExchangeRate) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *ExchangeRate) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *ExchangeRate) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type CurrencyConversion struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrency    *Currency              `protobuf:"bytes,1,opt,name=base_currency,json=baseCurrency,proto3" json:"base_currency,omitempty"`
+	TargetCurrency  *Currency              `protobuf:"bytes,2,opt,name=target_currency,json=targetCurrency,proto3" json:"target_currency,omitempty"`
+	Rate            float64                `protobuf:"fixed64,3,opt,name=rate,proto3" json:"rate,omitempty"`
+	Amount          float64                `protobuf:"fixed64,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	ConvertedAmount float64                `protobuf:"fixed64,5,opt,name=converted_amount,json=convertedAmount,proto3" json:"converted_amount,omitempty"`
+	// stale is set when the conversion used a rate older than the configured max age
+	Stale bool `protobuf:"varint,6,opt,name=stale,proto3" json:"stale,omitempty"`
+	// pivot is the currency a cross rate was derived through, empty for the rate of the pair or its inverse
+	Pivot         string `protobuf:"bytes,7,opt,name=pivot,proto3" json:"pivot,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CurrencyConversion) Reset() {
+	*x = CurrencyConversion{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CurrencyConversion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CurrencyConversion) ProtoMessage() {}
+
+func (x *CurrencyConversion) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CurrencyConversion.ProtoReflect.Descriptor instead.
+func (*CurrencyConversion) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CurrencyConversion) GetBaseCurrency() *Currency {
+	if x != nil {
+		return x.BaseCurrency
+	}
+	return nil
+}
+
+func (x *CurrencyConversion) GetTargetCurrency() *Currency {
+	if x != nil {
+		return x.TargetCurrency
+	}
+	return nil
+}
+
+func (x *CurrencyConversion) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *CurrencyConversion) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *CurrencyConversion) GetConvertedAmount() float64 {
+	if x != nil {
+		return x.ConvertedAmount
+	}
+	return 0
+}
+
+func (x *CurrencyConversion) GetStale() bool {
+	if x != nil {
+		return x.Stale
+	}
+	return false
+}
+
+func (x *CurrencyConversion) GetPivot() string {
+	if x != nil {
+		return x.Pivot
+	}
+	return ""
+}
+
+type RateEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// previous is not set for a newly created pair
+	Previous *ExchangeRate `protobuf:"bytes,2,opt,name=previous,proto3" json:"previous,omitempty"`
+	// current is not set for a deleted pair, previous is its last rate
+	Current *ExchangeRate `protobuf:"bytes,3,opt,name=current,proto3" json:"current,omitempty"`
+	// missed_events means events after the requested last_event_id were lost and rates should be refetched
+	MissedEvents  bool `protobuf:"varint,4,opt,name=missed_events,json=missedEvents,proto3" json:"missed_events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateEvent) Reset() {
+	*x = RateEvent{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateEvent) ProtoMessage() {}
+
+func (x *RateEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateEvent.ProtoReflect.Descriptor instead.
+func (*RateEvent) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RateEvent) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *RateEvent) GetPrevious() *ExchangeRate {
+	if x != nil {
+		return x.Previous
+	}
+	return nil
+}
+
+func (x *RateEvent) GetCurrent() *ExchangeRate {
+	if x != nil {
+		return x.Current
+	}
+	return nil
+}
+
+func (x *RateEvent) GetMissedEvents() bool {
+	if x != nil {
+		return x.MissedEvents
+	}
+	return false
+}
+
+type ListCurrenciesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCurrenciesRequest) Reset() {
+	*x = ListCurrenciesRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCurrenciesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCurrenciesRequest) ProtoMessage() {}
+
+func (x *ListCurrenciesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCurrenciesRequest.ProtoReflect.Descriptor instead.
+func (*ListCurrenciesRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{4}
+}
+
+type ListCurrenciesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Currencies    []*Currency            `protobuf:"bytes,1,rep,name=currencies,proto3" json:"currencies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCurrenciesResponse) Reset() {
+	*x = ListCurrenciesResponse{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCurrenciesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCurrenciesResponse) ProtoMessage() {}
+
+func (x *ListCurrenciesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCurrenciesResponse.ProtoReflect.Descriptor instead.
+func (*ListCurrenciesResponse) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListCurrenciesResponse) GetCurrencies() []*Currency {
+	if x != nil {
+		return x.Currencies
+	}
+	return nil
+}
+
+type GetCurrencyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrencyRequest) Reset() {
+	*x = GetCurrencyRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrencyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrencyRequest) ProtoMessage() {}
+
+func (x *GetCurrencyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrencyRequest.ProtoReflect.Descriptor instead.
+func (*GetCurrencyRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetCurrencyRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type CreateCurrencyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Sign          string                 `protobuf:"bytes,3,opt,name=sign,proto3" json:"sign,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCurrencyRequest) Reset() {
+	*x = CreateCurrencyRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCurrencyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCurrencyRequest) ProtoMessage() {}
+
+func (x *CreateCurrencyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCurrencyRequest.ProtoReflect.Descriptor instead.
+func (*CreateCurrencyRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateCurrencyRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateCurrencyRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *CreateCurrencyRequest) GetSign() string {
+	if x != nil {
+		return x.Sign
+	}
+	return ""
+}
+
+type ListExchangeRatesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExchangeRatesRequest) Reset() {
+	*x = ListExchangeRatesRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExchangeRatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExchangeRatesRequest) ProtoMessage() {}
+
+func (x *ListExchangeRatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExchangeRatesRequest.ProtoReflect.Descriptor instead.
+func (*ListExchangeRatesRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{8}
+}
+
+type ListExchangeRatesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExchangeRates []*ExchangeRate        `protobuf:"bytes,1,rep,name=exchange_rates,json=exchangeRates,proto3" json:"exchange_rates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExchangeRatesResponse) Reset() {
+	*x = ListExchangeRatesResponse{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExchangeRatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExchangeRatesResponse) ProtoMessage() {}
+
+func (x *ListExchangeRatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExchangeRatesResponse.ProtoReflect.Descriptor instead.
+func (*ListExchangeRatesResponse) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListExchangeRatesResponse) GetExchangeRates() []*ExchangeRate {
+	if x != nil {
+		return x.ExchangeRates
+	}
+	return nil
+}
+
+type GetExchangeRateRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrencyCode   string                 `protobuf:"bytes,1,opt,name=base_currency_code,json=baseCurrencyCode,proto3" json:"base_currency_code,omitempty"`
+	TargetCurrencyCode string                 `protobuf:"bytes,2,opt,name=target_currency_code,json=targetCurrencyCode,proto3" json:"target_currency_code,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *GetExchangeRateRequest) Reset() {
+	*x = GetExchangeRateRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetExchangeRateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetExchangeRateRequest) ProtoMessage() {}
+
+func (x *GetExchangeRateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetExchangeRateRequest.ProtoReflect.Descriptor instead.
+func (*GetExchangeRateRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetExchangeRateRequest) GetBaseCurrencyCode() string {
+	if x != nil {
+		return x.BaseCurrencyCode
+	}
+	return ""
+}
+
+func (x *GetExchangeRateRequest) GetTargetCurrencyCode() string {
+	if x != nil {
+		return x.TargetCurrencyCode
+	}
+	return ""
+}
+
+type CreateExchangeRateRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrencyCode   string                 `protobuf:"bytes,1,opt,name=base_currency_code,json=baseCurrencyCode,proto3" json:"base_currency_code,omitempty"`
+	TargetCurrencyCode string                 `protobuf:"bytes,2,opt,name=target_currency_code,json=targetCurrencyCode,proto3" json:"target_currency_code,omitempty"`
+	Rate               float64                `protobuf:"fixed64,3,opt,name=rate,proto3" json:"rate,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *CreateExchangeRateRequest) Reset() {
+	*x = CreateExchangeRateRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateExchangeRateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateExchangeRateRequest) ProtoMessage() {}
+
+func (x *CreateExchangeRateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateExchangeRateRequest.ProtoReflect.Descriptor instead.
+func (*CreateExchangeRateRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CreateExchangeRateRequest) GetBaseCurrencyCode() string {
+	if x != nil {
+		return x.BaseCurrencyCode
+	}
+	return ""
+}
+
+func (x *CreateExchangeRateRequest) GetTargetCurrencyCode() string {
+	if x != nil {
+		return x.TargetCurrencyCode
+	}
+	return ""
+}
+
+func (x *CreateExchangeRateRequest) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+type UpdateExchangeRateRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	BaseCurrencyCode   string                 `protobuf:"bytes,1,opt,name=base_currency_code,json=baseCurrencyCode,proto3" json:"base_currency_code,omitempty"`
+	TargetCurrencyCode string                 `protobuf:"bytes,2,opt,name=target_currency_code,json=targetCurrencyCode,proto3" json:"target_currency_code,omitempty"`
+	Rate               float64                `protobuf:"fixed64,3,opt,name=rate,proto3" json:"rate,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *UpdateExchangeRateRequest) Reset() {
+	*x = UpdateExchangeRateRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateExchangeRateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateExchangeRateRequest) ProtoMessage() {}
+
+func (x *UpdateExchangeRateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateExchangeRateRequest.ProtoReflect.Descriptor instead.
+func (*UpdateExchangeRateRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateExchangeRateRequest) GetBaseCurrencyCode() string {
+	if x != nil {
+		return x.BaseCurrencyCode
+	}
+	return ""
+}
+
+func (x *UpdateExchangeRateRequest) GetTargetCurrencyCode() string {
+	if x != nil {
+		return x.TargetCurrencyCode
+	}
+	return ""
+}
+
+func (x *UpdateExchangeRateRequest) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+type WatchExchangeRatesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// pairs like "USDRUB", empty means every pair
+	Pairs []string `protobuf:"bytes,1,rep,name=pairs,proto3" json:"pairs,omitempty"`
+	// currencies on either side of a pair, combined with pairs by OR
+	Currencies []string `protobuf:"bytes,2,rep,name=currencies,proto3" json:"currencies,omitempty"`
+	// resume after this event, the same ids are used by GET /exchangeRates/stream
+	LastEventId   *uint64 `protobuf:"varint,3,opt,name=last_event_id,json=lastEventId,proto3,oneof" json:"last_event_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchExchangeRatesRequest) Reset() {
+	*x = WatchExchangeRatesRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchExchangeRatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchExchangeRatesRequest) ProtoMessage() {}
+
+func (x *WatchExchangeRatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchExchangeRatesRequest.ProtoReflect.Descriptor instead.
+func (*WatchExchangeRatesRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WatchExchangeRatesRequest) GetPairs() []string {
+	if x != nil {
+		return x.Pairs
+	}
+	return nil
+}
+
+func (x *WatchExchangeRatesRequest) GetCurrencies() []string {
+	if x != nil {
+		return x.Currencies
+	}
+	return nil
+}
+
+func (x *WatchExchangeRatesRequest) GetLastEventId() uint64 {
+	if x != nil && x.LastEventId != nil {
+		return *x.LastEventId
+	}
+	return 0
+}
+
+type ConvertCurrencyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            string                 `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertCurrencyRequest) Reset() {
+	*x = ConvertCurrencyRequest{}
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertCurrencyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertCurrencyRequest) ProtoMessage() {}
+
+func (x *ConvertCurrencyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_exchanger_v1_exchanger_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertCurrencyRequest.ProtoReflect.Descriptor instead.
+func (*ConvertCurrencyRequest) Descriptor() ([]byte, []int) {
+	return file_exchanger_v1_exchanger_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ConvertCurrencyRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ConvertCurrencyRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ConvertCurrencyRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+var File_exchanger_v1_exchanger_proto protoreflect.FileDescriptor
+
+const file_exchanger_v1_exchanger_proto_rawDesc = "" +
+	"\n" +
+	"\x1cexchanger/v1/exchanger.proto\x12\fexchanger.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"p\n" +
+	"\bCurrency\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12\x12\n" +
+	"\x04sign\x18\x04 \x01(\tR\x04sign\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\x03R\aversion\"\x85\x02\n" +
+	"\fExchangeRate\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12;\n" +
+	"\rbase_currency\x18\x02 \x01(\v2\x16.exchanger.v1.CurrencyR\fbaseCurrency\x12?\n" +
+	"\x0ftarget_currency\x18\x03 \x01(\v2\x16.exchanger.v1.CurrencyR\x0etargetCurrency\x12\x12\n" +
+	"\x04rate\x18\x04 \x01(\x01R\x04rate\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\x03R\aversion\"\x95\x02\n" +
+	"\x12CurrencyConversion\x12;\n" +
+	"\rbase_currency\x18\x01 \x01(\v2\x16.exchanger.v1.CurrencyR\fbaseCurrency\x12?\n" +
+	"\x0ftarget_currency\x18\x02 \x01(\v2\x16.exchanger.v1.CurrencyR\x0etargetCurrency\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\x01R\x04rate\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x01R\x06amount\x12)\n" +
+	"\x10converted_amount\x18\x05 \x01(\x01R\x0fconvertedAmount\x12\x14\n" +
+	"\x05stale\x18\x06 \x01(\bR\x05stale\x12\x14\n" +
+	"\x05pivot\x18\a \x01(\tR\x05pivot\"\xae\x01\n" +
+	"\tRateEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x126\n" +
+	"\bprevious\x18\x02 \x01(\v2\x1a.exchanger.v1.ExchangeRateR\bprevious\x124\n" +
+	"\acurrent\x18\x03 \x01(\v2\x1a.exchanger.v1.ExchangeRateR\acurrent\x12#\n" +
+	"\rmissed_events\x18\x04 \x01(\bR\fmissedEvents\"\x17\n" +
+	"\x15ListCurrenciesRequest\"P\n" +
+	"\x16ListCurrenciesResponse\x126\n" +
+	"\n" +
+	"currencies\x18\x01 \x03(\v2\x16.exchanger.v1.CurrencyR\n" +
+	"currencies\"(\n" +
+	"\x12GetCurrencyRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"S\n" +
+	"\x15CreateCurrencyRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x12\n" +
+	"\x04sign\x18\x03 \x01(\tR\x04sign\"\x1a\n" +
+	"\x18ListExchangeRatesRequest\"^\n" +
+	"\x19ListExchangeRatesResponse\x12A\n" +
+	"\x0eexchange_rates\x18\x01 \x03(\v2\x1a.exchanger.v1.ExchangeRateR\rexchangeRates\"x\n" +
+	"\x16GetExchangeRateRequest\x12,\n" +
+	"\x12base_currency_code\x18\x01 \x01(\tR\x10baseCurrencyCode\x120\n" +
+	"\x14target_currency_code\x18\x02 \x01(\tR\x12targetCurrencyCode\"\x8f\x01\n" +
+	"\x19CreateExchangeRateRequest\x12,\n" +
+	"\x12base_currency_code\x18\x01 \x01(\tR\x10baseCurrencyCode\x120\n" +
+	"\x14target_currency_code\x18\x02 \x01(\tR\x12targetCurrencyCode\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\x01R\x04rate\"\x8f\x01\n" +
+	"\x19UpdateExchangeRateRequest\x12,\n" +
+	"\x12base_currency_code\x18\x01 \x01(\tR\x10baseCurrencyCode\x120\n" +
+	"\x14target_currency_code\x18\x02 \x01(\tR\x12targetCurrencyCode\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\x01R\x04rate\"\x8c\x01\n" +
+	"\x19WatchExchangeRatesRequest\x12\x14\n" +
+	"\x05pairs\x18\x01 \x03(\tR\x05pairs\x12\x1e\n" +
+	"\n" +
+	"currencies\x18\x02 \x03(\tR\n" +
+	"currencies\x12'\n" +
+	"\rlast_event_id\x18\x03 \x01(\x04H\x00R\vlastEventId\x88\x01\x01B\x10\n" +
+	"\x0e_last_event_id\"T\n" +
+	"\x16ConvertCurrencyRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount2\x86\x02\n" +
+	"\x0fCurrencyService\x12[\n" +
+	"\x0eListCurrencies\x12#.exchanger.v1.ListCurrenciesRequest\x1a$.exchanger.v1.ListCurrenciesResponse\x12G\n" +
+	"\vGetCurrency\x12 .exchanger.v1.GetCurrencyRequest\x1a\x16.exchanger.v1.Currency\x12M\n" +
+	"\x0eCreateCurrency\x12#.exchanger.v1.CreateCurrencyRequest\x1a\x16.exchanger.v1.Currency2\xe0\x03\n" +
+	"\x13ExchangeRateService\x12d\n" +
+	"\x11ListExchangeRates\x12&.exchanger.v1.ListExchangeRatesRequest\x1a'.exchanger.v1.ListExchangeRatesResponse\x12S\n" +
+	"\x0fGetExchangeRate\x12$.exchanger.v1.GetExchangeRateRequest\x1a\x1a.exchanger.v1.ExchangeRate\x12Y\n" +
+	"\x12CreateExchangeRate\x12'.exchanger.v1.CreateExchangeRateRequest\x1a\x1a.exchanger.v1.ExchangeRate\x12Y\n" +
+	"\x12UpdateExchangeRate\x12'.exchanger.v1.UpdateExchangeRateRequest\x1a\x1a.exchanger.v1.ExchangeRate\x12X\n" +
+	"\x12WatchExchangeRates\x12'.exchanger.v1.WatchExchangeRatesRequest\x1a\x17.exchanger.v1.RateEvent0\x012n\n" +
+	"\x11ConversionService\x12Y\n" +
+	"\x0fConvertCurrency\x12$.exchanger.v1.ConvertCurrencyRequest\x1a .exchanger.v1.CurrencyConversionB(Z&exchanger/api/exchanger/v1;exchangerv1b\x06proto3"
+
+var (
+	file_exchanger_v1_exchanger_proto_rawDescOnce sync.Once
+	file_exchanger_v1_exchanger_proto_rawDescData []byte
+)
+
+func file_exchanger_v1_exchanger_proto_rawDescGZIP() []byte {
+	file_exchanger_v1_exchanger_proto_rawDescOnce.Do(func() {
+		file_exchanger_v1_exchanger_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_exchanger_v1_exchanger_proto_rawDesc), len(file_exchanger_v1_exchanger_proto_rawDesc)))
+	})
+	return file_exchanger_v1_exchanger_proto_rawDescData
+}
+
+var file_exchanger_v1_exchanger_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_exchanger_v1_exchanger_proto_goTypes = []any{
+	(*Currency)(nil),                  // 0: exchanger.v1.Currency
+	(*ExchangeRate)(nil),              // 1: exchanger.v1.ExchangeRate
+	(*CurrencyConversion)(nil),        // 2: exchanger.v1.CurrencyConversion
+	(*RateEvent)(nil),                 // 3: exchanger.v1.RateEvent
+	(*ListCurrenciesRequest)(nil),     // 4: exchanger.v1.ListCurrenciesRequest
+	(*ListCurrenciesResponse)(nil),    // 5: exchanger.v1.ListCurrenciesResponse
+	(*GetCurrencyRequest)(nil),        // 6: exchanger.v1.GetCurrencyRequest
+	(*CreateCurrencyRequest)(nil),     // 7: exchanger.v1.CreateCurrencyRequest
+	(*ListExchangeRatesRequest)(nil),  // 8: exchanger.v1.ListExchangeRatesRequest
+	(*ListExchangeRatesResponse)(nil), // 9: exchanger.v1.ListExchangeRatesResponse
+	(*GetExchangeRateRequest)(nil),    // 10: exchanger.v1.GetExchangeRateRequest
+	(*CreateExchangeRateRequest)(nil), // 11: exchanger.v1.CreateExchangeRateRequest
+	(*UpdateExchangeRateRequest)(nil), // 12: exchanger.v1.UpdateExchangeRateRequest
+	(*WatchExchangeRatesRequest)(nil), // 13: exchanger.v1.WatchExchangeRatesRequest
+	(*ConvertCurrencyRequest)(nil),    // 14: exchanger.v1.ConvertCurrencyRequest
+	(*timestamppb.Timestamp)(nil),     // 15: google.protobuf.Timestamp
+}
+var file_exchanger_v1_exchanger_proto_depIdxs = []int32{
+	0,  // 0: exchanger.v1.ExchangeRate.base_currency:type_name -> exchanger.v1.Currency
+	0,  // 1: exchanger.v1.ExchangeRate.target_currency:type_name -> exchanger.v1.Currency
+	15, // 2: exchanger.v1.ExchangeRate.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 3: exchanger.v1.CurrencyConversion.base_currency:type_name -> exchanger.v1.Currency
+	0,  // 4: exchanger.v1.CurrencyConversion.target_currency:type_name -> exchanger.v1.Currency
+	1,  // 5: exchanger.v1.RateEvent.previous:type_name -> exchanger.v1.ExchangeRate
+	1,  // 6: exchanger.v1.RateEvent.current:type_name -> exchanger.v1.ExchangeRate
+	0,  // 7: exchanger.v1.ListCurrenciesResponse.currencies:type_name -> exchanger.v1.Currency
+	1,  // 8: exchanger.v1.ListExchangeRatesResponse.exchange_rates:type_name -> exchanger.v1.ExchangeRate
+	4,  // 9: exchanger.v1.CurrencyService.ListCurrencies:input_type -> exchanger.v1.ListCurrenciesRequest
+	6,  // 10: exchanger.v1.CurrencyService.GetCurrency:input_type -> exchanger.v1.GetCurrencyRequest
+	7,  // 11: exchanger.v1.CurrencyService.CreateCurrency:input_type -> exchanger.v1.CreateCurrencyRequest
+	8,  // 12: exchanger.v1.ExchangeRateService.ListExchangeRates:input_type -> exchanger.v1.ListExchangeRatesRequest
+	10, // 13: exchanger.v1.ExchangeRateService.GetExchangeRate:input_type -> exchanger.v1.GetExchangeRateRequest
+	11, // 14: exchanger.v1.ExchangeRateService.CreateExchangeRate:input_type -> exchanger.v1.CreateExchangeRateRequest
+	12, // 15: exchanger.v1.ExchangeRateService.UpdateExchangeRate:input_type -> exchanger.v1.UpdateExchangeRateRequest
+	13, // 16: exchanger.v1.ExchangeRateService.WatchExchangeRates:input_type -> exchanger.v1.WatchExchangeRatesRequest
+	14, // 17: exchanger.v1.ConversionService.ConvertCurrency:input_type -> exchanger.v1.ConvertCurrencyRequest
+	5,  // 18: exchanger.v1.CurrencyService.ListCurrencies:output_type -> exchanger.v1.ListCurrenciesResponse
+	0,  // 19: exchanger.v1.CurrencyService.GetCurrency:output_type -> exchanger.v1.Currency
+	0,  // 20: exchanger.v1.CurrencyService.CreateCurrency:output_type -> exchanger.v1.Currency
+	9,  // 21: exchanger.v1.ExchangeRateService.ListExchangeRates:output_type -> exchanger.v1.ListExchangeRatesResponse
+	1,  // 22: exchanger.v1.ExchangeRateService.GetExchangeRate:output_type -> exchanger.v1.ExchangeRate
+	1,  // 23: exchanger.v1.ExchangeRateService.CreateExchangeRate:output_type -> exchanger.v1.ExchangeRate
+	1,  // 24: exchanger.v1.ExchangeRateService.UpdateExchangeRate:output_type -> exchanger.v1.ExchangeRate
+	3,  // 25: exchanger.v1.ExchangeRateService.WatchExchangeRates:output_type -> exchanger.v1.RateEvent
+	2,  // 26: exchanger.v1.ConversionService.ConvertCurrency:output_type -> exchanger.v1.CurrencyConversion
+	18, // [18:27] is the sub-list for method output_type
+	9,  // [9:18] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_exchanger_v1_exchanger_proto_init() }
+func file_exchanger_v1_exchanger_proto_init() {
+	if File_exchanger_v1_exchanger_proto != nil {
+		return
+	}
+	file_exchanger_v1_exchanger_proto_msgTypes[13].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_exchanger_v1_exchanger_proto_rawDesc), len(file_exchanger_v1_exchanger_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_exchanger_v1_exchanger_proto_goTypes,
+		DependencyIndexes: file_exchanger_v1_exchanger_proto_depIdxs,
+		MessageInfos:      file_exchanger_v1_exchanger_proto_msgTypes,
+	}.Build()
+	File_exchanger_v1_exchanger_proto = out.File
+	file_exchanger_v1_exchanger_proto_goTypes = nil
+	file_exchanger_v1_exchanger_proto_depIdxs = nil
+}
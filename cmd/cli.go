@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/config"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+type catalogueService interface {
+	ImportCatalogue(ctx context.Context, r io.Reader, format service.CatalogueFormat, dryRun bool) (models.ImportReport, error)
+	ExportCatalogue(ctx context.Context, w io.Writer, format service.CatalogueFormat) error
+}
+
+// runCommand runs the import or export subcommand against storage.db in the working directory
+func runCommand(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "import":
+		return importCommand(ctx, args)
+	case "export":
+		return exportCommand(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q, expected import or export", name)
+	}
+}
+
+func importCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing anything")
+	format := flags.String("format", "", "csv or json, taken from the file extension by default")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: exchanger import [-dry-run] [-format csv|json] FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("import takes one file, - for stdin")
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	catalogue, closeRepo, err := newCatalogueService(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	// the import is audited as made by the system user that runs it
+	actor := models.Actor{Name: "cli"}
+	if u, err := user.Current(); err == nil {
+		actor.Name = "cli:" + u.Username
+	}
+	ctx = models.ContextWithActor(ctx, actor)
+
+	report, err := catalogue.ImportCatalogue(ctx, in, service.CatalogueFormat(*format), *dryRun)
+	if errors.Is(err, service.ErrImportRejected) {
+		for _, e := range report.Errors {
+			fmt.Fprintf(os.Stderr, "%s.%s: %s (%s)\n", e.Ref, e.Field, e.Message, e.Code)
+		}
+		return fmt.Errorf("%d errors, nothing was imported", len(report.Errors))
+	} else if err != nil {
+		return err
+	}
+
+	for _, row := range report.Rows {
+		fmt.Printf("%s\t%s %s\t%s\n", row.Ref, row.Kind, row.Key, row.Action)
+	}
+	summary := "imported"
+	if report.DryRun {
+		summary = "dry run, would import"
+	}
+	fmt.Printf("%s: %d created, %d updated, %d unchanged\n", summary, report.Created, report.Updated, report.Unchanged)
+
+	return nil
+}
+
+func exportCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", string(service.CatalogueCSV), "csv or json")
+	output := flags.String("o", "", "file to write, stdout by default")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: exchanger export [-format csv|json] [-o FILE]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if f := service.CatalogueFormat(*format); f != service.CatalogueCSV && f != service.CatalogueJSON {
+		return fmt.Errorf("unsupported format %q, expected csv or json", *format)
+	}
+
+	catalogue, closeRepo, err := newCatalogueService(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return catalogue.ExportCatalogue(ctx, out, service.CatalogueFormat(*format))
+}
+
+// newCatalogueService queues the alerts of imported rates, a running server delivers them, and
+// derives the rates of the baskets from the imported ones
+func newCatalogueService(ctx context.Context) (catalogueService, func() error, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repository, err := repository.New(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alertService := service.NewAlertService(repository, repository, repository, repository, cfg.Alert)
+	basketService := service.NewBasketService(repository, cfg.StaleRatePolicy, cfg.PivotCurrencies, alertService)
+	pegService := service.NewPegService(repository, cfg.StaleRatePolicy, cfg.PivotCurrencies, alertService, basketService)
+	return service.NewCatalogueService(repository, cfg.Approval, cfg.RateGuard, cfg.RequireIfMatch, alertService, pegService, basketService), repository.Close, nil
+}
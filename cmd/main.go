@@ -2,20 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
+	"exchanger/internal/config"
 	"exchanger/internal/repository"
 	"exchanger/internal/server"
 	"exchanger/internal/server/handlers"
+	"exchanger/internal/server/rpc"
 	"exchanger/internal/service"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
 )
 
-const addr = ":8080"
+// shutdownTimeout is how long requests in flight may finish after a stop signal, streams that
+// are still open then are cut
+const shutdownTimeout = 10 * time.Second
 
 func main() {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	repository, err := repository.New(ctx)
 	if err != nil {
@@ -23,27 +46,78 @@ func main() {
 	}
 	defer repository.Close()
 
-	currencyService := service.NewCurrencyService(repository)
-	exchangeService := service.NewExchangeRateService(repository)
-	convertService := service.NewConvertService(repository, repository)
+	alertService := service.NewAlertService(repository, repository, repository, repository, cfg.Alert)
+	rateFeed := service.NewRateFeed(cfg.RateEventLogSize)
+	basketService := service.NewBasketService(repository, cfg.StaleRatePolicy, cfg.PivotCurrencies, alertService, rateFeed)
+	pegService := service.NewPegService(repository, cfg.StaleRatePolicy, cfg.PivotCurrencies, alertService, rateFeed, basketService)
+	currencyService := service.NewCurrencyService(repository, pegService, basketService, alertService, rateFeed)
+	// pegs are derived first, baskets may hold pegged currencies
+	exchangeService := service.NewExchangeRateService(repository, cfg.Approval, cfg.RateGuard, cfg.RequireIfMatch, alertService, rateFeed, pegService, basketService)
+	convertService := service.NewConvertService(repository, repository, cfg.StaleRatePolicy, cfg.PivotCurrencies)
+	catalogueService := service.NewCatalogueService(repository, cfg.Approval, cfg.RateGuard, cfg.RequireIfMatch, alertService, rateFeed, pegService, basketService)
+	auditService := service.NewAuditService(repository)
+
+	// changes that became effective while the server was down are set before it serves requests
+	if err := exchangeService.ApplyDueScheduledRates(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	go alertService.Run(ctx)
+	go exchangeService.RunScheduler(ctx)
 
-	handlers := handlers.New(currencyService, exchangeService, convertService)
+	handlers := handlers.New(currencyService, exchangeService, convertService, alertService, rateFeed, catalogueService, auditService, pegService)
 
 	routes := server.Routes(handlers)
 
 	httpServer := &http.Server{
-		Addr:    addr,
+		Addr:    cfg.Addr,
 		Handler: routes,
 	}
 
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal(err)
 		}
 	}()
-	log.Println("server running at addres: ", addr)
+	log.Println("server running at addres: ", cfg.Addr)
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(rpc.WithActor))
+	rpc.New(currencyService, exchangeService, convertService, rateFeed).Register(grpcServer)
+
+	listener, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	log.Println("grpc server running at addres: ", cfg.GRPCAddr)
 
 	sigint := make(chan os.Signal, 1)
-	signal.Notify(sigint)
+	// without an explicit list every signal, including the runtime's SIGURG, would stop the server
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 	<-sigint
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http server shutdown: %v", err)
+		httpServer.Close()
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
 }
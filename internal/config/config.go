@@ -0,0 +1,312 @@
+package config
+
+import (
+	"exchanger/internal/service"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is read from the environment, every variable is optional
+type Config struct {
+	Addr            string
+	GRPCAddr        string
+	StaleRatePolicy service.StaleRatePolicy
+	// PivotCurrencies are the cross currencies of conversions, in order of preference
+	PivotCurrencies []string
+	Alert           service.AlertConfig
+	// RateEventLogSize is how many rate events a reconnecting stream client can catch up on
+	RateEventLogSize int
+	// RequireIfMatch rejects rate updates that do not say which version of the rate they change,
+	// on every API and in imports
+	RequireIfMatch bool
+	Approval       service.ApprovalPolicy
+	RateGuard      service.RateGuardPolicy
+}
+
+const (
+	envAddr                   = "EXCHANGER_ADDR"
+	envGRPCAddr               = "EXCHANGER_GRPC_ADDR"
+	envRateMaxAge             = "EXCHANGER_RATE_MAX_AGE"
+	envRatePairMaxAge         = "EXCHANGER_RATE_PAIR_MAX_AGE"
+	envStaleRateMode          = "EXCHANGER_STALE_RATE_MODE"
+	envWebhookMaxAttempts     = "EXCHANGER_WEBHOOK_MAX_ATTEMPTS"
+	envWebhookRetryBackoff    = "EXCHANGER_WEBHOOK_RETRY_BACKOFF"
+	envWebhookMaxRetryBackoff = "EXCHANGER_WEBHOOK_MAX_RETRY_BACKOFF"
+	envWebhookTimeout         = "EXCHANGER_WEBHOOK_TIMEOUT"
+	envAlertPollInterval      = "EXCHANGER_ALERT_POLL_INTERVAL"
+	envRateEventLogSize       = "EXCHANGER_RATE_EVENT_LOG_SIZE"
+	envRequireIfMatch         = "EXCHANGER_REQUIRE_IF_MATCH"
+	envApprovalMaxDeviation   = "EXCHANGER_APPROVAL_MAX_DEVIATION"
+	envApprovalPairs          = "EXCHANGER_APPROVAL_PAIRS"
+	envApprovalTTL            = "EXCHANGER_APPROVAL_TTL"
+	envRateBounds             = "EXCHANGER_RATE_BOUNDS"
+	envRateMaxJump            = "EXCHANGER_RATE_MAX_JUMP"
+	envRateInverseTolerance   = "EXCHANGER_RATE_INVERSE_TOLERANCE"
+	envArbitrageThreshold     = "EXCHANGER_ARBITRAGE_THRESHOLD"
+	envArbitrageBlock         = "EXCHANGER_ARBITRAGE_BLOCK"
+	envPivotCurrencies        = "EXCHANGER_PIVOT_CURRENCIES"
+)
+
+const (
+	defaultAddr                   = ":8080"
+	defaultGRPCAddr               = ":9090"
+	defaultStaleRateMode          = service.StaleRateFail
+	defaultWebhookMaxAttempts     = 8
+	defaultWebhookRetryBackoff    = 10 * time.Second
+	defaultWebhookMaxRetryBackoff = time.Hour
+	defaultWebhookTimeout         = 10 * time.Second
+	defaultAlertPollInterval      = 5 * time.Second
+	defaultRateEventLogSize       = 1024
+	defaultApprovalTTL            = 24 * time.Hour
+	defaultArbitrageThreshold     = 0.0001
+	defaultPivotCurrencies        = "USD"
+)
+
+func Load() (Config, error) {
+	const op = "internal.config.config.Load"
+
+	cfg := Config{
+		Addr:     getEnv(envAddr, defaultAddr),
+		GRPCAddr: getEnv(envGRPCAddr, defaultGRPCAddr),
+		StaleRatePolicy: service.StaleRatePolicy{
+			Mode: service.StaleRateMode(getEnv(envStaleRateMode, string(defaultStaleRateMode))),
+		},
+	}
+
+	switch cfg.StaleRatePolicy.Mode {
+	case service.StaleRateFail, service.StaleRateWarn, service.StaleRateFallback:
+	default:
+		return Config{}, fmt.Errorf("%s: %s: unknown mode %q", op, envStaleRateMode, cfg.StaleRatePolicy.Mode)
+	}
+
+	var err error
+
+	if cfg.StaleRatePolicy.MaxAge, err = getDuration(envRateMaxAge, 0); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// EXCHANGER_RATE_PAIR_MAX_AGE=USDRUB=1h,EURUSD=24h
+	if v := os.Getenv(envRatePairMaxAge); v != "" {
+		cfg.StaleRatePolicy.PairMaxAge = make(map[string]time.Duration)
+		for _, item := range strings.Split(v, ",") {
+			pair, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok {
+				return Config{}, fmt.Errorf("%s: %s: invalid item %q", op, envRatePairMaxAge, item)
+			}
+			maxAge, err := time.ParseDuration(value)
+			if err != nil {
+				return Config{}, fmt.Errorf("%s: %s: %w", op, envRatePairMaxAge, err)
+			}
+			cfg.StaleRatePolicy.PairMaxAge[strings.ToUpper(pair)] = maxAge
+		}
+	}
+
+	// EXCHANGER_PIVOT_CURRENCIES=RUB,USD,EUR tries RUB first
+	for _, code := range strings.Split(getEnv(envPivotCurrencies, defaultPivotCurrencies), ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if slices.Contains(cfg.PivotCurrencies, code) {
+			return Config{}, fmt.Errorf("%s: %s: %s is listed twice", op, envPivotCurrencies, code)
+		}
+		cfg.PivotCurrencies = append(cfg.PivotCurrencies, code)
+	}
+
+	if cfg.Alert.MaxAttempts, err = getInt(envWebhookMaxAttempts, defaultWebhookMaxAttempts); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Alert.MaxAttempts <= 0 {
+		return Config{}, fmt.Errorf("%s: %s: must be greater than zero", op, envWebhookMaxAttempts)
+	}
+	if cfg.Alert.RetryBackoff, err = getDuration(envWebhookRetryBackoff, defaultWebhookRetryBackoff); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Alert.RetryBackoff <= 0 {
+		return Config{}, fmt.Errorf("%s: %s: must be greater than zero", op, envWebhookRetryBackoff)
+	}
+	if cfg.Alert.MaxRetryBackoff, err = getDuration(envWebhookMaxRetryBackoff, defaultWebhookMaxRetryBackoff); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Alert.MaxRetryBackoff < cfg.Alert.RetryBackoff {
+		return Config{}, fmt.Errorf("%s: %s: must not be below %s", op, envWebhookMaxRetryBackoff, envWebhookRetryBackoff)
+	}
+	if cfg.Alert.Timeout, err = getDuration(envWebhookTimeout, defaultWebhookTimeout); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Alert.Timeout <= 0 {
+		return Config{}, fmt.Errorf("%s: %s: must be greater than zero", op, envWebhookTimeout)
+	}
+	// the alert worker polls with a ticker, which panics on an interval that is not positive
+	if cfg.Alert.PollInterval, err = getDuration(envAlertPollInterval, defaultAlertPollInterval); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Alert.PollInterval <= 0 {
+		return Config{}, fmt.Errorf("%s: %s: must be greater than zero", op, envAlertPollInterval)
+	}
+
+	if cfg.RateEventLogSize, err = getInt(envRateEventLogSize, defaultRateEventLogSize); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.RateEventLogSize <= 0 {
+		return Config{}, fmt.Errorf("%s: %s: must be greater than zero", op, envRateEventLogSize)
+	}
+
+	if cfg.RequireIfMatch, err = getBool(envRequireIfMatch, false); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if cfg.Approval.MaxDeviation, err = getFloat(envApprovalMaxDeviation, 0); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Approval.MaxDeviation < 0 {
+		return Config{}, fmt.Errorf("%s: %s: must not be negative", op, envApprovalMaxDeviation)
+	}
+	// EXCHANGER_APPROVAL_PAIRS=USDRUB,EURUSD holds back every change of those pairs
+	if v := os.Getenv(envApprovalPairs); v != "" {
+		cfg.Approval.Pairs = make(map[string]bool)
+		for _, pair := range strings.Split(v, ",") {
+			cfg.Approval.Pairs[strings.ToUpper(strings.TrimSpace(pair))] = true
+		}
+	}
+	if cfg.Approval.TTL, err = getDuration(envApprovalTTL, defaultApprovalTTL); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.Approval.TTL <= 0 {
+		return Config{}, fmt.Errorf("%s: %s: must be greater than zero", op, envApprovalTTL)
+	}
+
+	// EXCHANGER_RATE_BOUNDS=USDRUB=50:200,EURUSD=0.5: limits rates, an empty max leaves them unbounded above
+	if v := os.Getenv(envRateBounds); v != "" {
+		cfg.RateGuard.Bounds = make(map[string]service.RateBounds)
+		for _, item := range strings.Split(v, ",") {
+			pair, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			minValue, maxValue, hasMax := strings.Cut(value, ":")
+			if !ok || !hasMax {
+				return Config{}, fmt.Errorf("%s: %s: %q is not PAIR=MIN:MAX", op, envRateBounds, item)
+			}
+
+			var bounds service.RateBounds
+			for _, bound := range []struct {
+				value string
+				dst   *float64
+			}{{minValue, &bounds.Min}, {maxValue, &bounds.Max}} {
+				if bound.value == "" {
+					continue
+				}
+				if *bound.dst, err = strconv.ParseFloat(bound.value, 64); err != nil {
+					return Config{}, fmt.Errorf("%s: %s: %w", op, envRateBounds, err)
+				}
+				if math.IsNaN(*bound.dst) || math.IsInf(*bound.dst, 0) {
+					return Config{}, fmt.Errorf("%s: %s: %q must be a finite number", op, envRateBounds, item)
+				}
+				if *bound.dst < 0 {
+					return Config{}, fmt.Errorf("%s: %s: %q must not be negative", op, envRateBounds, item)
+				}
+			}
+			// a max of 0 would reject every rate, it is left empty instead
+			if maxValue != "" && bounds.Max == 0 {
+				return Config{}, fmt.Errorf("%s: %s: %q must have a max greater than zero", op, envRateBounds, item)
+			}
+			if bounds.Max > 0 && bounds.Max < bounds.Min {
+				return Config{}, fmt.Errorf("%s: %s: %q has max below min", op, envRateBounds, item)
+			}
+			cfg.RateGuard.Bounds[strings.ToUpper(pair)] = bounds
+		}
+	}
+	if cfg.RateGuard.MaxJump, err = getFloat(envRateMaxJump, 0); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.RateGuard.MaxJump < 0 {
+		return Config{}, fmt.Errorf("%s: %s: must not be negative", op, envRateMaxJump)
+	}
+	if cfg.RateGuard.InverseTolerance, err = getFloat(envRateInverseTolerance, 0); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.RateGuard.InverseTolerance < 0 {
+		return Config{}, fmt.Errorf("%s: %s: must not be negative", op, envRateInverseTolerance)
+	}
+	if cfg.RateGuard.Arbitrage.Threshold, err = getFloat(envArbitrageThreshold, defaultArbitrageThreshold); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.RateGuard.Arbitrage.Threshold < 0 {
+		return Config{}, fmt.Errorf("%s: %s: must not be negative", op, envArbitrageThreshold)
+	}
+	if cfg.RateGuard.Arbitrage.Block, err = getBool(envArbitrageBlock, false); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func getInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+func getBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return b, nil
+}
+
+func getFloat(key string, fallback float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	// NaN passes every comparison a check makes, so it would turn the check off
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s: must be a finite number", key)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsInvalidAlertSettings(t *testing.T) {
+	tests := []struct {
+		env   string
+		value string
+	}{
+		{envAlertPollInterval, "0s"},
+		{envAlertPollInterval, "-5s"},
+		{envWebhookRetryBackoff, "0s"},
+		{envWebhookMaxRetryBackoff, "-1s"},
+		{envWebhookMaxRetryBackoff, "1s"},
+		{envWebhookTimeout, "0s"},
+		{envWebhookMaxAttempts, "0"},
+		{envRateEventLogSize, "0"},
+		{envApprovalTTL, "-1h"},
+		{envApprovalMaxDeviation, "NaN"},
+		{envRateMaxJump, "-0.1"},
+		{envRateMaxJump, "NaN"},
+		{envRateInverseTolerance, "-0.01"},
+		{envRateInverseTolerance, "nan"},
+		{envRateInverseTolerance, "Inf"},
+		{envArbitrageThreshold, "NaN"},
+		{envRateBounds, "USDRUB=-1:200"},
+		{envRateBounds, "USDRUB=NaN:200"},
+		{envRateBounds, "USDRUB=50:NaN"},
+		{envRateBounds, "USDRUB=200:50"},
+		{envRateBounds, "USDRUB=50:0"},
+		{envRateBounds, "USDRUB=50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.env) {
+				t.Errorf("Load() error = %v, want one about %s", err, tt.env)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Alert.PollInterval != defaultAlertPollInterval || cfg.Alert.MaxAttempts != defaultWebhookMaxAttempts {
+		t.Errorf("alert config = %+v, want the defaults", cfg.Alert)
+	}
+}
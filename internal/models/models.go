@@ -1,17 +1,76 @@
 package models
 
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
 type Currency struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 	Code string `json:"code"`
 	Sign string `json:"sign"`
+	// Active is false for a deactivated currency, it is hidden from lists and rejected in conversions
+	Active bool `json:"active"`
+	// Version is bumped by every change of the currency
+	Version int64 `json:"version"`
+	// Basket makes a currency synthetic, one unit of it is worth the components together and its
+	// rates are derived from theirs. It is only set by the reads of currencies.
+	Basket []BasketComponent `json:"basket,omitempty"`
+}
+
+// BasketComponent is an amount of a real currency in the basket of a synthetic one
+type BasketComponent struct {
+	CurrencyCode string  `json:"currencyCode"`
+	Amount       float64 `json:"amount"`
 }
 
 type ExchangeRate struct {
-	ID             int      `json:"id"`
-	BaseCurrency   Currency `json:"baseCurrency"`
-	TargetCurrency Currency `json:"targetCurrency"`
-	Rate           float64  `json:"rate"`
+	ID             int       `json:"id"`
+	BaseCurrency   Currency  `json:"baseCurrency"`
+	TargetCurrency Currency  `json:"targetCurrency"`
+	Rate           float64   `json:"rate"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	// Source tells where the current value came from, RateSourceAPI for rates written through the API
+	Source string `json:"source"`
+	// Generation is the write the value came from, rates updated together share it
+	Generation int64 `json:"generation"`
+	// Version is bumped by every change of the rate, including renames of its currencies
+	Version int64 `json:"version"`
+	// Warnings are only set on the answer to a write, e.g. when the rate disagrees with the
+	// rate of the reverse pair
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+const (
+	RateSourceAPI    = "api"
+	RateSourceImport = "import"
+	// RateSourceSchedule marks rates set by a scheduled change when it became effective
+	RateSourceSchedule = "schedule"
+	// RateSourceBasket marks the rates of synthetic currencies derived from their baskets
+	RateSourceBasket = "basket"
+	// RateSourcePeg marks the rates of pegged currencies kept by their pegs
+	RateSourcePeg = "peg"
+)
+
+// RateUpdate sets the rate of an existing pair
+type RateUpdate struct {
+	BaseCurrencyCode   string
+	TargetCurrencyCode string
+	Rate               float64
+	// Version the pair must still be at, 0 for any
+	Version int64
+	// UpdatedAt is only set for derived rates, they are as old as the oldest rate they were
+	// derived from. Zero means now.
+	UpdatedAt time.Time
+}
+
+// RateGeneration is a set of rates written together, readers see all of them or none
+type RateGeneration struct {
+	ID            int64          `json:"id"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	ExchangeRates []ExchangeRate `json:"exchangeRates"`
 }
 
 type CurrencyConversion struct {
@@ -20,4 +79,512 @@ type CurrencyConversion struct {
 	Rate            float64  `json:"rate"`
 	Amount          float64  `json:"amount"`
 	ConvertedAmount float64  `json:"convertedAmount"`
+	// Stale is set when the conversion used a rate older than the configured max age
+	Stale bool `json:"stale,omitempty"`
+	// Pivot is the currency a cross rate was derived through, empty for the rate of the pair
+	// or its inverse
+	Pivot string `json:"pivot,omitempty"`
+	// Explanation is only set when it was asked for
+	Explanation *ConversionExplanation `json:"explanation,omitempty"`
+}
+
+// ConversionExplanation tells how a conversion was computed, so it can be reproduced exactly
+type ConversionExplanation struct {
+	// Strategy is how the rate was resolved: direct, inverse or cross
+	Strategy RouteKind       `json:"strategy"`
+	Pivot    string          `json:"pivot,omitempty"`
+	Legs     []ConversionLeg `json:"legs"`
+	// Steps compute Rate and ConvertedAmount in IEEE 754 double precision, each result rounded
+	// to nearest with ties to even. Operations that can not round, such as x × 1, are left out,
+	// nothing is rounded to the minor unit of a currency.
+	Steps []ConversionStep `json:"steps"`
+	// Skipped are the routes preferred over the one used that were passed over because a rate
+	// of them is stale
+	Skipped []SkippedRoute `json:"skipped,omitempty"`
+	// ComputedAt is the time the rates were checked for staleness against
+	ComputedAt time.Time `json:"computedAt"`
+}
+
+// ConversionLeg is a stored rate a conversion went through, from FromCurrencyCode to
+// ToCurrencyCode, Inverted when it is quoted the other way round
+type ConversionLeg struct {
+	FromCurrencyCode string  `json:"fromCurrencyCode"`
+	ToCurrencyCode   string  `json:"toCurrencyCode"`
+	Pair             string  `json:"pair"`
+	ExchangeRateID   int     `json:"exchangeRateId"`
+	StoredRate       float64 `json:"storedRate"`
+	Inverted         bool    `json:"inverted,omitempty"`
+	// Amount is the amount after this leg, converted leg by leg. It can differ from
+	// ConvertedAmount in the last digits, which is computed with the rate of the whole route.
+	Amount     float64   `json:"amount"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Version    int64     `json:"version"`
+	Generation int64     `json:"generation"`
+	Stale      bool      `json:"stale,omitempty"`
+}
+
+// ConversionStep is one floating point operation of a conversion, Result = Left op Right
+type ConversionStep struct {
+	// Description names what the step computes, e.g. "(1) / (EURUSD)"
+	Description string  `json:"description"`
+	Operation   string  `json:"operation"`
+	Left        float64 `json:"left"`
+	Right       float64 `json:"right"`
+	Result      float64 `json:"result"`
+}
+
+type SkippedRoute struct {
+	Strategy RouteKind `json:"strategy"`
+	Pivot    string    `json:"pivot,omitempty"`
+	// Pairs are the stored rates of the route
+	Pairs  []string `json:"pairs"`
+	Reason string   `json:"reason"`
+}
+
+// RouteKind tells how a rate between two currencies is computed from the stored rates
+type RouteKind string
+
+const (
+	// RouteDirect is the stored rate of the pair
+	RouteDirect RouteKind = "direct"
+	// RouteInverse is the inverse of the stored rate of the reverse pair
+	RouteInverse RouteKind = "inverse"
+	// RouteCross is derived from the rates of both currencies against a pivot currency
+	RouteCross RouteKind = "cross"
+	// RouteIdentity is the rate of a currency to itself
+	RouteIdentity RouteKind = "identity"
+	// RouteUnavailable is a pair that can not be converted
+	RouteUnavailable RouteKind = "unavailable"
+)
+
+// RateMatrixCell is the rate a conversion of a pair would use, Reason tells why an unavailable
+// cell has none: not_found or stale
+type RateMatrixCell struct {
+	Rate   *float64  `json:"rate,omitempty"`
+	Kind   RouteKind `json:"kind"`
+	Pivot  string    `json:"pivot,omitempty"`
+	Stale  bool      `json:"stale,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// RateMatrix holds the rate of every pair of Currencies, Cells[i][j] is the rate from
+// Currencies[i] to Currencies[j]
+type RateMatrix struct {
+	Currencies []string           `json:"currencies"`
+	Cells      [][]RateMatrixCell `json:"cells"`
+}
+
+// RateChange describes a single write to ExchangeRates, Previous is nil for a new pair. A deleted
+// pair has Deleted set and its last rate in both Previous and Current.
+type RateChange struct {
+	Previous *ExchangeRate `json:"previous,omitempty"`
+	Current  ExchangeRate  `json:"current"`
+	Deleted  bool          `json:"deleted,omitempty"`
+}
+
+type AlertCondition string
+
+const (
+	// AlertThreshold fires when the rate crosses Level in either direction
+	AlertThreshold AlertCondition = "threshold"
+	// AlertPercentChange fires when the rate moves by at least Percent within WindowSeconds
+	AlertPercentChange AlertCondition = "percent_change"
+	// AlertStaleness fires when the rate has not been updated for MaxAgeSeconds
+	AlertStaleness AlertCondition = "staleness"
+)
+
+type AlertSubscription struct {
+	ID                 int            `json:"id"`
+	BaseCurrencyCode   string         `json:"baseCurrencyCode"`
+	TargetCurrencyCode string         `json:"targetCurrencyCode"`
+	Condition          AlertCondition `json:"condition"`
+	Level              float64        `json:"level,omitempty"`
+	Percent            float64        `json:"percent,omitempty"`
+	WindowSeconds      int            `json:"windowSeconds,omitempty"`
+	MaxAgeSeconds      int            `json:"maxAgeSeconds,omitempty"`
+	URL                string         `json:"url"`
+	Secret             string         `json:"secret,omitempty"`
+	LastTriggeredAt    *time.Time     `json:"lastTriggeredAt,omitempty"`
+	CreatedAt          time.Time      `json:"createdAt"`
+}
+
+// AlertEvent is the body of a webhook sent for a triggered subscription
+type AlertEvent struct {
+	SubscriptionID     int            `json:"subscriptionId"`
+	Condition          AlertCondition `json:"condition"`
+	BaseCurrencyCode   string         `json:"baseCurrencyCode"`
+	TargetCurrencyCode string         `json:"targetCurrencyCode"`
+	Rate               float64        `json:"rate"`
+	ReferenceRate      float64        `json:"referenceRate,omitempty"`
+	UpdatedAt          time.Time      `json:"updatedAt"`
+	TriggeredAt        time.Time      `json:"triggeredAt"`
+}
+
+type AlertDeliveryStatus string
+
+const (
+	AlertDeliveryPending   AlertDeliveryStatus = "pending"
+	AlertDeliveryDelivered AlertDeliveryStatus = "delivered"
+	AlertDeliveryDead      AlertDeliveryStatus = "dead"
+)
+
+type AlertDelivery struct {
+	ID             int                 `json:"id"`
+	SubscriptionID int                 `json:"subscriptionId"`
+	URL            string              `json:"url"`
+	Payload        json.RawMessage     `json:"payload"`
+	Status         AlertDeliveryStatus `json:"status"`
+	Attempts       int                 `json:"attempts"`
+	LastError      string              `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time           `json:"nextAttemptAt"`
+	CreatedAt      time.Time           `json:"createdAt"`
+}
+
+// RateEvent is a RateChange numbered in the order it was published
+type RateEvent struct {
+	ID uint64 `json:"id"`
+	RateChange
+}
+
+type RateHistoryPoint struct {
+	ExchangeRateID int       `json:"exchangeRateId"`
+	Rate           float64   `json:"rate"`
+	RecordedAt     time.Time `json:"recordedAt"`
+}
+
+// RateCandle is what the rate of a pair did in one interval starting at Start. Open is the rate
+// in effect at Start, so a candle without updates repeats the close of the one before.
+type RateCandle struct {
+	Start   time.Time `json:"start"`
+	Open    float64   `json:"open"`
+	High    float64   `json:"high"`
+	Low     float64   `json:"low"`
+	Close   float64   `json:"close"`
+	Updates int       `json:"updates"`
+}
+
+// RateStats sum up the candles of a pair over a window. Volatility is the standard deviation of
+// the relative changes between consecutive closes, in percent like ChangePercent.
+type RateStats struct {
+	Pair          string    `json:"pair"`
+	Interval      string    `json:"interval"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Periods       int       `json:"periods"`
+	Updates       int       `json:"updates"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
+	Close         float64   `json:"close"`
+	ChangePercent float64   `json:"changePercent"`
+	SMA           float64   `json:"sma"`
+	EMA           float64   `json:"ema"`
+	Volatility    float64   `json:"volatility"`
+}
+
+// PageRequest selects one page of a sorted list
+type PageRequest struct {
+	// Sort is a sortable field, prefixed with "-" for descending order, empty for the ID order
+	Sort string
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// Page is one page of a list, NextCursor is empty on the last page
+type Page[T any] struct {
+	Items      []T
+	Total      int
+	NextCursor string
+}
+
+type CurrencyFilter struct {
+	CodePrefix string
+	// IncludeInactive also lists deactivated currencies
+	IncludeInactive bool
+}
+
+type ExchangeRateFilter struct {
+	BaseCode   string
+	TargetCode string
+	// CodePrefix matches rates where either currency code starts with it
+	CodePrefix   string
+	UpdatedSince time.Time
+	Source       string
+}
+
+// Catalogue is the set of currencies and rates moved by import and export
+type Catalogue struct {
+	Currencies    []CurrencyRecord `json:"currencies"`
+	ExchangeRates []RateRecord     `json:"exchangeRates"`
+}
+
+type CurrencyRecord struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Sign string `json:"sign"`
+	// Active is optional on import, a new currency is active and an existing one keeps its state
+	Active *bool `json:"active,omitempty"`
+	// Ref locates the record in the imported file, e.g. rows[3] or currencies[0]
+	Ref string `json:"-"`
+}
+
+type RateRecord struct {
+	BaseCurrencyCode   string  `json:"baseCurrencyCode"`
+	TargetCurrencyCode string  `json:"targetCurrencyCode"`
+	Rate               float64 `json:"rate"`
+	// Version is optional on import, a rate that is changed must still be at it
+	Version int64  `json:"version,omitempty"`
+	Ref     string `json:"-"`
+}
+
+// record kinds, also the type column of a CSV catalogue
+const (
+	RecordCurrency = "currency"
+	RecordRate     = "rate"
+)
+
+type ImportAction string
+
+const (
+	ImportCreated   ImportAction = "created"
+	ImportUpdated   ImportAction = "updated"
+	ImportUnchanged ImportAction = "unchanged"
+)
+
+// ImportRow is what an import did, or would do in a dry run, with one record
+type ImportRow struct {
+	Ref    string       `json:"ref"`
+	Kind   string       `json:"kind"`
+	Key    string       `json:"key"`
+	Action ImportAction `json:"action"`
+}
+
+// ImportError is a rejected field of an imported record
+type ImportError struct {
+	Ref     string `json:"ref"`
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ImportReport struct {
+	DryRun    bool          `json:"dryRun"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Rows      []ImportRow   `json:"rows"`
+	Errors    []ImportError `json:"errors,omitempty"`
+	// RateChanges are the rate writes of an applied import, for the rate listeners
+	RateChanges []RateChange `json:"-"`
+}
+
+func (r *ImportReport) AddRow(ref, kind, key string, action ImportAction) {
+	r.Rows = append(r.Rows, ImportRow{Ref: ref, Kind: kind, Key: key, Action: action})
+	switch action {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportUnchanged:
+		r.Unchanged++
+	}
+}
+
+func (r *ImportReport) AddError(ref, field, code, message string) {
+	r.Errors = append(r.Errors, ImportError{Ref: ref, Field: field, Code: code, Message: message})
+}
+
+type RateProposalStatus string
+
+const (
+	RateProposalPending  RateProposalStatus = "pending"
+	RateProposalApproved RateProposalStatus = "approved"
+	RateProposalRejected RateProposalStatus = "rejected"
+	RateProposalExpired  RateProposalStatus = "expired"
+)
+
+// RateProposal is a rate change that waits for the approval of another user before it is applied
+type RateProposal struct {
+	ID                 int     `json:"id"`
+	BaseCurrencyCode   string  `json:"baseCurrencyCode"`
+	TargetCurrencyCode string  `json:"targetCurrencyCode"`
+	Rate               float64 `json:"rate"`
+	// PreviousRate and RateVersion are the rate the change was proposed against, an approval only
+	// applies it while the rate is still at that version
+	PreviousRate float64            `json:"previousRate"`
+	RateVersion  int64              `json:"rateVersion"`
+	Status       RateProposalStatus `json:"status"`
+	ProposedBy   string             `json:"proposedBy"`
+	ProposedAt   time.Time          `json:"proposedAt"`
+	ExpiresAt    time.Time          `json:"expiresAt"`
+	DecidedBy    string             `json:"decidedBy,omitempty"`
+	DecidedAt    *time.Time         `json:"decidedAt,omitempty"`
+	// Reason is why the proposal was rejected
+	Reason string `json:"reason,omitempty"`
+}
+
+type RateProposalFilter struct {
+	Status     RateProposalStatus
+	BaseCode   string
+	TargetCode string
+}
+
+type ScheduledRateStatus string
+
+const (
+	ScheduledRatePending  ScheduledRateStatus = "pending"
+	ScheduledRateApplied  ScheduledRateStatus = "applied"
+	ScheduledRateCanceled ScheduledRateStatus = "canceled"
+	// ScheduledRateFailed is a change that could not be applied, e.g. because a currency was deactivated
+	ScheduledRateFailed ScheduledRateStatus = "failed"
+)
+
+// ScheduledRate is a rate that is stored ahead of time and set on its pair at EffectiveFrom,
+// creating the pair when it has no rate then
+type ScheduledRate struct {
+	ID                 int     `json:"id"`
+	BaseCurrencyCode   string  `json:"baseCurrencyCode"`
+	TargetCurrencyCode string  `json:"targetCurrencyCode"`
+	Rate               float64 `json:"rate"`
+	// RateVersion is the version of the rate the change was scheduled against, 0 for a new pair.
+	// The change fails when the pair is no longer at it by the time the change becomes effective.
+	RateVersion   int64               `json:"rateVersion"`
+	EffectiveFrom time.Time           `json:"effectiveFrom"`
+	Status        ScheduledRateStatus `json:"status"`
+	CreatedBy     string              `json:"createdBy"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	AppliedAt     *time.Time          `json:"appliedAt,omitempty"`
+	// Error tells why a failed change could not be applied
+	Error string `json:"error,omitempty"`
+}
+
+type ScheduledRateFilter struct {
+	Status     ScheduledRateStatus
+	BaseCode   string
+	TargetCode string
+}
+
+// ArbitrageLeg is one conversion of a loop, Inverse when it goes against a stored rate of
+// TargetCurrencyCode to BaseCurrencyCode the way conversions do when the pair has no rate
+type ArbitrageLeg struct {
+	BaseCurrencyCode   string  `json:"baseCurrencyCode"`
+	TargetCurrencyCode string  `json:"targetCurrencyCode"`
+	Rate               float64 `json:"rate"`
+	Inverse            bool    `json:"inverse,omitempty"`
+}
+
+// ArbitrageCycle is a loop of conversions that ends with more of its first currency than it
+// started with, Currencies start and end with that currency
+type ArbitrageCycle struct {
+	Currencies []string       `json:"currencies"`
+	Legs       []ArbitrageLeg `json:"legs"`
+	// Product is what one unit becomes after going round the loop
+	Product float64 `json:"product"`
+	// Profit is Product - 1
+	Profit float64 `json:"profit"`
+}
+
+type ArbitrageReport struct {
+	Threshold float64          `json:"threshold"`
+	Cycles    []ArbitrageCycle `json:"cycles"`
+}
+
+type ArbitrageFilter struct {
+	// Threshold is the least profit of a reported loop, nil for the configured one
+	Threshold *float64
+	// Currency keeps the loops through it
+	Currency string
+}
+
+// Peg fixes the rate of a currency to an anchor currency, one unit of the anchor is worth
+// CentralRate of the pegged currency give or take Band, e.g. 0.005 for 0.5%
+type Peg struct {
+	CurrencyCode       string    `json:"currencyCode"`
+	AnchorCurrencyCode string    `json:"anchorCurrencyCode"`
+	CentralRate        float64   `json:"centralRate"`
+	Band               float64   `json:"band"`
+	CreatedBy          string    `json:"createdBy"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+type AuditEntity string
+
+const (
+	AuditCurrency      AuditEntity = "currency"
+	AuditExchangeRate  AuditEntity = "exchangeRate"
+	AuditRateProposal  AuditEntity = "rateProposal"
+	AuditScheduledRate AuditEntity = "scheduledRate"
+	AuditPeg           AuditEntity = "peg"
+	// AuditAlertSubscription entries are keyed by the subscription ID and never hold its secret
+	AuditAlertSubscription AuditEntity = "alertSubscription"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate     AuditAction = "create"
+	AuditUpdate     AuditAction = "update"
+	AuditDelete     AuditAction = "delete"
+	AuditDeactivate AuditAction = "deactivate"
+	AuditActivate   AuditAction = "activate"
+	AuditApprove    AuditAction = "approve"
+	AuditReject     AuditAction = "reject"
+	AuditCancel     AuditAction = "cancel"
+)
+
+// AuditEntry records one change of a currency, rate or alert subscription, OldValue is absent
+// for a created record and NewValue for a deleted one
+type AuditEntry struct {
+	ID     int         `json:"id"`
+	Entity AuditEntity `json:"entity"`
+	// Key is the currency code, the pair or the ID of an alert subscription
+	Key       string          `json:"key"`
+	Action    AuditAction     `json:"action"`
+	OldValue  json.RawMessage `json:"oldValue,omitempty"`
+	NewValue  json.RawMessage `json:"newValue,omitempty"`
+	Actor     string          `json:"actor"`
+	SourceIP  string          `json:"sourceIp"`
+	RequestID string          `json:"requestId"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type AuditFilter struct {
+	Entity    AuditEntity
+	Key       string
+	Action    AuditAction
+	Actor     string
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Actor is who makes a request and from where, changes made while serving it are audited with it
+type Actor struct {
+	Name string
+	// Scopes are what the actor is allowed to do beyond reading and writing, e.g. rates:approve
+	Scopes    []string
+	IP        string
+	RequestID string
+}
+
+func (a Actor) HasScope(scope string) bool {
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type actorKey struct{}
+
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor of the request, the zero Actor when there is none
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
 }
@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrAlertSubscriptionNotFound = errors.New("alert subscription not found")
+	ErrAlertDeliveryNotFound     = errors.New("alert delivery not found")
+)
+
+const alertSubscriptionColumns = `ID, base_currency_code, target_currency_code, condition, level, percent,
+	window_seconds, max_age_seconds, url, secret, last_triggered_at, created_at`
+
+func scanAlertSubscription(row interface{ Scan(dest ...any) error }) (models.AlertSubscription, error) {
+	var s models.AlertSubscription
+	var lastTriggeredAt sql.NullTime
+
+	err := row.Scan(
+		&s.ID, &s.BaseCurrencyCode, &s.TargetCurrencyCode, &s.Condition, &s.Level, &s.Percent,
+		&s.WindowSeconds, &s.MaxAgeSeconds, &s.URL, &s.Secret, &lastTriggeredAt, &s.CreatedAt)
+	if err != nil {
+		return models.AlertSubscription{}, err
+	}
+
+	if lastTriggeredAt.Valid {
+		s.LastTriggeredAt = &lastTriggeredAt.Time
+	}
+
+	return s, nil
+}
+
+func (r *repository) queryAlertSubscriptions(ctx context.Context, query string, args ...any) ([]models.AlertSubscription, error) {
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []models.AlertSubscription
+	for rows.Next() {
+		s, err := scanAlertSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (r *repository) GetAllAlertSubscriptions(ctx context.Context) ([]models.AlertSubscription, error) {
+	const op = "internal.repository.repository.GetAllAlertSubscriptions"
+
+	subscriptions, err := r.queryAlertSubscriptions(ctx, "SELECT "+alertSubscriptionColumns+" FROM AlertSubscriptions")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(subscriptions) == 0 {
+		return []models.AlertSubscription{}, fmt.Errorf("%s: %w", op, ErrAlertSubscriptionNotFound)
+	}
+
+	return subscriptions, nil
+}
+
+// GetAlertSubscriptionsByPair returns an empty slice without an error when nobody watches the pair
+func (r *repository) GetAlertSubscriptionsByPair(ctx context.Context, baseCode, targetCode string) ([]models.AlertSubscription, error) {
+	const op = "internal.repository.repository.GetAlertSubscriptionsByPair"
+
+	subscriptions, err := r.queryAlertSubscriptions(ctx,
+		"SELECT "+alertSubscriptionColumns+" FROM AlertSubscriptions WHERE base_currency_code = ? AND target_currency_code = ?",
+		baseCode, targetCode)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscriptions, nil
+}
+
+// GetAlertSubscriptionsByCondition returns an empty slice without an error when there are no such subscriptions
+func (r *repository) GetAlertSubscriptionsByCondition(ctx context.Context, condition models.AlertCondition) ([]models.AlertSubscription, error) {
+	const op = "internal.repository.repository.GetAlertSubscriptionsByCondition"
+
+	subscriptions, err := r.queryAlertSubscriptions(ctx,
+		"SELECT "+alertSubscriptionColumns+" FROM AlertSubscriptions WHERE condition = ?", condition)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *repository) GetAlertSubscription(ctx context.Context, id int) (models.AlertSubscription, error) {
+	const op = "internal.repository.repository.GetAlertSubscription"
+
+	s, err := scanAlertSubscription(r.conn.QueryRowContext(ctx,
+		"SELECT "+alertSubscriptionColumns+" FROM AlertSubscriptions WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, ErrAlertSubscriptionNotFound)
+	} else if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// auditedAlertSubscription is the subscription as it is recorded in the audit log, without its secret
+func auditedAlertSubscription(s models.AlertSubscription) models.AlertSubscription {
+	s.Secret = ""
+	return s
+}
+
+func (r *repository) AddAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error) {
+	const op = "internal.repository.repository.AddAlertSubscription"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	subscription.CreatedAt = time.Now().UTC()
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO AlertSubscriptions (base_currency_code, target_currency_code, condition, level, percent,
+		window_seconds, max_age_seconds, url, secret, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING ID`,
+		subscription.BaseCurrencyCode, subscription.TargetCurrencyCode, subscription.Condition,
+		subscription.Level, subscription.Percent, subscription.WindowSeconds, subscription.MaxAgeSeconds,
+		subscription.URL, subscription.Secret, subscription.CreatedAt,
+	).Scan(&subscription.ID)
+	if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditAlertSubscription, strconv.Itoa(subscription.ID), models.AuditCreate,
+		nil, auditedAlertSubscription(subscription), subscription.CreatedAt); err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subscription, nil
+}
+
+func (r *repository) UpdateAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error) {
+	const op = "internal.repository.repository.UpdateAlertSubscription"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	previous, err := scanAlertSubscription(tx.QueryRowContext(ctx,
+		"SELECT "+alertSubscriptionColumns+" FROM AlertSubscriptions WHERE ID = ?", subscription.ID))
+	if err == sql.ErrNoRows {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, ErrAlertSubscriptionNotFound)
+	} else if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated, err := scanAlertSubscription(tx.QueryRowContext(ctx, `
+	UPDATE AlertSubscriptions SET base_currency_code = ?, target_currency_code = ?, condition = ?, level = ?,
+		percent = ?, window_seconds = ?, max_age_seconds = ?, url = ?, secret = ?
+	WHERE ID = ?
+	RETURNING `+alertSubscriptionColumns,
+		subscription.BaseCurrencyCode, subscription.TargetCurrencyCode, subscription.Condition,
+		subscription.Level, subscription.Percent, subscription.WindowSeconds, subscription.MaxAgeSeconds,
+		subscription.URL, subscription.Secret, subscription.ID,
+	))
+	if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditAlertSubscription, strconv.Itoa(updated.ID), models.AuditUpdate,
+		auditedAlertSubscription(previous), auditedAlertSubscription(updated), time.Now().UTC()); err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return updated, nil
+}
+
+// DeleteAlertSubscription removes the subscription together with its deliveries
+func (r *repository) DeleteAlertSubscription(ctx context.Context, id int) error {
+	const op = "internal.repository.repository.DeleteAlertSubscription"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	previous, err := scanAlertSubscription(tx.QueryRowContext(ctx,
+		"SELECT "+alertSubscriptionColumns+" FROM AlertSubscriptions WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", op, ErrAlertSubscriptionNotFound)
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM AlertDeliveries WHERE subscription_id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM AlertSubscriptions WHERE ID = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditAlertSubscription, strconv.Itoa(id), models.AuditDelete,
+		auditedAlertSubscription(previous), nil, time.Now().UTC()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TriggerAlert marks the subscription as triggered and queues a delivery for it in one transaction
+func (r *repository) TriggerAlert(ctx context.Context, subscription models.AlertSubscription, payload []byte, triggeredAt time.Time) error {
+	const op = "internal.repository.repository.TriggerAlert"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE AlertSubscriptions SET last_triggered_at = ? WHERE ID = ?",
+		triggeredAt.UTC(), subscription.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO AlertDeliveries (subscription_id, url, payload, status, next_attempt_at, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`,
+		subscription.ID, subscription.URL, string(payload), models.AlertDeliveryPending, triggeredAt.UTC(), triggeredAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+const alertDeliveryColumns = `ID, subscription_id, url, payload, status, attempts, last_error, next_attempt_at, created_at`
+
+func (r *repository) queryAlertDeliveries(ctx context.Context, query string, args ...any) ([]models.AlertDelivery, error) {
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.AlertDelivery
+	for rows.Next() {
+		var d models.AlertDelivery
+		var payload string
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.URL, &payload, &d.Status, &d.Attempts,
+			&d.LastError, &d.NextAttemptAt, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetDueAlertDeliveries returns pending deliveries whose next attempt is not later than now
+func (r *repository) GetDueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]models.AlertDelivery, error) {
+	const op = "internal.repository.repository.GetDueAlertDeliveries"
+
+	deliveries, err := r.queryAlertDeliveries(ctx,
+		"SELECT "+alertDeliveryColumns+" FROM AlertDeliveries WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+		models.AlertDeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *repository) GetAlertDeliveriesByStatus(ctx context.Context, status models.AlertDeliveryStatus) ([]models.AlertDelivery, error) {
+	const op = "internal.repository.repository.GetAlertDeliveriesByStatus"
+
+	deliveries, err := r.queryAlertDeliveries(ctx,
+		"SELECT "+alertDeliveryColumns+" FROM AlertDeliveries WHERE status = ? ORDER BY ID", status)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(deliveries) == 0 {
+		return []models.AlertDelivery{}, fmt.Errorf("%s: %w", op, ErrAlertDeliveryNotFound)
+	}
+
+	return deliveries, nil
+}
+
+func (r *repository) GetAlertDelivery(ctx context.Context, id int) (models.AlertDelivery, error) {
+	const op = "internal.repository.repository.GetAlertDelivery"
+
+	deliveries, err := r.queryAlertDeliveries(ctx,
+		"SELECT "+alertDeliveryColumns+" FROM AlertDeliveries WHERE ID = ?", id)
+	if err != nil {
+		return models.AlertDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(deliveries) == 0 {
+		return models.AlertDelivery{}, fmt.Errorf("%s: %w", op, ErrAlertDeliveryNotFound)
+	}
+
+	return deliveries[0], nil
+}
+
+// UpdateAlertDelivery stores the outcome of a delivery attempt
+func (r *repository) UpdateAlertDelivery(ctx context.Context, delivery models.AlertDelivery) error {
+	const op = "internal.repository.repository.UpdateAlertDelivery"
+
+	result, err := r.conn.ExecContext(ctx,
+		"UPDATE AlertDeliveries SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ? WHERE ID = ?",
+		delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt.UTC(), delivery.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAlertDeliveryNotFound)
+	}
+
+	return nil
+}
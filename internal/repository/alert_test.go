@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAlertSubscriptionChangesAreAudited(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	subscription, err := repo.AddAlertSubscription(ctx, models.AlertSubscription{
+		BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Condition: models.AlertThreshold, Level: 0.9,
+		URL: "https://example.com/hook", Secret: "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("AddAlertSubscription: %v", err)
+	}
+	subscription.Level = 0.95
+	if _, err := repo.UpdateAlertSubscription(ctx, subscription); err != nil {
+		t.Fatalf("UpdateAlertSubscription: %v", err)
+	}
+	if err := repo.DeleteAlertSubscription(ctx, subscription.ID); err != nil {
+		t.Fatalf("DeleteAlertSubscription: %v", err)
+	}
+
+	page, err := repo.ListAuditEntries(ctx, models.AuditFilter{Entity: models.AuditAlertSubscription}, models.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+
+	want := []models.AuditAction{models.AuditCreate, models.AuditUpdate, models.AuditDelete}
+	if len(page.Items) != len(want) {
+		t.Fatalf("entries = %+v, want %v", page.Items, want)
+	}
+	for i, entry := range page.Items {
+		if entry.Action != want[i] || entry.Key != strconv.Itoa(subscription.ID) {
+			t.Errorf("entry %d = %s of %q, want %s of %d", i, entry.Action, entry.Key, want[i], subscription.ID)
+		}
+		if strings.Contains(string(entry.OldValue)+string(entry.NewValue), "s3cret") {
+			t.Errorf("entry %d holds the secret: %s %s", i, entry.OldValue, entry.NewValue)
+		}
+	}
+	if !strings.Contains(string(page.Items[1].OldValue), "0.9") || !strings.Contains(string(page.Items[1].NewValue), "0.95") {
+		t.Errorf("update entry = %s -> %s, want level 0.9 -> 0.95", page.Items[1].OldValue, page.Items[1].NewValue)
+	}
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAuditEntryNotFound = errors.New("audit entry not found")
+)
+
+// addAudit records a change in the transaction that makes it, with the actor of the request in
+// ctx. oldValue is nil for a created record and newValue for a deleted one.
+func addAudit(ctx context.Context, tx *sql.Tx, entity models.AuditEntity, key string, action models.AuditAction, oldValue, newValue any, at time.Time) error {
+	values := make([]sql.NullString, 2)
+	for i, v := range []any{oldValue, newValue} {
+		if v == nil {
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		values[i] = sql.NullString{String: string(data), Valid: true}
+	}
+
+	actor := models.ActorFromContext(ctx)
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO AuditLog (entity, entity_key, action, old_value, new_value, actor, source_ip, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entity, key, action, values[0], values[1], actor.Name, actor.IP, actor.RequestID, at)
+	return err
+}
+
+// LastAuditID is the ID of the latest audit entry, 0 while the log is empty. It changes with
+// every write of a currency or rate, whichever process makes it.
+func (r *repository) LastAuditID(ctx context.Context) (int64, error) {
+	const op = "internal.repository.repository.LastAuditID"
+
+	var id int64
+	if err := r.conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(ID), 0) FROM AuditLog").Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+var auditSortColumns = map[string]sortColumn{
+	"id":        {expr: "ID", key: intKey},
+	"createdAt": {expr: "created_at", key: timeKey},
+}
+
+func auditSortKey(field string, e models.AuditEntry) any {
+	if field == "createdAt" {
+		return e.CreatedAt
+	}
+	return e.ID
+}
+
+// ListAuditEntries returns one page of the audit entries matching the filter and their total count
+func (r *repository) ListAuditEntries(ctx context.Context, filter models.AuditFilter, page models.PageRequest) (models.Page[models.AuditEntry], error) {
+	const op = "internal.repository.repository.ListAuditEntries"
+
+	var conditions []string
+	var args []any
+	for _, eq := range []struct {
+		column string
+		value  string
+	}{
+		{"entity", string(filter.Entity)},
+		{"entity_key", filter.Key},
+		{"action", string(filter.Action)},
+		{"actor", filter.Actor},
+		{"request_id", filter.RequestID},
+	} {
+		if eq.value != "" {
+			conditions = append(conditions, eq.column+" = ?")
+			args = append(args, eq.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	orderBy, after, afterArgs, err := keyset(auditSortColumns, "ID", page.Sort, page.Cursor)
+	if err != nil {
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	err = r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM AuditLog"+where(conditions), args...).Scan(&total)
+	if err != nil {
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return models.Page[models.AuditEntry]{Items: []models.AuditEntry{}}, fmt.Errorf("%s: %w", op, ErrAuditEntryNotFound)
+	}
+
+	if after != "" {
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	// one more row tells whether there is a next page
+	query := `
+	SELECT ID, entity, entity_key, action, old_value, new_value, actor, source_ip, request_id, created_at
+	FROM AuditLog` + where(conditions) + orderBy + " LIMIT ?"
+	args = append(args, page.Limit+1)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var oldValue, newValue sql.NullString
+		err := rows.Scan(&e.ID, &e.Entity, &e.Key, &e.Action, &oldValue, &newValue, &e.Actor, &e.SourceIP, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return models.Page[models.AuditEntry]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if oldValue.Valid {
+			e.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			e.NewValue = json.RawMessage(newValue.String)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.AuditEntry]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := models.Page[models.AuditEntry]{Items: entries, Total: total}
+	if len(entries) > page.Limit {
+		result.Items = entries[:page.Limit]
+		last := result.Items[page.Limit-1]
+
+		field := strings.TrimPrefix(page.Sort, "-")
+		result.NextCursor, err = encodeCursor(page.Sort, auditSortKey(field, last), last.ID)
+		if err != nil {
+			return models.Page[models.AuditEntry]{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return result, nil
+}
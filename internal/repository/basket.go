@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+)
+
+var ErrNestedBasket = errors.New("basket component is a synthetic currency")
+
+// addBasket stores the components of a synthetic currency in tx, they must be active real currencies
+func addBasket(ctx context.Context, tx *sql.Tx, currencyID int, basket []models.BasketComponent) error {
+	for _, c := range basket {
+		component, err := currencyByCode(ctx, tx, c.CurrencyCode)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrCurrencyNotFound, c.CurrencyCode)
+		} else if err != nil {
+			return err
+		}
+		if !component.Active {
+			return fmt.Errorf("%w: %s", ErrCurrencyInactive, c.CurrencyCode)
+		}
+
+		var nested bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM CurrencyBaskets WHERE currency_id = ?)", component.ID).Scan(&nested); err != nil {
+			return err
+		}
+		if nested {
+			return fmt.Errorf("%w: %s", ErrNestedBasket, c.CurrencyCode)
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO CurrencyBaskets (currency_id, component_currency_id, amount) VALUES (?, ?, ?)",
+			currencyID, component.ID, c.Amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadBaskets sets the baskets of the synthetic currencies among currencies, the table is small
+// enough to be read whole
+func loadBaskets(ctx context.Context, q queryer, currencies []models.Currency) error {
+	rows, err := q.QueryContext(ctx, `
+	SELECT b.currency_id, c.code, b.amount
+	FROM CurrencyBaskets b
+	JOIN Currencies c ON b.component_currency_id = c.ID
+	ORDER BY b.currency_id, c.code
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	baskets := make(map[int][]models.BasketComponent)
+	for rows.Next() {
+		var id int
+		var c models.BasketComponent
+		if err := rows.Scan(&id, &c.CurrencyCode, &c.Amount); err != nil {
+			return err
+		}
+		baskets[id] = append(baskets[id], c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range currencies {
+		currencies[i].Basket = baskets[currencies[i].ID]
+	}
+
+	return nil
+}
+
+// GetBasketCurrencies returns the active synthetic currencies with their baskets
+func (r *repository) GetBasketCurrencies(ctx context.Context) ([]models.Currency, error) {
+	const op = "internal.repository.repository.GetBasketCurrencies"
+
+	rows, err := r.conn.QueryContext(ctx, `
+	SELECT ID, code, full_name, sign, active, version FROM Currencies
+	WHERE active = 1 AND ID IN (SELECT currency_id FROM CurrencyBaskets)
+	ORDER BY code
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	currencies := []models.Currency{}
+	for rows.Next() {
+		var c models.Currency
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.Sign, &c.Active, &c.Version); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		currencies = append(currencies, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loadBaskets(ctx, r.conn, currencies); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return currencies, nil
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"exchanger/internal/models"
+	"fmt"
+	"time"
+)
+
+// GetCatalogue returns every currency, deactivated ones included, and the active rates but the
+// derived ones of synthetic currencies
+func (r *repository) GetCatalogue(ctx context.Context) (models.Catalogue, error) {
+	const op = "internal.repository.repository.GetCatalogue"
+
+	catalogue := models.Catalogue{
+		Currencies:    []models.CurrencyRecord{},
+		ExchangeRates: []models.RateRecord{},
+	}
+
+	rows, err := r.conn.QueryContext(ctx, "SELECT code, full_name, sign, active FROM Currencies ORDER BY code")
+	if err != nil {
+		return models.Catalogue{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.CurrencyRecord
+		var active bool
+		if err := rows.Scan(&c.Code, &c.Name, &c.Sign, &active); err != nil {
+			return models.Catalogue{}, fmt.Errorf("%s: %w", op, err)
+		}
+		c.Active = &active
+		catalogue.Currencies = append(catalogue.Currencies, c)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Catalogue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err = r.conn.QueryContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND er.source NOT IN (?, ?) ORDER BY bc.code, tc.code", models.RateSourceBasket, models.RateSourcePeg)
+	if err != nil {
+		return models.Catalogue{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		er, err := scanExchangeRate(rows)
+		if err != nil {
+			return models.Catalogue{}, fmt.Errorf("%s: %w", op, err)
+		}
+		catalogue.ExchangeRates = append(catalogue.ExchangeRates, models.RateRecord{
+			BaseCurrencyCode:   er.BaseCurrency.Code,
+			TargetCurrencyCode: er.TargetCurrency.Code,
+			Rate:               er.Rate,
+			Version:            er.Version,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return models.Catalogue{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return catalogue, nil
+}
+
+// ImportCatalogue upserts the currencies and then the rates in one transaction. Records that
+// can not be applied, rates no longer at the version of their record among them, are reported in the Errors of the report and nothing is written, neither
+// is anything in a dry run.
+func (r *repository) ImportCatalogue(ctx context.Context, catalogue models.Catalogue, dryRun bool) (models.ImportReport, error) {
+	const op = "internal.repository.repository.ImportCatalogue"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	report := models.ImportReport{DryRun: dryRun, Rows: []models.ImportRow{}}
+
+	now := time.Now().UTC()
+
+	for _, record := range catalogue.Currencies {
+		action, err := importCurrency(ctx, tx, record, now)
+		if err != nil {
+			return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		report.AddRow(record.Ref, models.RecordCurrency, record.Code, action)
+	}
+
+	// the imported rates are one generation
+	generation, err := newRateGeneration(ctx, tx, now)
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, record := range catalogue.ExchangeRates {
+		if err := importExchangeRate(ctx, tx, record, now, generation, &report); err != nil {
+			return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// a currency deactivated by the import must not be left with active rates
+	for _, record := range catalogue.Currencies {
+		if record.Active == nil || *record.Active {
+			continue
+		}
+
+		var used int
+		err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM ExchangeRates er
+		JOIN Currencies c ON c.ID IN (er.base_currency_id, er.target_currency_id)
+		WHERE er.active = 1 AND c.code = ?`, record.Code).Scan(&used)
+		if err != nil {
+			return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if used > 0 {
+			report.AddError(record.Ref, "active", "currency_in_use", "currency is used by active exchange rates")
+		}
+	}
+
+	if len(report.Errors) > 0 || dryRun {
+		report.RateChanges = nil
+		return report, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return report, nil
+}
+
+func importCurrency(ctx context.Context, tx *sql.Tx, record models.CurrencyRecord, now time.Time) (models.ImportAction, error) {
+	c, err := currencyByCode(ctx, tx, record.Code)
+	if err == sql.ErrNoRows {
+		created := models.Currency{Name: record.Name, Code: record.Code, Sign: record.Sign, Active: record.Active == nil || *record.Active, Version: 1}
+		err := tx.QueryRowContext(ctx, "INSERT INTO Currencies (full_name, code, sign, active) VALUES (?, ?, ?, ?) RETURNING ID",
+			created.Name, created.Code, created.Sign, created.Active).Scan(&created.ID)
+		if err != nil {
+			return "", err
+		}
+		if err := addAudit(ctx, tx, models.AuditCurrency, created.Code, models.AuditCreate, nil, created, now); err != nil {
+			return "", err
+		}
+		return models.ImportCreated, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	active := c.Active
+	if record.Active != nil {
+		active = *record.Active
+	}
+	if c.Name == record.Name && c.Sign == record.Sign && c.Active == active {
+		return models.ImportUnchanged, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Currencies SET full_name = ?, sign = ?, active = ?, version = version + 1 WHERE ID = ?",
+		record.Name, record.Sign, active, c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	// the rates embed their currencies, a renamed one changes them too
+	_, err = tx.ExecContext(ctx, "UPDATE ExchangeRates SET version = version + 1 WHERE base_currency_id = ? OR target_currency_id = ?",
+		c.ID, c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	updated := c
+	updated.Name, updated.Sign, updated.Active = record.Name, record.Sign, active
+	updated.Version++
+	if err := addAudit(ctx, tx, models.AuditCurrency, c.Code, models.AuditUpdate, c, updated, now); err != nil {
+		return "", err
+	}
+	return models.ImportUpdated, nil
+}
+
+func importExchangeRate(ctx context.Context, tx *sql.Tx, record models.RateRecord, now time.Time, generation int64, report *models.ImportReport) error {
+	key := record.BaseCurrencyCode + record.TargetCurrencyCode
+
+	rejected := false
+	currencyIDs := make([]int, 2)
+	for i, field := range []struct{ name, code string }{
+		{"baseCurrencyCode", record.BaseCurrencyCode},
+		{"targetCurrencyCode", record.TargetCurrencyCode},
+	} {
+		var active bool
+		err := tx.QueryRowContext(ctx, "SELECT ID, active FROM Currencies WHERE code = ?", field.code).
+			Scan(&currencyIDs[i], &active)
+		if err == sql.ErrNoRows {
+			report.AddError(record.Ref, field.name, "currency_not_found", fmt.Sprintf("currency %s not found", field.code))
+			rejected = true
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !active {
+			report.AddError(record.Ref, field.name, "currency_inactive", fmt.Sprintf("currency %s is inactive", field.code))
+			rejected = true
+		}
+	}
+	if rejected {
+		return nil
+	}
+
+	var id int
+	var rate float64
+	var active bool
+	var version int64
+	err := tx.QueryRowContext(ctx,
+		"SELECT ID, rate, active, version FROM ExchangeRates WHERE base_currency_id = ? AND target_currency_id = ?",
+		currencyIDs[0], currencyIDs[1]).Scan(&id, &rate, &active, &version)
+
+	var previous *models.ExchangeRate
+	action := models.ImportCreated
+	switch {
+	case err == sql.ErrNoRows:
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO ExchangeRates (base_currency_id, target_currency_id, rate, updated_at, source, generation) VALUES (?, ?, ?, ?, ?, ?) RETURNING ID",
+			currencyIDs[0], currencyIDs[1], record.Rate, now, models.RateSourceImport, generation).Scan(&id)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case active && rate == record.Rate:
+		report.AddRow(record.Ref, models.RecordRate, key, models.ImportUnchanged)
+		return nil
+	case active && record.Version != 0 && record.Version != version:
+		report.AddError(record.Ref, "version", "version_mismatch",
+			fmt.Sprintf("exchange rate %s is at version %d, export it again and retry", key, version))
+		return nil
+	default:
+		if active {
+			current, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.ID = ?", id))
+			if err != nil {
+				return err
+			}
+			previous = &current
+			action = models.ImportUpdated
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE ExchangeRates SET rate = ?, updated_at = ?, source = ?, generation = ?, active = 1, version = version + 1 WHERE ID = ?",
+			record.Rate, now, models.RateSourceImport, generation, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := addRateHistory(ctx, tx, id, record.Rate, now, generation); err != nil {
+		return err
+	}
+
+	current, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.ID = ?", id))
+	if err != nil {
+		return err
+	}
+
+	auditAction, oldValue := models.AuditCreate, any(nil)
+	if previous != nil {
+		auditAction, oldValue = models.AuditUpdate, *previous
+	}
+	if err := addAudit(ctx, tx, models.AuditExchangeRate, key, auditAction, oldValue, current, now); err != nil {
+		return err
+	}
+
+	report.AddRow(record.Ref, models.RecordRate, key, action)
+	report.RateChanges = append(report.RateChanges, models.RateChange{Previous: previous, Current: current})
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"exchanger/internal/models"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 )
@@ -13,12 +15,14 @@ import (
 var (
 	ErrCurrencyNotFound = errors.New("currency not found")
 	ErrCurrencyExists   = errors.New("currency already exists")
+	ErrCurrencyInactive = errors.New("currency is inactive")
+	ErrCurrencyInUse    = errors.New("currency is used by active exchange rates")
 )
 
 func (r *repository) GetAllCurrencies(ctx context.Context) ([]models.Currency, error) {
 	const op = "internal.repository.repository.GetAllCurrencies"
 
-	rows, err := r.conn.QueryContext(ctx, "SELECT ID, code, full_name, sign FROM Currencies")
+	rows, err := r.conn.QueryContext(ctx, "SELECT ID, code, full_name, sign, active, version FROM Currencies WHERE active = 1")
 	if err != nil {
 		return []models.Currency{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -30,7 +34,7 @@ func (r *repository) GetAllCurrencies(ctx context.Context) ([]models.Currency, e
 	for rows.Next() {
 		hasRows = true
 		var c models.Currency
-		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.Sign); err != nil {
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.Sign, &c.Active, &c.Version); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		currencies = append(currencies, c)
@@ -44,29 +48,52 @@ func (r *repository) GetAllCurrencies(ctx context.Context) ([]models.Currency, e
 		return []models.Currency{}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
 	}
 
+	if err := loadBaskets(ctx, r.conn, currencies); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return currencies, nil
 }
 
+func currencyByCode(ctx context.Context, tx *sql.Tx, code string) (models.Currency, error) {
+	var c models.Currency
+	err := tx.QueryRowContext(ctx, "SELECT ID, full_name, code, sign, active, version FROM Currencies WHERE code = ?", code).
+		Scan(&c.ID, &c.Name, &c.Code, &c.Sign, &c.Active, &c.Version)
+	return c, err
+}
+
+// GetCurrencyByCode also returns deactivated currencies, callers check Active
 func (r *repository) GetCurrencyByCode(ctx context.Context, code string) (models.Currency, error) {
 	const op = "internal.repository.repository.GetCurrencyByCode"
 
 	var c models.Currency
-	err := r.conn.QueryRowContext(ctx, "SELECT ID, full_name, code, sign FROM Currencies WHERE code = ?", code).
-		Scan(&c.ID, &c.Name, &c.Code, &c.Sign)
+	err := r.conn.QueryRowContext(ctx, "SELECT ID, full_name, code, sign, active, version FROM Currencies WHERE code = ?", code).
+		Scan(&c.ID, &c.Name, &c.Code, &c.Sign, &c.Active, &c.Version)
 	if err == sql.ErrNoRows {
 		return models.Currency{}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
 	} else if err != nil {
 		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return c, nil
+	currencies := []models.Currency{c}
+	if err := loadBaskets(ctx, r.conn, currencies); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return currencies[0], nil
 }
 
 func (r *repository) AddCurrency(ctx context.Context, currency models.Currency) (models.Currency, error) {
 	const op = "internal.repository.repository.AddCurrency"
 
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
 	var id int
-	err := r.conn.QueryRowContext(ctx, "INSERT INTO Currencies (full_name, code, sign) VALUES (?, ?, ?) RETURNING ID",
+	err = tx.QueryRowContext(ctx, "INSERT INTO Currencies (full_name, code, sign) VALUES (?, ?, ?) RETURNING ID",
 		currency.Name, currency.Code, currency.Sign).Scan(&id)
 	if err != nil {
 		var sqliteErr sqlite3.Error
@@ -79,6 +106,218 @@ func (r *repository) AddCurrency(ctx context.Context, currency models.Currency)
 	}
 
 	currency.ID = id
+	currency.Active = true
+	currency.Version = 1
+
+	if err := addBasket(ctx, tx, id, currency.Basket); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditCurrency, currency.Code, models.AuditCreate, nil, currency, time.Now().UTC()); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
 
 	return currency, nil
 }
+
+// DeactivateCurrency hides a currency from lists and conversions. A currency used by active rates
+// is only deactivated with cascade, which deletes those rates as well and returns their changes.
+func (r *repository) DeactivateCurrency(ctx context.Context, code string, cascade bool) (models.Currency, []models.RateChange, error) {
+	const op = "internal.repository.repository.DeactivateCurrency"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	previous, err := currencyByCode(ctx, tx, code)
+	if err == sql.ErrNoRows {
+		return models.Currency{}, nil, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+	} else if err != nil {
+		return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now().UTC()
+	var changes []models.RateChange
+
+	if cascade {
+		rates, err := queryExchangeRates(ctx, tx, exchangeRateSelect+"WHERE er.active = 1 AND (bc.ID = ? OR tc.ID = ?)", previous.ID, previous.ID)
+		if err != nil {
+			return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE ExchangeRates SET active = 0, version = version + 1 WHERE active = 1 AND (base_currency_id = ? OR target_currency_id = ?)",
+			previous.ID, previous.ID)
+		if err != nil {
+			return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, er := range rates {
+			key := er.BaseCurrency.Code + er.TargetCurrency.Code
+			if err := addAudit(ctx, tx, models.AuditExchangeRate, key, models.AuditDelete, er, nil, now); err != nil {
+				return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+			}
+			changes = append(changes, deletedRate(er))
+		}
+	} else {
+		var used int
+		err = tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM ExchangeRates WHERE active = 1 AND (base_currency_id = ? OR target_currency_id = ?)",
+			previous.ID, previous.ID).Scan(&used)
+		if err != nil {
+			return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if used > 0 {
+			return models.Currency{}, nil, fmt.Errorf("%s: %w", op, ErrCurrencyInUse)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Currencies SET active = 0, version = version + 1 WHERE ID = ?", previous.ID); err != nil {
+		return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	c := previous
+	c.Active = false
+	c.Version++
+
+	if err := addAudit(ctx, tx, models.AuditCurrency, c.Code, models.AuditDeactivate, previous, c, now); err != nil {
+		return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Currency{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, changes, nil
+}
+
+// ActivateCurrency brings back a deactivated currency, its deleted rates have to be created again
+func (r *repository) ActivateCurrency(ctx context.Context, code string) (models.Currency, error) {
+	const op = "internal.repository.repository.ActivateCurrency"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	previous, err := currencyByCode(ctx, tx, code)
+	if err == sql.ErrNoRows {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+	} else if err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Currencies SET active = 1, version = version + 1 WHERE ID = ?", previous.ID); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+	c := previous
+	c.Active = true
+	c.Version++
+
+	if err := addAudit(ctx, tx, models.AuditCurrency, c.Code, models.AuditActivate, previous, c, time.Now().UTC()); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Currency{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, nil
+}
+
+var currencySortColumns = map[string]sortColumn{
+	"id":   {expr: "ID", key: intKey},
+	"code": {expr: "code", key: stringKey},
+	"name": {expr: "full_name", key: stringKey},
+}
+
+func currencySortKey(field string, c models.Currency) any {
+	switch field {
+	case "code":
+		return c.Code
+	case "name":
+		return c.Name
+	default:
+		return c.ID
+	}
+}
+
+// ListCurrencies returns one page of the currencies matching the filter and their total count
+func (r *repository) ListCurrencies(ctx context.Context, filter models.CurrencyFilter, page models.PageRequest) (models.Page[models.Currency], error) {
+	const op = "internal.repository.repository.ListCurrencies"
+
+	var conditions []string
+	var args []any
+	if !filter.IncludeInactive {
+		conditions = append(conditions, "active = 1")
+	}
+	if filter.CodePrefix != "" {
+		conditions = append(conditions, `code LIKE ? ESCAPE '\'`)
+		args = append(args, likePrefix(filter.CodePrefix))
+	}
+
+	orderBy, after, afterArgs, err := keyset(currencySortColumns, "ID", page.Sort, page.Cursor)
+	if err != nil {
+		return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	err = r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM Currencies"+where(conditions), args...).Scan(&total)
+	if err != nil {
+		return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return models.Page[models.Currency]{Items: []models.Currency{}}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+	}
+
+	if after != "" {
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	// one more row tells whether there is a next page
+	query := "SELECT ID, code, full_name, sign, active, version FROM Currencies" + where(conditions) + orderBy + " LIMIT ?"
+	args = append(args, page.Limit+1)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	currencies := []models.Currency{}
+	for rows.Next() {
+		var c models.Currency
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &c.Sign, &c.Active, &c.Version); err != nil {
+			return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		currencies = append(currencies, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := loadBaskets(ctx, r.conn, currencies); err != nil {
+		return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := models.Page[models.Currency]{Items: currencies, Total: total}
+	if len(currencies) > page.Limit {
+		result.Items = currencies[:page.Limit]
+		last := result.Items[page.Limit-1]
+
+		field := strings.TrimPrefix(page.Sort, "-")
+		result.NextCursor, err = encodeCursor(page.Sort, currencySortKey(field, last), last.ID)
+		if err != nil {
+			return models.Page[models.Currency]{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return result, nil
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"exchanger/internal/models"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 )
@@ -13,21 +15,60 @@ import (
 var (
 	ErrExchangeRateNotFound = errors.New("exchange rate not found")
 	ErrExchangeRateExists   = errors.New("exchange rate already exists")
+	ErrVersionMismatch      = errors.New("version does not match")
 )
 
-func (r *repository) GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
-	const op = "internal.repository.repository.GetAllExchangeRates"
-
-	query := `
-	SELECT er.ID, er.rate, 
-		bc.ID, bc.code, bc.full_name, bc.sign,
-		tc.ID, tc.code, tc.full_name, tc.sign 
+// exchangeRateSelect reads the columns scanExchangeRate expects, callers append the WHERE clause
+const exchangeRateSelect = `
+	SELECT er.ID, er.rate, er.updated_at, er.source, er.generation, er.version,
+		bc.ID, bc.code, bc.full_name, bc.sign, bc.active, bc.version,
+		tc.ID, tc.code, tc.full_name, tc.sign, tc.active, tc.version
 	FROM ExchangeRates er
 	JOIN Currencies bc ON er.base_currency_id = bc.ID
 	JOIN Currencies tc ON er.target_currency_id = tc.ID
 	`
 
-	rows, err := r.conn.QueryContext(ctx, query)
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExchangeRate(row scanner) (models.ExchangeRate, error) {
+	var er models.ExchangeRate
+	err := row.Scan(
+		&er.ID, &er.Rate, &er.UpdatedAt, &er.Source, &er.Generation, &er.Version,
+		&er.BaseCurrency.ID, &er.BaseCurrency.Code, &er.BaseCurrency.Name, &er.BaseCurrency.Sign, &er.BaseCurrency.Active, &er.BaseCurrency.Version,
+		&er.TargetCurrency.ID, &er.TargetCurrency.Code, &er.TargetCurrency.Name, &er.TargetCurrency.Sign, &er.TargetCurrency.Active, &er.TargetCurrency.Version)
+	return er, err
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// queryExchangeRates reads the rates of a query made of exchangeRateSelect and a WHERE clause
+func queryExchangeRates(ctx context.Context, q queryer, query string, args ...any) ([]models.ExchangeRate, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		er, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, er)
+	}
+
+	return rates, rows.Err()
+}
+
+func (r *repository) GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	const op = "internal.repository.repository.GetAllExchangeRates"
+
+	rows, err := r.conn.QueryContext(ctx, exchangeRateSelect+"WHERE er.active = 1")
 	if err != nil {
 		return []models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -39,20 +80,11 @@ func (r *repository) GetAllExchangeRates(ctx context.Context) ([]models.Exchange
 	for rows.Next() {
 		hasRows = true
 
-		var er models.ExchangeRate
-		var bc models.Currency
-		var tc models.Currency
-
-		if err := rows.Scan(
-			&er.ID, &er.Rate,
-			&bc.ID, &bc.Code, &bc.Name, &bc.Sign,
-			&tc.ID, &tc.Code, &tc.Name, &tc.Sign); err != nil {
+		er, err := scanExchangeRate(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
-		er.BaseCurrency = bc
-		er.TargetCurrency = tc
-
 		rates = append(rates, er)
 	}
 
@@ -67,36 +99,43 @@ func (r *repository) GetAllExchangeRates(ctx context.Context) ([]models.Exchange
 	return rates, nil
 }
 
-func (r *repository) GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error) {
-	const op = "internal.repository.repository.GetExchangeRate"
+// GetExchangeRatesByCurrencyCodes returns the rates quoted against any of the currencies on either side,
+// an empty slice without an error when there are none
+func (r *repository) GetExchangeRatesByCurrencyCodes(ctx context.Context, codes []string) ([]models.ExchangeRate, error) {
+	const op = "internal.repository.repository.GetExchangeRatesByCurrencyCodes"
 
-	query := `
-	SELECT er.ID, er.rate, 
-		bc.ID, bc.code, bc.full_name, bc.sign,
-		tc.ID, tc.code, tc.full_name, tc.sign 
-	FROM ExchangeRates er
-	JOIN Currencies bc ON er.base_currency_id = bc.ID
-	JOIN Currencies tc ON er.target_currency_id = tc.ID
-	WHERE bc.code = ? AND tc.code = ?
-	`
+	if len(codes) == 0 {
+		return []models.ExchangeRate{}, nil
+	}
 
-	var er models.ExchangeRate
-	var bc models.Currency
-	var tc models.Currency
+	query := exchangeRateSelect +
+		`WHERE er.active = 1 AND (bc.code IN (` + placeholders(len(codes)) + `) OR tc.code IN (` + placeholders(len(codes)) + `))`
+
+	args := make([]any, 0, 2*len(codes))
+	for range 2 {
+		for _, code := range codes {
+			args = append(args, code)
+		}
+	}
+
+	rates, err := queryExchangeRates(ctx, r.conn, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+func (r *repository) GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error) {
+	const op = "internal.repository.repository.GetExchangeRate"
 
-	err := r.conn.QueryRowContext(ctx, query, baseCode, targetCode).Scan(
-		&er.ID, &er.Rate,
-		&bc.ID, &bc.Code, &bc.Name, &bc.Sign,
-		&tc.ID, &tc.Code, &tc.Name, &tc.Sign)
+	er, err := scanExchangeRate(r.conn.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND bc.code = ? AND tc.code = ?", baseCode, targetCode))
 	if err == sql.ErrNoRows {
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, ErrExchangeRateNotFound)
 	} else if err != nil {
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	er.BaseCurrency = bc
-	er.TargetCurrency = tc
-
 	return er, nil
 }
 
@@ -113,13 +152,40 @@ func (r *repository) AddExchangeRate(ctx context.Context, baseCode, targetCode s
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if !baseCurrency.Active || !targetCurrency.Active {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, ErrCurrencyInactive)
+	}
+
+	updatedAt := time.Now().UTC()
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	generation, err := newRateGeneration(ctx, tx, updatedAt)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// a deleted pair is brought back with its history, an active one is left alone and
+	// returns no row
 	var id int
-	err = r.conn.QueryRowContext(
+	var version int64
+	err = tx.QueryRowContext(
 		ctx,
-		"INSERT INTO ExchangeRates (base_currency_id, target_currency_id, rate) VALUES (?, ?, ?) RETURNING ID",
-		baseCurrency.ID, targetCurrency.ID, rate,
-	).Scan(&id)
-	if err != nil {
+		`INSERT INTO ExchangeRates (base_currency_id, target_currency_id, rate, updated_at, source, generation) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (base_currency_id, target_currency_id) DO UPDATE
+		SET rate = excluded.rate, updated_at = excluded.updated_at, source = excluded.source, generation = excluded.generation,
+			active = 1, version = version + 1
+		WHERE active = 0
+		RETURNING ID, version`,
+		baseCurrency.ID, targetCurrency.ID, rate, updatedAt, models.RateSourceAPI, generation,
+	).Scan(&id, &version)
+	if err == sql.ErrNoRows {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, ErrExchangeRateExists)
+	} else if err != nil {
 		var sqliteErr sqlite3.Error
 
 		if errors.As(err, &sqliteErr) {
@@ -131,59 +197,381 @@ func (r *repository) AddExchangeRate(ctx context.Context, baseCode, targetCode s
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return models.ExchangeRate{
+	if err := addRateHistory(ctx, tx, id, rate, updatedAt, generation); err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	created := models.ExchangeRate{
 		ID:             id,
 		BaseCurrency:   baseCurrency,
 		TargetCurrency: targetCurrency,
 		Rate:           rate,
-	}, nil
+		UpdatedAt:      updatedAt,
+		Source:         models.RateSourceAPI,
+		Generation:     generation,
+		Version:        version,
+	}
+	if err := addAudit(ctx, tx, models.AuditExchangeRate, baseCode+targetCode, models.AuditCreate, nil, created, updatedAt); err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return created, nil
 }
 
-func (r *repository) UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error) {
+// UpdateExchangeRate sets the rate of an existing pair. Unless version is 0 the pair must still be
+// at that version, otherwise nothing is written and ErrVersionMismatch is returned.
+func (r *repository) UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, version int64) (models.ExchangeRate, error) {
 	const op = "internal.repository.repository.UpdateExchangeRate"
 
-	baseCurrency, err := r.GetCurrencyByCode(ctx, baseCode)
+	tx, err := r.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	targetCurrency, err := r.GetCurrencyByCode(ctx, targetCode)
+	change, err := updateExchangeRate(ctx, tx, baseCode, targetCode, rate, version, time.Now().UTC())
 	if err != nil {
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	result, err := r.conn.ExecContext(
-		ctx,
-		"UPDATE ExchangeRates SET rate = ? WHERE base_currency_id = ? AND target_currency_id = ?",
-		rate, baseCurrency.ID, targetCurrency.ID,
-	)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return change.Current, nil
+}
+
+// updateExchangeRate sets the rate of an existing pair as a generation of its own in tx, it is
+// shared by direct updates and approved proposals
+func updateExchangeRate(ctx context.Context, tx *sql.Tx, baseCode, targetCode string, rate float64, version int64, updatedAt time.Time) (models.RateChange, error) {
+	baseCurrency, err := currencyByCode(ctx, tx, baseCode)
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, ErrCurrencyNotFound
+	} else if err != nil {
+		return models.RateChange{}, err
+	}
+
+	targetCurrency, err := currencyByCode(ctx, tx, targetCode)
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, ErrCurrencyNotFound
+	} else if err != nil {
+		return models.RateChange{}, err
+	}
+
+	generation, err := newRateGeneration(ctx, tx, updatedAt)
 	if err != nil {
-		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+		return models.RateChange{}, err
 	}
 
-	if rowsAffected == 0 {
-		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, ErrExchangeRateNotFound)
+	previous, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND bc.ID = ? AND tc.ID = ?",
+		baseCurrency.ID, targetCurrency.ID))
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, ErrExchangeRateNotFound
+	} else if err != nil {
+		return models.RateChange{}, err
 	}
 
 	var id int
-	err = r.conn.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
-		"SELECT ID FROM ExchangeRates WHERE base_currency_id = ? AND target_currency_id = ?",
-		baseCurrency.ID, targetCurrency.ID,
-	).Scan(&id)
-	if err != nil {
-		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+		`UPDATE ExchangeRates SET rate = ?, updated_at = ?, source = ?, generation = ?, version = version + 1
+		WHERE ID = ? AND (? = 0 OR version = ?)
+		RETURNING ID, version`,
+		rate, updatedAt, models.RateSourceAPI, generation, previous.ID, version, version,
+	).Scan(&id, &version)
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, ErrVersionMismatch
+	} else if err != nil {
+		return models.RateChange{}, err
+	}
+
+	if err := addRateHistory(ctx, tx, id, rate, updatedAt, generation); err != nil {
+		return models.RateChange{}, err
 	}
 
-	return models.ExchangeRate{
+	updated := models.ExchangeRate{
 		ID:             id,
 		BaseCurrency:   baseCurrency,
 		TargetCurrency: targetCurrency,
 		Rate:           rate,
-	}, nil
+		UpdatedAt:      updatedAt,
+		Source:         models.RateSourceAPI,
+		Generation:     generation,
+		Version:        version,
+	}
+	if err := addAudit(ctx, tx, models.AuditExchangeRate, baseCode+targetCode, models.AuditUpdate, previous, updated, updatedAt); err != nil {
+		return models.RateChange{}, err
+	}
+
+	return models.RateChange{Previous: &previous, Current: updated}, nil
+}
+
+// PairsError names the pairs a write of several rates failed for, it unwraps to the reason
+type PairsError struct {
+	Err   error
+	Pairs []string
+}
+
+func (e *PairsError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(e.Pairs, ", "))
+}
+
+func (e *PairsError) Unwrap() error {
+	return e.Err
+}
+
+// UpdateExchangeRates sets the rates of existing pairs in one transaction, as one generation.
+// When a pair has no active rate, or is no longer at the version of its update, nothing is
+// written and the error names every such pair.
+func (r *repository) UpdateExchangeRates(ctx context.Context, updates []models.RateUpdate) ([]models.RateChange, error) {
+	const op = "internal.repository.repository.UpdateExchangeRates"
+
+	updatedAt := time.Now().UTC()
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	generation, err := newRateGeneration(ctx, tx, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var missing, changed []string
+	changes := make([]models.RateChange, 0, len(updates))
+	for _, update := range updates {
+		previous, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND bc.code = ? AND tc.code = ?",
+			update.BaseCurrencyCode, update.TargetCurrencyCode))
+		if err == sql.ErrNoRows {
+			missing = append(missing, update.BaseCurrencyCode+update.TargetCurrencyCode)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if update.Version != 0 && update.Version != previous.Version {
+			changed = append(changed, update.BaseCurrencyCode+update.TargetCurrencyCode)
+			continue
+		}
+		if len(missing) > 0 || len(changed) > 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE ExchangeRates SET rate = ?, updated_at = ?, source = ?, generation = ?, version = version + 1 WHERE ID = ?",
+			update.Rate, updatedAt, models.RateSourceAPI, generation, previous.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := addRateHistory(ctx, tx, previous.ID, update.Rate, updatedAt, generation); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		current := previous
+		current.Rate = update.Rate
+		current.UpdatedAt = updatedAt
+		current.Source = models.RateSourceAPI
+		current.Generation = generation
+		current.Version++
+		key := update.BaseCurrencyCode + update.TargetCurrencyCode
+		if err := addAudit(ctx, tx, models.AuditExchangeRate, key, models.AuditUpdate, previous, current, updatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		changes = append(changes, models.RateChange{Previous: &previous, Current: current})
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s: %w", op, &PairsError{Err: ErrExchangeRateNotFound, Pairs: missing})
+	}
+	if len(changed) > 0 {
+		return nil, fmt.Errorf("%s: %w", op, &PairsError{Err: ErrVersionMismatch, Pairs: changed})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changes, nil
+}
+
+// GetExchangeRatesByPairs returns the active rates of the pairs that have one. They are read
+// with a single query, so rates updated together are either all old or all new.
+func (r *repository) GetExchangeRatesByPairs(ctx context.Context, pairs [][2]string) ([]models.ExchangeRate, error) {
+	const op = "internal.repository.repository.GetExchangeRatesByPairs"
+
+	if len(pairs) == 0 {
+		return []models.ExchangeRate{}, nil
+	}
+
+	values := make([]string, len(pairs))
+	args := make([]any, 0, 2*len(pairs))
+	for i, pair := range pairs {
+		values[i] = "(?, ?)"
+		args = append(args, pair[0], pair[1])
+	}
+
+	query := exchangeRateSelect + "WHERE er.active = 1 AND (bc.code, tc.code) IN (VALUES " + strings.Join(values, ", ") + ")"
+
+	rates, err := queryExchangeRates(ctx, r.conn, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+// DeleteExchangeRate deactivates the rate of a pair, which takes it out of lists and
+// conversions while its history is kept, and returns the change that deleted it
+func (r *repository) DeleteExchangeRate(ctx context.Context, baseCode, targetCode string) (models.RateChange, error) {
+	const op = "internal.repository.repository.DeleteExchangeRate"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	previous, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND bc.code = ? AND tc.code = ?",
+		baseCode, targetCode))
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, ErrExchangeRateNotFound)
+	} else if err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE ExchangeRates SET active = 0, version = version + 1 WHERE ID = ?", previous.ID); err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditExchangeRate, baseCode+targetCode, models.AuditDelete, previous, nil, time.Now().UTC()); err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deletedRate(previous), nil
+}
+
+// deletedRate is the change that deletes the rate, its version is the one the delete bumped it to
+func deletedRate(rate models.ExchangeRate) models.RateChange {
+	deleted := rate
+	deleted.Version++
+	return models.RateChange{Previous: &rate, Current: deleted, Deleted: true}
+}
+
+var exchangeRateSortColumns = map[string]sortColumn{
+	"id":        {expr: "er.ID", key: intKey},
+	"base":      {expr: "bc.code", key: stringKey},
+	"target":    {expr: "tc.code", key: stringKey},
+	"rate":      {expr: "er.rate", key: floatKey},
+	"updatedAt": {expr: "er.updated_at", key: timeKey},
+}
+
+func exchangeRateSortKey(field string, er models.ExchangeRate) any {
+	switch field {
+	case "base":
+		return er.BaseCurrency.Code
+	case "target":
+		return er.TargetCurrency.Code
+	case "rate":
+		return er.Rate
+	case "updatedAt":
+		return er.UpdatedAt
+	default:
+		return er.ID
+	}
+}
+
+// ListExchangeRates returns one page of the rates matching the filter and their total count
+func (r *repository) ListExchangeRates(ctx context.Context, filter models.ExchangeRateFilter, page models.PageRequest) (models.Page[models.ExchangeRate], error) {
+	const op = "internal.repository.repository.ListExchangeRates"
+
+	conditions := []string{"er.active = 1"}
+	var args []any
+	if filter.BaseCode != "" {
+		conditions = append(conditions, "bc.code = ?")
+		args = append(args, filter.BaseCode)
+	}
+	if filter.TargetCode != "" {
+		conditions = append(conditions, "tc.code = ?")
+		args = append(args, filter.TargetCode)
+	}
+	if filter.CodePrefix != "" {
+		conditions = append(conditions, `(bc.code LIKE ? ESCAPE '\' OR tc.code LIKE ? ESCAPE '\')`)
+		args = append(args, likePrefix(filter.CodePrefix), likePrefix(filter.CodePrefix))
+	}
+	if !filter.UpdatedSince.IsZero() {
+		conditions = append(conditions, "er.updated_at >= ?")
+		args = append(args, filter.UpdatedSince.UTC())
+	}
+	if filter.Source != "" {
+		conditions = append(conditions, "er.source = ?")
+		args = append(args, filter.Source)
+	}
+
+	orderBy, after, afterArgs, err := keyset(exchangeRateSortColumns, "er.ID", page.Sort, page.Cursor)
+	if err != nil {
+		return models.Page[models.ExchangeRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	countQuery := `
+	SELECT COUNT(*)
+	FROM ExchangeRates er
+	JOIN Currencies bc ON er.base_currency_id = bc.ID
+	JOIN Currencies tc ON er.target_currency_id = tc.ID` + where(conditions)
+	if err := r.conn.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return models.Page[models.ExchangeRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return models.Page[models.ExchangeRate]{Items: []models.ExchangeRate{}}, fmt.Errorf("%s: %w", op, ErrExchangeRateNotFound)
+	}
+
+	if after != "" {
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	// one more row tells whether there is a next page
+	query := exchangeRateSelect + where(conditions) + orderBy + " LIMIT ?"
+	args = append(args, page.Limit+1)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.Page[models.ExchangeRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		er, err := scanExchangeRate(rows)
+		if err != nil {
+			return models.Page[models.ExchangeRate]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		rates = append(rates, er)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.ExchangeRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := models.Page[models.ExchangeRate]{Items: rates, Total: total}
+	if len(rates) > page.Limit {
+		result.Items = rates[:page.Limit]
+		last := result.Items[page.Limit-1]
+
+		field := strings.TrimPrefix(page.Sort, "-")
+		result.NextCursor, err = encodeCursor(page.Sort, exchangeRateSortKey(field, last), last.ID)
+		if err != nil {
+			return models.Page[models.ExchangeRate]{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return result, nil
 }
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeactivateCurrencyCascade(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "RUBUSD", 0.011)
+	addRate(t, repo, "EURRUB", 100)
+
+	if _, _, err := repo.DeactivateCurrency(ctx, "USD", false); !errors.Is(err, ErrCurrencyInUse) {
+		t.Fatalf("DeactivateCurrency without cascade: err = %v, want ErrCurrencyInUse", err)
+	}
+	if _, err := repo.GetExchangeRate(ctx, "USD", "EUR"); err != nil {
+		t.Fatalf("a refused deactivation deleted USDEUR: %v", err)
+	}
+
+	usd, changes, err := repo.DeactivateCurrency(ctx, "USD", true)
+	if err != nil {
+		t.Fatalf("DeactivateCurrency: %v", err)
+	}
+	if usd.Active || len(changes) != 2 {
+		t.Fatalf("DeactivateCurrency = %+v, %d changes, want an inactive currency and 2 deleted rates", usd, len(changes))
+	}
+	for _, change := range changes {
+		if !change.Deleted || change.Current.Version != change.Previous.Version+1 {
+			t.Errorf("change = %+v, want a deletion that bumps the version", change)
+		}
+	}
+	for _, pair := range []string{"USDEUR", "RUBUSD"} {
+		if _, err := repo.GetExchangeRate(ctx, pair[:3], pair[3:]); !errors.Is(err, ErrExchangeRateNotFound) {
+			t.Errorf("GetExchangeRate(%s) after the cascade: err = %v, want ErrExchangeRateNotFound", pair, err)
+		}
+	}
+	if _, err := repo.GetExchangeRate(ctx, "EUR", "RUB"); err != nil {
+		t.Errorf("the cascade deleted EURRUB: %v", err)
+	}
+	if _, err := repo.AddExchangeRate(ctx, "USD", "EUR", 0.95); !errors.Is(err, ErrCurrencyInactive) {
+		t.Errorf("AddExchangeRate of an inactive currency: err = %v, want ErrCurrencyInactive", err)
+	}
+
+	// activation does not bring the rates back, creating the pair again does with its history
+	if usd, err = repo.ActivateCurrency(ctx, "USD"); err != nil || !usd.Active {
+		t.Fatalf("ActivateCurrency = %+v, %v", usd, err)
+	}
+	if _, err := repo.GetExchangeRate(ctx, "USD", "EUR"); !errors.Is(err, ErrExchangeRateNotFound) {
+		t.Errorf("GetExchangeRate after the activation: err = %v, want ErrExchangeRateNotFound", err)
+	}
+
+	recreated := addRate(t, repo, "USDEUR", 0.95)
+	if recreated.ID != usdeur.ID || recreated.Version != usdeur.Version+2 {
+		t.Errorf("recreated USDEUR = %+v, want ID %d at version %d", recreated, usdeur.ID, usdeur.Version+2)
+	}
+	history, err := repo.GetExchangeRateHistory(ctx, []int{usdeur.ID}, time.Time{}, 10)
+	if err != nil || len(history) != 2 {
+		t.Errorf("history = %+v, %v, want the rates before and after the deletion", history, err)
+	}
+}
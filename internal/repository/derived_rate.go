@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"exchanger/internal/models"
+	"fmt"
+	"time"
+)
+
+// SetDerivedRates makes rates the rates derived from others by source, e.g. the ones of synthetic
+// currencies, written as one generation and creating the pairs that have none. A rate whose value
+// did not change but was derived from fresher rates only has its updated_at moved, without history
+// or audit entry, and rates that did not change at all are left alone and have no RateChange.
+// The active rates of source that are not among rates can no longer be derived and are deleted.
+func (r *repository) SetDerivedRates(ctx context.Context, source string, rates []models.RateUpdate) ([]models.RateChange, error) {
+	const op = "internal.repository.repository.SetDerivedRates"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var generation int64
+
+	changes := []models.RateChange{}
+	derived := make(map[string]bool, len(rates))
+	for _, u := range rates {
+		derived[u.BaseCurrencyCode+u.TargetCurrencyCode] = true
+
+		baseCurrency, err := currencyByCode(ctx, tx, u.BaseCurrencyCode)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		targetCurrency, err := currencyByCode(ctx, tx, u.TargetCurrencyCode)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		updatedAt := now
+		if !u.UpdatedAt.IsZero() {
+			updatedAt = u.UpdatedAt.UTC()
+		}
+
+		var previous *models.ExchangeRate
+		current, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND bc.ID = ? AND tc.ID = ?",
+			baseCurrency.ID, targetCurrency.ID))
+		if err == nil {
+			if current.Rate == u.Rate && current.Source == source && !updatedAt.After(current.UpdatedAt) {
+				continue
+			}
+			previous = &current
+		} else if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if generation == 0 {
+			if generation, err = newRateGeneration(ctx, tx, now); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if previous != nil && previous.Rate == u.Rate && previous.Source == source {
+			refreshed := *previous
+			refreshed.UpdatedAt = updatedAt
+			refreshed.Generation = generation
+			refreshed.Version++
+			_, err := tx.ExecContext(ctx, "UPDATE ExchangeRates SET updated_at = ?, generation = ?, version = version + 1 WHERE ID = ?",
+				updatedAt, generation, previous.ID)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			changes = append(changes, models.RateChange{Previous: previous, Current: refreshed})
+			continue
+		}
+
+		var id int
+		var version int64
+		err = tx.QueryRowContext(
+			ctx,
+			`INSERT INTO ExchangeRates (base_currency_id, target_currency_id, rate, updated_at, source, generation) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (base_currency_id, target_currency_id) DO UPDATE
+			SET rate = excluded.rate, updated_at = excluded.updated_at, source = excluded.source, generation = excluded.generation,
+				active = 1, version = version + 1
+			RETURNING ID, version`,
+			baseCurrency.ID, targetCurrency.ID, u.Rate, updatedAt, source, generation,
+		).Scan(&id, &version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := addRateHistory(ctx, tx, id, u.Rate, now, generation); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		updated := models.ExchangeRate{
+			ID:             id,
+			BaseCurrency:   baseCurrency,
+			TargetCurrency: targetCurrency,
+			Rate:           u.Rate,
+			UpdatedAt:      updatedAt,
+			Source:         source,
+			Generation:     generation,
+			Version:        version,
+		}
+
+		action, oldValue := models.AuditCreate, any(nil)
+		if previous != nil {
+			action, oldValue = models.AuditUpdate, *previous
+		}
+		if err := addAudit(ctx, tx, models.AuditExchangeRate, u.BaseCurrencyCode+u.TargetCurrencyCode, action, oldValue, updated, now); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		changes = append(changes, models.RateChange{Previous: previous, Current: updated})
+	}
+
+	underived, err := queryExchangeRates(ctx, tx, exchangeRateSelect+"WHERE er.active = 1 AND er.source = ?", source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, er := range underived {
+		key := er.BaseCurrency.Code + er.TargetCurrency.Code
+		if derived[key] {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE ExchangeRates SET active = 0, version = version + 1 WHERE ID = ?", er.ID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := addAudit(ctx, tx, models.AuditExchangeRate, key, models.AuditDelete, er, nil, now); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		changes = append(changes, deletedRate(er))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+)
+
+// sortColumn is a field a list can be sorted by
+type sortColumn struct {
+	expr string
+	// key returns a pointer the cursor value of the column is decoded into
+	key func() any
+}
+
+func stringKey() any { return new(string) }
+func floatKey() any  { return new(float64) }
+func intKey() any    { return new(int) }
+func timeKey() any   { return new(time.Time) }
+
+// cursor points after the last row of a page, it is only valid for the sort it was made with
+type cursor struct {
+	Sort string          `json:"s"`
+	Key  json.RawMessage `json:"k"`
+	ID   int             `json:"id"`
+}
+
+func encodeCursor(sort string, key any, id int) (string, error) {
+	rawKey, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(cursor{Sort: sort, Key: rawKey, ID: id})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// keyset builds the ORDER BY clause of a sorted list and, for a cursor, the condition that
+// skips the rows up to it. Rows are ordered by the sort column and then by idExpr so pages
+// never overlap, even when many rows share the sort value.
+func keyset(columns map[string]sortColumn, idExpr, sort, encodedCursor string) (orderBy, after string, args []any, err error) {
+	field, desc := strings.CutPrefix(sort, "-")
+	if field == "" {
+		field = "id"
+	}
+
+	column, ok := columns[field]
+	if !ok {
+		return "", "", nil, fmt.Errorf("%w: %q", ErrInvalidSort, field)
+	}
+
+	direction, cmp := "ASC", ">"
+	if desc {
+		direction, cmp = "DESC", "<"
+	}
+	orderBy = fmt.Sprintf(" ORDER BY %s %s, %s %s", column.expr, direction, idExpr, direction)
+
+	if encodedCursor == "" {
+		return orderBy, "", nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+	if err != nil {
+		return "", "", nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return "", "", nil, ErrInvalidCursor
+	}
+	key := column.key()
+	if err := json.Unmarshal(c.Key, key); err != nil {
+		return "", "", nil, ErrInvalidCursor
+	}
+	after = fmt.Sprintf("(%s, %s) %s (?, ?)", column.expr, idExpr, cmp)
+	return orderBy, after, []any{reflect.ValueOf(key).Elem().Interface(), c.ID}, nil
+}
+
+// likePrefix returns a LIKE pattern matching values that start with prefix, use it with ESCAPE '\'
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(prefix) + "%"
+}
+
+// where joins conditions with AND into a WHERE clause, an empty string when there are none
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
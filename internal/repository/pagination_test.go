@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"slices"
+	"testing"
+)
+
+// allPages follows the cursors of list from the first page to the last and returns the key of every row
+func allPages[T, K any](t *testing.T, list func(page models.PageRequest) (models.Page[T], error), sort string, limit int, key func(T) K) []K {
+	t.Helper()
+	var keys []K
+	page := models.PageRequest{Sort: sort, Limit: limit}
+	for {
+		result, err := list(page)
+		if err != nil {
+			t.Fatalf("page after %q: %v", page.Cursor, err)
+		}
+		if len(result.Items) > limit {
+			t.Fatalf("page has %d items, want at most %d", len(result.Items), limit)
+		}
+		for _, item := range result.Items {
+			keys = append(keys, key(item))
+		}
+		if result.NextCursor == "" {
+			return keys
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+func TestListCurrenciesPages(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB", "GBP", "JPY")
+
+	list := func(page models.PageRequest) (models.Page[models.Currency], error) {
+		return repo.ListCurrencies(ctx, models.CurrencyFilter{}, page)
+	}
+	code := func(c models.Currency) string { return c.Code }
+
+	got := allPages(t, list, "code", 2, code)
+	if want := []string{"EUR", "GBP", "JPY", "RUB", "USD"}; !slices.Equal(got, want) {
+		t.Errorf("codes = %v, want %v", got, want)
+	}
+	got = allPages(t, list, "-code", 2, code)
+	if want := []string{"USD", "RUB", "JPY", "GBP", "EUR"}; !slices.Equal(got, want) {
+		t.Errorf("codes in reverse = %v, want %v", got, want)
+	}
+}
+
+func TestListExchangeRatesPagesWithEqualKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB", "GBP")
+	// every rate but one is the same, so only the ID tells the rows apart
+	var want []int
+	for _, pair := range []string{"USDEUR", "USDRUB", "USDGBP", "EURRUB", "EURGBP"} {
+		want = append(want, addRate(t, repo, pair, 1).ID)
+	}
+	low := addRate(t, repo, "RUBGBP", 0.5)
+
+	list := func(page models.PageRequest) (models.Page[models.ExchangeRate], error) {
+		return repo.ListExchangeRates(ctx, models.ExchangeRateFilter{}, page)
+	}
+	id := func(er models.ExchangeRate) int { return er.ID }
+
+	for _, limit := range []int{1, 2, 4} {
+		got := allPages(t, list, "rate", limit, id)
+		if wantAsc := append([]int{low.ID}, want...); !slices.Equal(got, wantAsc) {
+			t.Errorf("rate, limit %d: IDs = %v, want %v", limit, got, wantAsc)
+		}
+
+		got = allPages(t, list, "-rate", limit, id)
+		wantDesc := slices.Clone(want)
+		slices.Reverse(wantDesc)
+		if wantDesc = append(wantDesc, low.ID); !slices.Equal(got, wantDesc) {
+			t.Errorf("-rate, limit %d: IDs = %v, want %v", limit, got, wantDesc)
+		}
+	}
+}
+
+func TestListCursorErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+
+	first, err := repo.ListCurrencies(ctx, models.CurrencyFilter{}, models.PageRequest{Sort: "code", Limit: 1})
+	if err != nil || first.NextCursor == "" {
+		t.Fatalf("first page = %+v, %v, want a next cursor", first, err)
+	}
+
+	tests := []struct {
+		name    string
+		page    models.PageRequest
+		wantErr error
+	}{
+		{"unknown sort", models.PageRequest{Sort: "sign", Limit: 1}, ErrInvalidSort},
+		{"garbage cursor", models.PageRequest{Sort: "code", Cursor: "not a cursor", Limit: 1}, ErrInvalidCursor},
+		{"cursor of another sort", models.PageRequest{Sort: "-code", Cursor: first.NextCursor, Limit: 1}, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.ListCurrencies(ctx, models.CurrencyFilter{}, tt.page); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := repo.ListExchangeRates(ctx, models.ExchangeRateFilter{}, models.PageRequest{Sort: "code", Limit: 1}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("rates sorted by code: err = %v, want ErrInvalidSort", err)
+	}
+}
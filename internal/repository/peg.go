@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrPegNotFound = errors.New("peg not found")
+	ErrPegExists   = errors.New("currency is already pegged")
+	// ErrPegConflict is a peg of a synthetic currency, to one, to a pegged currency or of an anchor
+	ErrPegConflict = errors.New("peg conflicts with another peg or basket")
+)
+
+const pegSelect = `
+	SELECT c.code, a.code, p.central_rate, p.band, p.created_by, p.created_at
+	FROM Pegs p
+	JOIN Currencies c ON p.currency_id = c.ID
+	JOIN Currencies a ON p.anchor_currency_id = a.ID
+	`
+
+func scanPeg(row scanner) (models.Peg, error) {
+	var p models.Peg
+	err := row.Scan(&p.CurrencyCode, &p.AnchorCurrencyCode, &p.CentralRate, &p.Band, &p.CreatedBy, &p.CreatedAt)
+	return p, err
+}
+
+func (r *repository) GetAllPegs(ctx context.Context) ([]models.Peg, error) {
+	const op = "internal.repository.repository.GetAllPegs"
+
+	rows, err := r.conn.QueryContext(ctx, pegSelect+"ORDER BY c.code")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	pegs := []models.Peg{}
+	for rows.Next() {
+		p, err := scanPeg(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		pegs = append(pegs, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(pegs) == 0 {
+		return pegs, fmt.Errorf("%s: %w", op, ErrPegNotFound)
+	}
+
+	return pegs, nil
+}
+
+func (r *repository) GetPeg(ctx context.Context, code string) (models.Peg, error) {
+	const op = "internal.repository.repository.GetPeg"
+
+	p, err := scanPeg(r.conn.QueryRowContext(ctx, pegSelect+"WHERE c.code = ?", code))
+	if err == sql.ErrNoRows {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, ErrPegNotFound)
+	} else if err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+// AddPeg pegs an active real currency to another one. Pegs do not chain: an anchor is not pegged
+// itself and a pegged currency is no anchor.
+func (r *repository) AddPeg(ctx context.Context, peg models.Peg) (models.Peg, error) {
+	const op = "internal.repository.repository.AddPeg"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	currency, err := currencyByCode(ctx, tx, peg.CurrencyCode)
+	if err == sql.ErrNoRows {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+	} else if err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	anchor, err := currencyByCode(ctx, tx, peg.AnchorCurrencyCode)
+	if err == sql.ErrNoRows {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+	} else if err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !currency.Active || !anchor.Active {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, ErrCurrencyInactive)
+	}
+
+	var pegged, conflict bool
+	err = tx.QueryRowContext(ctx, `
+	SELECT
+		EXISTS (SELECT 1 FROM Pegs WHERE currency_id = ?),
+		EXISTS (SELECT 1 FROM Pegs WHERE currency_id = ? OR anchor_currency_id = ?)
+			OR EXISTS (SELECT 1 FROM CurrencyBaskets WHERE currency_id IN (?, ?))
+	`, currency.ID, anchor.ID, currency.ID, currency.ID, anchor.ID).Scan(&pegged, &conflict)
+	if err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if pegged {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, ErrPegExists)
+	}
+	if conflict {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, ErrPegConflict)
+	}
+
+	peg.CreatedAt = peg.CreatedAt.UTC()
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO Pegs (currency_id, anchor_currency_id, central_rate, band, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		currency.ID, anchor.ID, peg.CentralRate, peg.Band, peg.CreatedBy, peg.CreatedAt)
+	if err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditPeg, peg.CurrencyCode, models.AuditCreate, nil, peg, peg.CreatedAt); err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Peg{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return peg, nil
+}
+
+// DeletePeg drops the peg of a currency, the rates it derived are left to the next derivation
+func (r *repository) DeletePeg(ctx context.Context, code string) error {
+	const op = "internal.repository.repository.DeletePeg"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	previous, err := scanPeg(tx.QueryRowContext(ctx, pegSelect+"WHERE c.code = ?", code))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", op, ErrPegNotFound)
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Pegs WHERE currency_id = (SELECT ID FROM Currencies WHERE code = ?)", code); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := addAudit(ctx, tx, models.AuditPeg, code, models.AuditDelete, previous, nil, time.Now().UTC()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"exchanger/internal/models"
+	"fmt"
+	"time"
+)
+
+// GetRateCandles returns the candles of a pair interval long from from until to, the history of a
+// deleted pair stays readable. Bucketing and the extremes are left to SQLite, which reads the
+// history of the range once through its index. Intervals before the first recorded rate have no
+// candle.
+func (r *repository) GetRateCandles(ctx context.Context, baseCode, targetCode string, from, to time.Time, interval time.Duration) ([]models.RateCandle, error) {
+	const op = "internal.repository.repository.GetRateCandles"
+
+	var id int
+	err := r.conn.QueryRowContext(ctx, `
+	SELECT er.ID FROM ExchangeRates er
+	JOIN Currencies bc ON er.base_currency_id = bc.ID
+	JOIN Currencies tc ON er.target_currency_id = tc.ID
+	WHERE bc.code = ? AND tc.code = ?
+	`, baseCode, targetCode).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", op, ErrExchangeRateNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the rate in effect at from opens the first candle
+	var open sql.NullFloat64
+	err = r.conn.QueryRowContext(ctx, `
+	SELECT rate FROM ExchangeRateHistory
+	WHERE exchange_rate_id = ? AND recorded_at < ?
+	ORDER BY recorded_at DESC, ID DESC
+	LIMIT 1
+	`, id, from.UTC()).Scan(&open)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+	SELECT bucket, MIN(rate), MAX(rate), COUNT(*), first, last FROM (
+		SELECT bucket, rate,
+			FIRST_VALUE(rate) OVER w AS first,
+			LAST_VALUE(rate) OVER w AS last
+		FROM (
+			SELECT ID, rate, recorded_at, (unixepoch(recorded_at) - ?) / ? AS bucket
+			FROM ExchangeRateHistory
+			WHERE exchange_rate_id = ? AND recorded_at >= ? AND recorded_at < ?
+		)
+		WINDOW w AS (PARTITION BY bucket ORDER BY recorded_at, ID ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)
+	)
+	GROUP BY bucket
+	ORDER BY bucket
+	`
+
+	step := int64(interval / time.Second)
+	rows, err := r.conn.QueryContext(ctx, query, from.Unix(), step, id, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	type bucket struct {
+		low, high, first, last float64
+		updates                int
+	}
+	buckets := make(map[int64]bucket)
+	for rows.Next() {
+		var n int64
+		var b bucket
+		if err := rows.Scan(&n, &b.low, &b.high, &b.updates, &b.first, &b.last); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		buckets[n] = b
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	candles := []models.RateCandle{}
+	for n, start := int64(0), from.UTC(); start.Before(to); n, start = n+1, start.Add(interval) {
+		b, updated := buckets[n]
+		if !updated && !open.Valid {
+			continue
+		}
+
+		candle := models.RateCandle{Start: start, Open: open.Float64, High: open.Float64, Low: open.Float64, Close: open.Float64}
+		if updated {
+			if !open.Valid {
+				candle.Open, candle.High, candle.Low = b.first, b.first, b.first
+			}
+			candle.High = max(candle.High, b.high)
+			candle.Low = min(candle.Low, b.low)
+			candle.Close = b.last
+			candle.Updates = b.updates
+		}
+		candles = append(candles, candle)
+
+		open = sql.NullFloat64{Float64: candle.Close, Valid: true}
+	}
+
+	return candles, nil
+}
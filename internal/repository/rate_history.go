@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"exchanger/internal/models"
+	"fmt"
+	"time"
+)
+
+func addRateHistory(ctx context.Context, tx *sql.Tx, exchangeRateID int, rate float64, recordedAt time.Time, generation int64) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO ExchangeRateHistory (exchange_rate_id, rate, recorded_at, generation) VALUES (?, ?, ?, ?)",
+		exchangeRateID, rate, recordedAt, generation,
+	)
+	return err
+}
+
+// newRateGeneration starts the generation the rates written by tx belong to
+func newRateGeneration(ctx context.Context, tx *sql.Tx, createdAt time.Time) (int64, error) {
+	var generation int64
+	err := tx.QueryRowContext(ctx, "INSERT INTO RateGenerations (created_at) VALUES (?) RETURNING ID", createdAt).Scan(&generation)
+	return generation, err
+}
+
+// GetExchangeRateAt returns the value the pair had at the given moment
+func (r *repository) GetExchangeRateAt(ctx context.Context, baseCode, targetCode string, at time.Time) (models.ExchangeRate, error) {
+	const op = "internal.repository.repository.GetExchangeRateAt"
+
+	query := `
+	SELECT er.ID, h.rate, h.recorded_at, er.source, h.generation, er.version,
+		bc.ID, bc.code, bc.full_name, bc.sign, bc.active, bc.version,
+		tc.ID, tc.code, tc.full_name, tc.sign, tc.active, tc.version
+	FROM ExchangeRateHistory h
+	JOIN ExchangeRates er ON h.exchange_rate_id = er.ID
+	JOIN Currencies bc ON er.base_currency_id = bc.ID
+	JOIN Currencies tc ON er.target_currency_id = tc.ID
+	WHERE bc.code = ? AND tc.code = ? AND h.recorded_at <= ?
+	ORDER BY h.recorded_at DESC, h.ID DESC
+	LIMIT 1
+	`
+
+	er, err := scanExchangeRate(r.conn.QueryRowContext(ctx, query, baseCode, targetCode, at.UTC()))
+	if err == sql.ErrNoRows {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, ErrExchangeRateNotFound)
+	} else if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return er, nil
+}
+
+// GetExchangeRateHistory returns up to limit latest values recorded since the given moment for
+// each of the rates, ordered by rate and time
+func (r *repository) GetExchangeRateHistory(ctx context.Context, exchangeRateIDs []int, since time.Time, limit int) ([]models.RateHistoryPoint, error) {
+	const op = "internal.repository.repository.GetExchangeRateHistory"
+
+	if len(exchangeRateIDs) == 0 {
+		return []models.RateHistoryPoint{}, nil
+	}
+
+	query := `
+	SELECT exchange_rate_id, rate, recorded_at FROM (
+		SELECT exchange_rate_id, rate, recorded_at,
+			ROW_NUMBER() OVER (PARTITION BY exchange_rate_id ORDER BY recorded_at DESC, ID DESC) AS n
+		FROM ExchangeRateHistory
+		WHERE exchange_rate_id IN (` + placeholders(len(exchangeRateIDs)) + `) AND recorded_at >= ?
+	)
+	WHERE n <= ?
+	ORDER BY exchange_rate_id, recorded_at
+	`
+
+	args := make([]any, 0, len(exchangeRateIDs)+2)
+	for _, id := range exchangeRateIDs {
+		args = append(args, id)
+	}
+	args = append(args, since.UTC(), limit)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	points := []models.RateHistoryPoint{}
+	for rows.Next() {
+		var p models.RateHistoryPoint
+		if err := rows.Scan(&p.ExchangeRateID, &p.Rate, &p.RecordedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		points = append(points, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return points, nil
+}
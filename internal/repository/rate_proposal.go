@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRateProposalNotFound   = errors.New("rate proposal not found")
+	ErrRateProposalNotPending = errors.New("rate proposal is not pending")
+)
+
+const rateProposalSelect = `
+	SELECT ID, base_currency_code, target_currency_code, rate, previous_rate, rate_version, status,
+		proposed_by, proposed_at, expires_at, decided_by, decided_at, reason
+	FROM RateProposals
+	`
+
+func scanRateProposal(row scanner) (models.RateProposal, error) {
+	var p models.RateProposal
+	var decidedAt sql.NullTime
+	err := row.Scan(&p.ID, &p.BaseCurrencyCode, &p.TargetCurrencyCode, &p.Rate, &p.PreviousRate, &p.RateVersion, &p.Status,
+		&p.ProposedBy, &p.ProposedAt, &p.ExpiresAt, &p.DecidedBy, &decidedAt, &p.Reason)
+	if decidedAt.Valid {
+		p.DecidedAt = &decidedAt.Time
+	}
+	return p, err
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// expireRateProposals marks the pending proposals whose time ran out as expired. Proposals are
+// expired when they are read or decided rather than by a timer.
+func expireRateProposals(ctx context.Context, e execer, now time.Time) error {
+	_, err := e.ExecContext(ctx,
+		`UPDATE RateProposals SET status = ?, decided_at = expires_at
+		WHERE status = ? AND expires_at <= ?`,
+		models.RateProposalExpired, models.RateProposalPending, now)
+	return err
+}
+
+func (r *repository) AddRateProposal(ctx context.Context, proposal models.RateProposal) (models.RateProposal, error) {
+	const op = "internal.repository.repository.AddRateProposal"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	proposal.Status = models.RateProposalPending
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO RateProposals (base_currency_code, target_currency_code, rate, previous_rate, rate_version, status,
+			proposed_by, proposed_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ID`,
+		proposal.BaseCurrencyCode, proposal.TargetCurrencyCode, proposal.Rate, proposal.PreviousRate, proposal.RateVersion,
+		proposal.Status, proposal.ProposedBy, proposal.ProposedAt.UTC(), proposal.ExpiresAt.UTC(),
+	).Scan(&proposal.ID)
+	if err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := proposal.BaseCurrencyCode + proposal.TargetCurrencyCode
+	if err := addAudit(ctx, tx, models.AuditRateProposal, key, models.AuditCreate, nil, proposal, proposal.ProposedAt.UTC()); err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return proposal, nil
+}
+
+func (r *repository) GetRateProposal(ctx context.Context, id int) (models.RateProposal, error) {
+	const op = "internal.repository.repository.GetRateProposal"
+
+	if err := expireRateProposals(ctx, r.conn, time.Now().UTC()); err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err := scanRateProposal(r.conn.QueryRowContext(ctx, rateProposalSelect+"WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, ErrRateProposalNotFound)
+	} else if err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+var rateProposalSortColumns = map[string]sortColumn{
+	"id":        {expr: "ID", key: intKey},
+	"expiresAt": {expr: "expires_at", key: timeKey},
+}
+
+func rateProposalSortKey(field string, p models.RateProposal) any {
+	if field == "expiresAt" {
+		return p.ExpiresAt
+	}
+	return p.ID
+}
+
+// ListRateProposals returns one page of the proposals matching the filter and their total count
+func (r *repository) ListRateProposals(ctx context.Context, filter models.RateProposalFilter, page models.PageRequest) (models.Page[models.RateProposal], error) {
+	const op = "internal.repository.repository.ListRateProposals"
+
+	if err := expireRateProposals(ctx, r.conn, time.Now().UTC()); err != nil {
+		return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var conditions []string
+	var args []any
+	for _, eq := range []struct {
+		column string
+		value  string
+	}{
+		{"status", string(filter.Status)},
+		{"base_currency_code", filter.BaseCode},
+		{"target_currency_code", filter.TargetCode},
+	} {
+		if eq.value != "" {
+			conditions = append(conditions, eq.column+" = ?")
+			args = append(args, eq.value)
+		}
+	}
+
+	orderBy, after, afterArgs, err := keyset(rateProposalSortColumns, "ID", page.Sort, page.Cursor)
+	if err != nil {
+		return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	err = r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM RateProposals"+where(conditions), args...).Scan(&total)
+	if err != nil {
+		return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return models.Page[models.RateProposal]{Items: []models.RateProposal{}}, fmt.Errorf("%s: %w", op, ErrRateProposalNotFound)
+	}
+
+	if after != "" {
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	// one more row tells whether there is a next page
+	query := rateProposalSelect + where(conditions) + orderBy + " LIMIT ?"
+	args = append(args, page.Limit+1)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	proposals := []models.RateProposal{}
+	for rows.Next() {
+		p, err := scanRateProposal(rows)
+		if err != nil {
+			return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		proposals = append(proposals, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := models.Page[models.RateProposal]{Items: proposals, Total: total}
+	if len(proposals) > page.Limit {
+		result.Items = proposals[:page.Limit]
+		last := result.Items[page.Limit-1]
+
+		field := strings.TrimPrefix(page.Sort, "-")
+		result.NextCursor, err = encodeCursor(page.Sort, rateProposalSortKey(field, last), last.ID)
+		if err != nil {
+			return models.Page[models.RateProposal]{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return result, nil
+}
+
+// pendingRateProposal reads a proposal in tx and fails unless it is still pending
+func pendingRateProposal(ctx context.Context, tx *sql.Tx, id int, now time.Time) (models.RateProposal, error) {
+	if err := expireRateProposals(ctx, tx, now); err != nil {
+		return models.RateProposal{}, err
+	}
+
+	p, err := scanRateProposal(tx.QueryRowContext(ctx, rateProposalSelect+"WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return models.RateProposal{}, ErrRateProposalNotFound
+	} else if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	if p.Status != models.RateProposalPending {
+		return models.RateProposal{}, fmt.Errorf("%w: it is %s", ErrRateProposalNotPending, p.Status)
+	}
+
+	return p, nil
+}
+
+// decideRateProposal records the decision on a pending proposal and audits it
+func decideRateProposal(ctx context.Context, tx *sql.Tx, p models.RateProposal, status models.RateProposalStatus, decidedBy, reason string, at time.Time) (models.RateProposal, error) {
+	previous := p
+
+	_, err := tx.ExecContext(ctx,
+		"UPDATE RateProposals SET status = ?, decided_by = ?, decided_at = ?, reason = ? WHERE ID = ?",
+		status, decidedBy, at, reason, p.ID)
+	if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	p.Status = status
+	p.DecidedBy = decidedBy
+	p.DecidedAt = &at
+	p.Reason = reason
+
+	action := models.AuditApprove
+	if status == models.RateProposalRejected {
+		action = models.AuditReject
+	}
+	if err := addAudit(ctx, tx, models.AuditRateProposal, p.BaseCurrencyCode+p.TargetCurrencyCode, action, previous, p, at); err != nil {
+		return models.RateProposal{}, err
+	}
+
+	return p, nil
+}
+
+// ApproveRateProposal applies a pending proposal to its rate and marks it approved in one
+// transaction. When the rate changed since the proposal was made nothing is written and
+// ErrVersionMismatch is returned.
+func (r *repository) ApproveRateProposal(ctx context.Context, id int, decidedBy string) (models.RateProposal, models.RateChange, error) {
+	const op = "internal.repository.repository.ApproveRateProposal"
+
+	decidedAt := time.Now().UTC()
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateProposal{}, models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	p, err := pendingRateProposal(ctx, tx, id, decidedAt)
+	if err != nil {
+		return models.RateProposal{}, models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	change, err := updateExchangeRate(ctx, tx, p.BaseCurrencyCode, p.TargetCurrencyCode, p.Rate, p.RateVersion, decidedAt)
+	if err != nil {
+		return models.RateProposal{}, models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err = decideRateProposal(ctx, tx, p, models.RateProposalApproved, decidedBy, "", decidedAt)
+	if err != nil {
+		return models.RateProposal{}, models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateProposal{}, models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, change, nil
+}
+
+func (r *repository) RejectRateProposal(ctx context.Context, id int, decidedBy, reason string) (models.RateProposal, error) {
+	const op = "internal.repository.repository.RejectRateProposal"
+
+	decidedAt := time.Now().UTC()
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	p, err := pendingRateProposal(ctx, tx, id, decidedAt)
+	if err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err = decideRateProposal(ctx, tx, p, models.RateProposalRejected, decidedBy, reason, decidedAt)
+	if err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateProposal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -59,9 +60,225 @@ func New(ctx context.Context) (*repository, error) {
 		return &repository{}, fmt.Errorf("%s: %v", op, err)
 	}
 
+	if err := migrate(ctx, db); err != nil {
+		return &repository{}, fmt.Errorf("%s: %v", op, err)
+	}
+
 	return &repository{conn: db}, nil
 }
 
+// migrations are applied in order on top of the base schema, the index of
+// the last applied one is kept in PRAGMA user_version
+var migrations = []string{
+	// rates stored before updated_at existed get the epoch, so they are treated as stale
+	`ALTER TABLE ExchangeRates ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+	`
+	CREATE TABLE ExchangeRateHistory (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		exchange_rate_id INTEGER NOT NULL,
+		rate REAL NOT NULL,
+		recorded_at TIMESTAMP NOT NULL,
+
+		FOREIGN KEY (exchange_rate_id) REFERENCES ExchangeRates(ID)
+	);`,
+	`
+	CREATE INDEX idx_exchange_rate_history_rate_time
+	ON ExchangeRateHistory(exchange_rate_id, recorded_at);`,
+	`
+	INSERT INTO ExchangeRateHistory (exchange_rate_id, rate, recorded_at)
+	SELECT ID, rate, updated_at FROM ExchangeRates;`,
+	`
+	CREATE TABLE AlertSubscriptions (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		base_currency_code TEXT NOT NULL,
+		target_currency_code TEXT NOT NULL,
+		condition TEXT NOT NULL,
+		level REAL NOT NULL DEFAULT 0,
+		percent REAL NOT NULL DEFAULT 0,
+		window_seconds INTEGER NOT NULL DEFAULT 0,
+		max_age_seconds INTEGER NOT NULL DEFAULT 0,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		last_triggered_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL
+	);`,
+	`
+	CREATE INDEX idx_alert_subscriptions_pair
+	ON AlertSubscriptions(base_currency_code, target_currency_code);`,
+	`
+	CREATE TABLE AlertDeliveries (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscription_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+
+		FOREIGN KEY (subscription_id) REFERENCES AlertSubscriptions(ID)
+	);`,
+	`
+	CREATE INDEX idx_alert_deliveries_status_next_attempt
+	ON AlertDeliveries(status, next_attempt_at);`,
+	`ALTER TABLE ExchangeRates ADD COLUMN source TEXT NOT NULL DEFAULT 'api'`,
+	`
+	CREATE INDEX idx_exchange_rates_updated_at
+	ON ExchangeRates(updated_at);`,
+	// the migration default has no zone, written times do, keyset pages compare them as text
+	`UPDATE ExchangeRates SET updated_at = '1970-01-01 00:00:00+00:00' WHERE updated_at = '1970-01-01 00:00:00'`,
+	// deactivated currencies and deleted rates are kept so their history stays readable
+	`ALTER TABLE Currencies ADD COLUMN active INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE ExchangeRates ADD COLUMN active INTEGER NOT NULL DEFAULT 1`,
+	// every write of rates is a generation, the rates written together share it
+	`
+	CREATE TABLE RateGenerations (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TIMESTAMP NOT NULL
+	);`,
+	`ALTER TABLE ExchangeRates ADD COLUMN generation INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE ExchangeRateHistory ADD COLUMN generation INTEGER NOT NULL DEFAULT 0`,
+	// every write of a row bumps its version, it is the ETag of the currency or rate
+	`ALTER TABLE Currencies ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE ExchangeRates ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	// the audit log is written in the transaction of the change it records and never changed
+	`
+	CREATE TABLE AuditLog (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		entity TEXT NOT NULL,
+		entity_key TEXT NOT NULL,
+		action TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		actor TEXT NOT NULL,
+		source_ip TEXT NOT NULL,
+		request_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);`,
+	`
+	CREATE INDEX idx_audit_log_entity_key
+	ON AuditLog(entity, entity_key);`,
+	`
+	CREATE INDEX idx_audit_log_created_at
+	ON AuditLog(created_at);`,
+	`
+	CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON AuditLog
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log entries can not be changed');
+	END;`,
+	`
+	CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON AuditLog
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log entries can not be deleted');
+	END;`,
+	`
+	CREATE TABLE RateProposals (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		base_currency_code TEXT NOT NULL,
+		target_currency_code TEXT NOT NULL,
+		rate REAL NOT NULL,
+		previous_rate REAL NOT NULL,
+		rate_version INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		proposed_by TEXT NOT NULL,
+		proposed_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		decided_by TEXT NOT NULL DEFAULT '',
+		decided_at TIMESTAMP,
+		reason TEXT NOT NULL DEFAULT ''
+	);`,
+	`
+	CREATE INDEX idx_rate_proposals_status_expires_at
+	ON RateProposals(status, expires_at);`,
+	`
+	CREATE TABLE ScheduledRates (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		base_currency_code TEXT NOT NULL,
+		target_currency_code TEXT NOT NULL,
+		rate REAL NOT NULL,
+		effective_from TIMESTAMP NOT NULL,
+		status TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		applied_at TIMESTAMP,
+		error TEXT NOT NULL DEFAULT ''
+	);`,
+	`
+	CREATE INDEX idx_scheduled_rates_status_effective_from
+	ON ScheduledRates(status, effective_from);`,
+	`
+	CREATE TABLE CurrencyBaskets (
+		currency_id INTEGER NOT NULL,
+		component_currency_id INTEGER NOT NULL,
+		amount REAL NOT NULL,
+
+		FOREIGN KEY (currency_id) REFERENCES Currencies(ID),
+		FOREIGN KEY (component_currency_id) REFERENCES Currencies(ID),
+
+		PRIMARY KEY (currency_id, component_currency_id)
+	);`,
+	`
+	CREATE TABLE Pegs (
+		currency_id INTEGER PRIMARY KEY,
+		anchor_currency_id INTEGER NOT NULL,
+		central_rate REAL NOT NULL,
+		band REAL NOT NULL DEFAULT 0,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+
+		FOREIGN KEY (currency_id) REFERENCES Currencies(ID),
+		FOREIGN KEY (anchor_currency_id) REFERENCES Currencies(ID)
+	);`,
+	`ALTER TABLE ScheduledRates ADD COLUMN rate_version INTEGER NOT NULL DEFAULT 0`,
+	// pending changes of pairs that have a rate are pinned to the version it is at now
+	`
+	UPDATE ScheduledRates SET rate_version = COALESCE((
+		SELECT er.version FROM ExchangeRates er
+		JOIN Currencies bc ON er.base_currency_id = bc.ID
+		JOIN Currencies tc ON er.target_currency_id = tc.ID
+		WHERE er.active = 1 AND bc.code = ScheduledRates.base_currency_code AND tc.code = ScheduledRates.target_currency_code
+	), 0)
+	WHERE status = 'pending'`,
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	const op = "internal.repository.repository.migrate"
+
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: migration %d: %w", op, i+1, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
 func (r *repository) Close() error {
 	return r.conn.Close()
 }
+
+// placeholders returns "?, ?, ?" for n query arguments
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
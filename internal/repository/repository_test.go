@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"testing"
+)
+
+// newTestRepository opens a fresh database in a temporary working directory
+func newTestRepository(t *testing.T) *repository {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	repo, err := New(context.Background())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func addCurrencies(t *testing.T, repo *repository, codes ...string) {
+	t.Helper()
+	for _, code := range codes {
+		if _, err := repo.AddCurrency(context.Background(), models.Currency{Code: code, Name: code, Sign: code}); err != nil {
+			t.Fatalf("AddCurrency(%s): %v", code, err)
+		}
+	}
+}
+
+func addRate(t *testing.T, repo *repository, pair string, rate float64) models.ExchangeRate {
+	t.Helper()
+	er, err := repo.AddExchangeRate(context.Background(), pair[:3], pair[3:], rate)
+	if err != nil {
+		t.Fatalf("AddExchangeRate(%s): %v", pair, err)
+	}
+	return er
+}
@@ -0,0 +1,440 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrScheduledRateNotFound   = errors.New("scheduled rate not found")
+	ErrScheduledRateNotPending = errors.New("scheduled rate is not pending")
+	ErrScheduledRateOutdated   = errors.New("exchange rate was written after the scheduled change became effective")
+)
+
+const scheduledRateSelect = `
+	SELECT ID, base_currency_code, target_currency_code, rate, rate_version, effective_from, status, created_by, created_at, applied_at, error
+	FROM ScheduledRates
+	`
+
+func scanScheduledRate(row scanner) (models.ScheduledRate, error) {
+	var s models.ScheduledRate
+	var appliedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.BaseCurrencyCode, &s.TargetCurrencyCode, &s.Rate, &s.RateVersion, &s.EffectiveFrom, &s.Status,
+		&s.CreatedBy, &s.CreatedAt, &appliedAt, &s.Error)
+	if appliedAt.Valid {
+		s.AppliedAt = &appliedAt.Time
+	}
+	return s, err
+}
+
+// AddScheduledRate stores a rate change to apply at its EffectiveFrom, both currencies must exist
+// and be active when it is scheduled
+func (r *repository) AddScheduledRate(ctx context.Context, scheduled models.ScheduledRate) (models.ScheduledRate, error) {
+	const op = "internal.repository.repository.AddScheduledRate"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, code := range []string{scheduled.BaseCurrencyCode, scheduled.TargetCurrencyCode} {
+		currency, err := currencyByCode(ctx, tx, code)
+		if err == sql.ErrNoRows {
+			return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, ErrCurrencyNotFound)
+		} else if err != nil {
+			return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if !currency.Active {
+			return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, ErrCurrencyInactive)
+		}
+	}
+
+	scheduled.Status = models.ScheduledRatePending
+	scheduled.EffectiveFrom = scheduled.EffectiveFrom.UTC()
+	scheduled.CreatedAt = scheduled.CreatedAt.UTC()
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO ScheduledRates (base_currency_code, target_currency_code, rate, rate_version, effective_from, status, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ID`,
+		scheduled.BaseCurrencyCode, scheduled.TargetCurrencyCode, scheduled.Rate, scheduled.RateVersion, scheduled.EffectiveFrom, scheduled.Status,
+		scheduled.CreatedBy, scheduled.CreatedAt,
+	).Scan(&scheduled.ID)
+	if err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := scheduled.BaseCurrencyCode + scheduled.TargetCurrencyCode
+	if err := addAudit(ctx, tx, models.AuditScheduledRate, key, models.AuditCreate, nil, scheduled, scheduled.CreatedAt); err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scheduled, nil
+}
+
+func (r *repository) GetScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error) {
+	const op = "internal.repository.repository.GetScheduledRate"
+
+	s, err := scanScheduledRate(r.conn.QueryRowContext(ctx, scheduledRateSelect+"WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, ErrScheduledRateNotFound)
+	} else if err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+var scheduledRateSortColumns = map[string]sortColumn{
+	"id":            {expr: "ID", key: intKey},
+	"effectiveFrom": {expr: "effective_from", key: timeKey},
+}
+
+func scheduledRateSortKey(field string, s models.ScheduledRate) any {
+	if field == "effectiveFrom" {
+		return s.EffectiveFrom
+	}
+	return s.ID
+}
+
+// ListScheduledRates returns one page of the scheduled changes matching the filter and their total count
+func (r *repository) ListScheduledRates(ctx context.Context, filter models.ScheduledRateFilter, page models.PageRequest) (models.Page[models.ScheduledRate], error) {
+	const op = "internal.repository.repository.ListScheduledRates"
+
+	var conditions []string
+	var args []any
+	for _, eq := range []struct {
+		column string
+		value  string
+	}{
+		{"status", string(filter.Status)},
+		{"base_currency_code", filter.BaseCode},
+		{"target_currency_code", filter.TargetCode},
+	} {
+		if eq.value != "" {
+			conditions = append(conditions, eq.column+" = ?")
+			args = append(args, eq.value)
+		}
+	}
+
+	orderBy, after, afterArgs, err := keyset(scheduledRateSortColumns, "ID", page.Sort, page.Cursor)
+	if err != nil {
+		return models.Page[models.ScheduledRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	err = r.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM ScheduledRates"+where(conditions), args...).Scan(&total)
+	if err != nil {
+		return models.Page[models.ScheduledRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if total == 0 {
+		return models.Page[models.ScheduledRate]{Items: []models.ScheduledRate{}}, fmt.Errorf("%s: %w", op, ErrScheduledRateNotFound)
+	}
+
+	if after != "" {
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	// one more row tells whether there is a next page
+	query := scheduledRateSelect + where(conditions) + orderBy + " LIMIT ?"
+	args = append(args, page.Limit+1)
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.Page[models.ScheduledRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	scheduled := []models.ScheduledRate{}
+	for rows.Next() {
+		s, err := scanScheduledRate(rows)
+		if err != nil {
+			return models.Page[models.ScheduledRate]{}, fmt.Errorf("%s: %w", op, err)
+		}
+		scheduled = append(scheduled, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.ScheduledRate]{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := models.Page[models.ScheduledRate]{Items: scheduled, Total: total}
+	if len(scheduled) > page.Limit {
+		result.Items = scheduled[:page.Limit]
+		last := result.Items[page.Limit-1]
+
+		field := strings.TrimPrefix(page.Sort, "-")
+		result.NextCursor, err = encodeCursor(page.Sort, scheduledRateSortKey(field, last), last.ID)
+		if err != nil {
+			return models.Page[models.ScheduledRate]{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *repository) CancelScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error) {
+	const op = "internal.repository.repository.CancelScheduledRate"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	s, err := scanScheduledRate(tx.QueryRowContext(ctx, scheduledRateSelect+"WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, ErrScheduledRateNotFound)
+	} else if err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if s.Status != models.ScheduledRatePending {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w: it is %s", op, ErrScheduledRateNotPending, s.Status)
+	}
+
+	previous := s
+	s.Status = models.ScheduledRateCanceled
+	if _, err := tx.ExecContext(ctx, "UPDATE ScheduledRates SET status = ? WHERE ID = ?", s.Status, s.ID); err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := s.BaseCurrencyCode + s.TargetCurrencyCode
+	if err := addAudit(ctx, tx, models.AuditScheduledRate, key, models.AuditCancel, previous, s, time.Now().UTC()); err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// NextScheduledRateAt returns when the earliest pending change becomes effective,
+// ErrScheduledRateNotFound when nothing is pending
+func (r *repository) NextScheduledRateAt(ctx context.Context) (time.Time, error) {
+	const op = "internal.repository.repository.NextScheduledRateAt"
+
+	s, err := scanScheduledRate(r.conn.QueryRowContext(ctx,
+		scheduledRateSelect+"WHERE status = ? ORDER BY effective_from, ID LIMIT 1", models.ScheduledRatePending))
+	if err == sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrScheduledRateNotFound)
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.EffectiveFrom, nil
+}
+
+// DueScheduledRates returns the pending changes effective at or before now, oldest first
+func (r *repository) DueScheduledRates(ctx context.Context, now time.Time) ([]models.ScheduledRate, error) {
+	const op = "internal.repository.repository.DueScheduledRates"
+
+	rows, err := r.conn.QueryContext(ctx, scheduledRateSelect+"WHERE status = ? AND effective_from <= ? ORDER BY effective_from, ID",
+		models.ScheduledRatePending, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	due := []models.ScheduledRate{}
+	for rows.Next() {
+		s, err := scanScheduledRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		due = append(due, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return due, nil
+}
+
+// ApplyScheduledRate sets a due change on its pair in one transaction. It is recorded as made at
+// its EffectiveFrom, so the history is right even when the server was down at that moment.
+// ErrScheduledRateNotPending is returned when the change was canceled meanwhile.
+func (r *repository) ApplyScheduledRate(ctx context.Context, id int, now time.Time) (models.RateChange, error) {
+	const op = "internal.repository.repository.ApplyScheduledRate"
+
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	s, err := scanScheduledRate(tx.QueryRowContext(ctx, scheduledRateSelect+"WHERE ID = ?", id))
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, ErrScheduledRateNotFound)
+	} else if err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if s.Status != models.ScheduledRatePending {
+		return models.RateChange{}, fmt.Errorf("%s: %w: it is %s", op, ErrScheduledRateNotPending, s.Status)
+	}
+
+	change, err := setScheduledRate(ctx, tx, s, now.UTC())
+	if err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE ScheduledRates SET status = ?, applied_at = ? WHERE ID = ?",
+		models.ScheduledRateApplied, now.UTC(), s.ID)
+	if err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateChange{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return change, nil
+}
+
+// FailScheduledRate marks a pending change that can not be applied as failed for reason
+func (r *repository) FailScheduledRate(ctx context.Context, id int, reason string, now time.Time) (models.ScheduledRate, error) {
+	const op = "internal.repository.repository.FailScheduledRate"
+
+	s, err := scanScheduledRate(r.conn.QueryRowContext(ctx, `
+	UPDATE ScheduledRates SET status = ?, applied_at = ?, error = ?
+	WHERE ID = ? AND status = ?
+	RETURNING ID, base_currency_code, target_currency_code, rate, rate_version, effective_from, status, created_by, created_at, applied_at, error`,
+		models.ScheduledRateFailed, now.UTC(), reason, id, models.ScheduledRatePending))
+	if err == sql.ErrNoRows {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, ErrScheduledRateNotPending)
+	} else if err != nil {
+		return models.ScheduledRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// setScheduledRate creates or updates the rate of the pair of s in tx as of its EffectiveFrom,
+// the audit entry is made at now. A create fails when the pair has a rate, an update when the
+// rate is no longer at the version it was scheduled against, and both when the pair was written
+// after EffectiveFrom.
+func setScheduledRate(ctx context.Context, tx *sql.Tx, s models.ScheduledRate, now time.Time) (models.RateChange, error) {
+	baseCurrency, err := currencyByCode(ctx, tx, s.BaseCurrencyCode)
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, ErrCurrencyNotFound
+	} else if err != nil {
+		return models.RateChange{}, err
+	}
+
+	targetCurrency, err := currencyByCode(ctx, tx, s.TargetCurrencyCode)
+	if err == sql.ErrNoRows {
+		return models.RateChange{}, ErrCurrencyNotFound
+	} else if err != nil {
+		return models.RateChange{}, err
+	}
+
+	if !baseCurrency.Active || !targetCurrency.Active {
+		return models.RateChange{}, ErrCurrencyInactive
+	}
+
+	var previous *models.ExchangeRate
+	current, err := scanExchangeRate(tx.QueryRowContext(ctx, exchangeRateSelect+"WHERE er.active = 1 AND bc.ID = ? AND tc.ID = ?",
+		baseCurrency.ID, targetCurrency.ID))
+	if err == nil {
+		previous = &current
+	} else if err != sql.ErrNoRows {
+		return models.RateChange{}, err
+	}
+
+	// a deleted pair keeps its row, so the time of its last write is known either way
+	var writtenAt time.Time
+	err = tx.QueryRowContext(ctx, "SELECT updated_at FROM ExchangeRates WHERE base_currency_id = ? AND target_currency_id = ?",
+		baseCurrency.ID, targetCurrency.ID).Scan(&writtenAt)
+	if err != nil && err != sql.ErrNoRows {
+		return models.RateChange{}, err
+	}
+
+	switch {
+	case s.RateVersion == 0 && previous != nil:
+		return models.RateChange{}, ErrExchangeRateExists
+	case s.RateVersion != 0 && previous == nil:
+		return models.RateChange{}, ErrExchangeRateNotFound
+	case s.RateVersion != 0 && previous.Version != s.RateVersion:
+		return models.RateChange{}, ErrVersionMismatch
+	}
+
+	updatedAt := s.EffectiveFrom.UTC()
+	// after downtime a change is applied late, a rate written since it became effective is newer
+	if writtenAt.After(updatedAt) {
+		return models.RateChange{}, ErrScheduledRateOutdated
+	}
+
+	generation, err := newRateGeneration(ctx, tx, updatedAt)
+	if err != nil {
+		return models.RateChange{}, err
+	}
+
+	var id int
+	var version int64
+	if previous == nil {
+		// like AddExchangeRate an active pair is left alone and returns no row
+		err = tx.QueryRowContext(
+			ctx,
+			`INSERT INTO ExchangeRates (base_currency_id, target_currency_id, rate, updated_at, source, generation) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (base_currency_id, target_currency_id) DO UPDATE
+			SET rate = excluded.rate, updated_at = excluded.updated_at, source = excluded.source, generation = excluded.generation,
+				active = 1, version = version + 1
+			WHERE active = 0
+			RETURNING ID, version`,
+			baseCurrency.ID, targetCurrency.ID, s.Rate, updatedAt, models.RateSourceSchedule, generation,
+		).Scan(&id, &version)
+		if err == sql.ErrNoRows {
+			return models.RateChange{}, ErrExchangeRateExists
+		}
+	} else {
+		err = tx.QueryRowContext(
+			ctx,
+			`UPDATE ExchangeRates SET rate = ?, updated_at = ?, source = ?, generation = ?, version = version + 1
+			WHERE ID = ? AND active = 1 AND version = ?
+			RETURNING ID, version`,
+			s.Rate, updatedAt, models.RateSourceSchedule, generation, previous.ID, s.RateVersion,
+		).Scan(&id, &version)
+		if err == sql.ErrNoRows {
+			return models.RateChange{}, ErrVersionMismatch
+		}
+	}
+	if err != nil {
+		return models.RateChange{}, err
+	}
+
+	if err := addRateHistory(ctx, tx, id, s.Rate, updatedAt, generation); err != nil {
+		return models.RateChange{}, err
+	}
+
+	updated := models.ExchangeRate{
+		ID:             id,
+		BaseCurrency:   baseCurrency,
+		TargetCurrency: targetCurrency,
+		Rate:           s.Rate,
+		UpdatedAt:      updatedAt,
+		Source:         models.RateSourceSchedule,
+		Generation:     generation,
+		Version:        version,
+	}
+
+	action, oldValue := models.AuditCreate, any(nil)
+	if previous != nil {
+		action, oldValue = models.AuditUpdate, *previous
+	}
+	if err := addAudit(ctx, tx, models.AuditExchangeRate, s.BaseCurrencyCode+s.TargetCurrencyCode, action, oldValue, updated, now); err != nil {
+		return models.RateChange{}, err
+	}
+
+	return models.RateChange{Previous: previous, Current: updated}, nil
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type alertService interface {
+	GetAllAlertSubscriptions(ctx context.Context) ([]models.AlertSubscription, error)
+	GetAlertSubscription(ctx context.Context, id int) (models.AlertSubscription, error)
+	AddAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error)
+	UpdateAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error)
+	DeleteAlertSubscription(ctx context.Context, id int) error
+	GetDeadLetters(ctx context.Context) ([]models.AlertDelivery, error)
+	RetryDeadLetter(ctx context.Context, id int) (models.AlertDelivery, error)
+}
+
+func (h *Handlers) GetAlertSubscriptions(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetAlertSubscriptions"
+
+	subscriptions, err := h.alertSrv.GetAllAlertSubscriptions(r.Context())
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrAlertSubscriptionNotFound) {
+			errorJSON(w, problemAlertNotFound, "alert subscriptions not found")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+func (h *Handlers) GetAlertSubscription(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetAlertSubscription"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid alert subscription id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	subscription, err := h.alertSrv.GetAlertSubscription(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrAlertSubscriptionNotFound) {
+			errorJSON(w, problemAlertNotFound, "alert subscription not found")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func (h *Handlers) CreateAlertSubscription(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.CreateAlertSubscription"
+
+	var req alertSubscriptionRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		bodyErrorJSON(w, err)
+		return
+	}
+
+	var subscription models.AlertSubscription
+	req.apply(&subscription)
+
+	invalid.require(subscription.BaseCurrencyCode != "", "baseCurrencyCode")
+	invalid.require(subscription.TargetCurrencyCode != "", "targetCurrencyCode")
+	invalid.require(subscription.Condition != "", "condition")
+	invalid.require(subscription.URL != "", "url")
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	created, err := h.alertSrv.AddAlertSubscription(r.Context(), subscription)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		alertSubscriptionErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *Handlers) UpdateAlertSubscription(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.UpdateAlertSubscription"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid alert subscription id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	var req alertSubscriptionRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		bodyErrorJSON(w, err)
+		return
+	}
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	subscription, err := h.alertSrv.GetAlertSubscription(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		alertSubscriptionErrorJSON(w, err)
+		return
+	}
+
+	// only the fields present in the body are changed
+	req.apply(&subscription)
+
+	updated, err := h.alertSrv.UpdateAlertSubscription(r.Context(), subscription)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		alertSubscriptionErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (h *Handlers) DeleteAlertSubscription(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.DeleteAlertSubscription"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid alert subscription id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	if err := h.alertSrv.DeleteAlertSubscription(r.Context(), id); err != nil {
+		log.Printf("%s: %v", op, err)
+		alertSubscriptionErrorJSON(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) GetAlertDeadLetters(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetAlertDeadLetters"
+
+	deliveries, err := h.alertSrv.GetDeadLetters(r.Context())
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrAlertDeliveryNotFound) {
+			errorJSON(w, problemDeliveryNotFound, "dead letters not found")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (h *Handlers) RetryAlertDeadLetter(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.RetryAlertDeadLetter"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid delivery id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	delivery, err := h.alertSrv.RetryDeadLetter(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrAlertDeliveryNotFound) {
+			errorJSON(w, problemDeliveryNotFound, "delivery not found")
+			return
+		}
+		if errors.Is(err, service.ErrAlertDeliveryNotDead) {
+			errorJSON(w, problemDeliveryNotDead, "delivery is not in the dead letter list")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// alertSubscriptionRequest holds the fields a client may set, nil ones are left as they are
+type alertSubscriptionRequest struct {
+	BaseCurrencyCode   *string                `json:"baseCurrencyCode"`
+	TargetCurrencyCode *string                `json:"targetCurrencyCode"`
+	Condition          *models.AlertCondition `json:"condition"`
+	Level              *float64               `json:"level"`
+	Percent            *float64               `json:"percent"`
+	WindowSeconds      *int                   `json:"windowSeconds"`
+	MaxAgeSeconds      *int                   `json:"maxAgeSeconds"`
+	URL                *string                `json:"url"`
+	Secret             *string                `json:"secret"`
+}
+
+func (req alertSubscriptionRequest) apply(subscription *models.AlertSubscription) {
+	if req.BaseCurrencyCode != nil {
+		subscription.BaseCurrencyCode = *req.BaseCurrencyCode
+	}
+	if req.TargetCurrencyCode != nil {
+		subscription.TargetCurrencyCode = *req.TargetCurrencyCode
+	}
+	if req.Condition != nil {
+		subscription.Condition = *req.Condition
+	}
+	if req.Level != nil {
+		subscription.Level = *req.Level
+	}
+	if req.Percent != nil {
+		subscription.Percent = *req.Percent
+	}
+	if req.WindowSeconds != nil {
+		subscription.WindowSeconds = *req.WindowSeconds
+	}
+	if req.MaxAgeSeconds != nil {
+		subscription.MaxAgeSeconds = *req.MaxAgeSeconds
+	}
+	if req.URL != nil {
+		subscription.URL = *req.URL
+	}
+	if req.Secret != nil {
+		subscription.Secret = *req.Secret
+	}
+}
+
+func alertSubscriptionErrorJSON(w http.ResponseWriter, err error) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		var invalid validation
+		for _, field := range validationErr.Fields {
+			invalid.add(field.Field, "invalid", field.Message)
+		}
+		validationErrorJSON(w, invalid)
+	case errors.Is(err, repository.ErrAlertSubscriptionNotFound):
+		errorJSON(w, problemAlertNotFound, "alert subscription not found")
+	case errors.Is(err, repository.ErrCurrencyNotFound):
+		errorJSON(w, problemCurrencyNotFound, "one or both currencies not found")
+	case errors.Is(err, service.ErrInvalidAlertSubscription):
+		errorJSON(w, problemValidationFailed, "invalid alert subscription")
+	default:
+		errorJSON(w, problemInternal, "internal server error")
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"exchanger/internal/models"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// GetArbitrage reports the loops of conversions over the stored rates that return more than they
+// take. ?threshold=0.001 sets the least profit of a reported loop, ?currency=USD keeps the loops
+// through USD.
+func (h *Handlers) GetArbitrage(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetArbitrage"
+
+	query := r.URL.Query()
+
+	var invalid validation
+	filter := models.ArbitrageFilter{Currency: query.Get("currency")}
+	if v := query.Get("threshold"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		switch {
+		case err != nil:
+			invalid.add("threshold", "invalid_format", "invalid threshold format")
+		case !(threshold >= 0) || math.IsInf(threshold, 1):
+			invalid.add("threshold", "out_of_range", "threshold must be a finite number of at least zero")
+		default:
+			filter.Threshold = &threshold
+		}
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	report, err := h.exchangeRateSrv.FindArbitrage(r.Context(), filter)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ActorHeader names the user a request is made for. The service does not authenticate requests,
+// the gateway in front of it sets the header and audit entries record it as the actor.
+const ActorHeader = "X-Actor"
+
+// ActorScopesHeader lists what the actor may do beyond reading and writing, separated by spaces,
+// e.g. rates:approve. It is set by the gateway like ActorHeader.
+const ActorScopesHeader = "X-Actor-Scopes"
+
+type auditService interface {
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter, page models.PageRequest) (models.Page[models.AuditEntry], error)
+	ExportAuditEntries(ctx context.Context, w io.Writer, filter models.AuditFilter) error
+}
+
+// parseAuditFilter reads ?entity=currency|exchangeRate|rateProposal|scheduledRate|peg, ?key=USDRUB, ?action=update, ?actor=,
+// ?requestId= and the ?since= and ?until= RFC 3339 times
+func parseAuditFilter(query url.Values, invalid *validation) models.AuditFilter {
+	filter := models.AuditFilter{
+		Entity:    models.AuditEntity(query.Get("entity")),
+		Key:       query.Get("key"),
+		Action:    models.AuditAction(query.Get("action")),
+		Actor:     query.Get("actor"),
+		RequestID: query.Get("requestId"),
+	}
+
+	switch filter.Entity {
+	case "", models.AuditCurrency, models.AuditExchangeRate, models.AuditRateProposal, models.AuditScheduledRate, models.AuditPeg,
+		models.AuditAlertSubscription:
+	default:
+		invalid.add("entity", "invalid", "entity must be currency, exchangeRate, rateProposal, scheduledRate, peg or alertSubscription")
+	}
+
+	switch filter.Action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditDeactivate, models.AuditActivate,
+		models.AuditApprove, models.AuditReject, models.AuditCancel:
+	default:
+		invalid.add("action", "invalid", "action must be create, update, delete, deactivate, activate, approve, reject or cancel")
+	}
+
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if v := query.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				invalid.add(bound.name, "invalid_format", bound.name+" must be an RFC 3339 time")
+			}
+			*bound.t = t
+		}
+	}
+
+	return filter
+}
+
+// GetAuditEntries lists the audit log a page at a time, newest first unless ?sort=id|createdAt
+// (with "-" for descending order) says otherwise
+func (h *Handlers) GetAuditEntries(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetAuditEntries"
+
+	query := r.URL.Query()
+
+	var invalid validation
+	page := parsePageRequest(query, &invalid)
+	if page.Sort == "" {
+		page.Sort = "-id"
+	}
+	filter := parseAuditFilter(query, &invalid)
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	result, err := h.auditSrv.ListAuditEntries(r.Context(), filter, page)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if pageErrorJSON(w, err) {
+			return
+		}
+		if errors.Is(err, repository.ErrAuditEntryNotFound) {
+			errorJSON(w, problemAuditEntryNotFound, "no audit entries match")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	writePageHeaders(w, r, result.Total, result.NextCursor)
+	writeTaggedJSON(w, r, "", result.Items)
+}
+
+// ExportAuditEntries streams every audit entry matching the filters of GetAuditEntries as CSV
+func (h *Handlers) ExportAuditEntries(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.ExportAuditEntries"
+
+	var invalid validation
+	filter := parseAuditFilter(r.URL.Query(), &invalid)
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="exchanger-audit.csv"`)
+
+	out := &startedWriter{w: w}
+	if err := h.auditSrv.ExportAuditEntries(r.Context(), out, filter); err != nil {
+		log.Printf("%s: %v", op, err)
+		// once the export has started the status is sent and the failure can only be logged
+		if !out.started {
+			w.Header().Del("Content-Disposition")
+			errorJSON(w, problemInternal, "internal server error")
+		}
+	}
+}
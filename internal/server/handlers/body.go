@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxBodySize limits JSON and form bodies of the write endpoints
+const maxBodySize = 64 << 10
+
+var (
+	ErrBodyTooLarge         = errors.New("request body too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// decodeBody fills dst, a pointer to a struct with json tags, from a JSON body or from
+// a form encoded one. Fields with a value of the wrong type and unknown JSON fields are
+// returned as a validation, form fields that are not in dst are ignored so older clients
+// keep working. An error means the body could not be read at all.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) (validation, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	mediaType := "application/x-www-form-urlencoded"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, ErrUnsupportedMediaType
+		}
+	}
+
+	switch mediaType {
+	case "application/json":
+		return decodeJSON(r.Body, dst)
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, bodyReadError(err, "invalid form data")
+		}
+		return decodeForm(r.PostForm, dst), nil
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(maxBodySize); err != nil {
+			return nil, bodyReadError(err, "invalid form data")
+		}
+		return decodeForm(r.PostForm, dst), nil
+	default:
+		return nil, ErrUnsupportedMediaType
+	}
+}
+
+func decodeJSON(body io.Reader, dst any) (validation, error) {
+	dec := json.NewDecoder(body)
+
+	// fields are decoded one by one so every invalid one is reported
+	var values map[string]json.RawMessage
+	if err := dec.Decode(&values); err != nil {
+		return nil, bodyReadError(err, "body must be a JSON object")
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: body must contain a single JSON object", ErrInvalidInputData)
+	}
+
+	var invalid validation
+
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		name := jsonName(t.Field(i))
+		raw, ok := values[name]
+		if name == "" || !ok {
+			continue
+		}
+		delete(values, name)
+
+		if err := json.Unmarshal(raw, v.Field(i).Addr().Interface()); err != nil {
+			v.Field(i).SetZero()
+			invalid.add(name, "invalid_format", fmt.Sprintf("invalid %s format", name))
+		}
+	}
+
+	unknown := make([]string, 0, len(values))
+	for name := range values {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		invalid.add(name, "unknown_field", fmt.Sprintf("unknown field %q", name))
+	}
+
+	return invalid, nil
+}
+
+// decodeForm sets the string, integer, float and encoding.TextUnmarshaler fields of dst that are
+// present in values
+func decodeForm(values url.Values, dst any) validation {
+	var invalid validation
+
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		name := jsonName(t.Field(i))
+		raw := values.Get(name)
+		if name == "" || raw == "" {
+			continue
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Pointer {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+
+		var err error
+		text, isText := field.Addr().Interface().(encoding.TextUnmarshaler)
+		switch {
+		case isText:
+			err = text.UnmarshalText([]byte(raw))
+		case field.Kind() == reflect.String:
+			field.SetString(raw)
+		case field.Kind() == reflect.Int:
+			var n int
+			n, err = strconv.Atoi(raw)
+			field.SetInt(int64(n))
+		case field.Kind() == reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(raw, 64)
+			field.SetFloat(f)
+		}
+		if err != nil {
+			v.Field(i).SetZero()
+			invalid.add(name, "invalid_format", fmt.Sprintf("invalid %s format", name))
+		}
+	}
+
+	return invalid
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+func bodyReadError(err error, message string) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrBodyTooLarge
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidInputData, message)
+}
+
+// bodyErrorJSON writes the response for an error returned by decodeBody
+func bodyErrorJSON(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		errorJSON(w, problemBodyTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBodySize))
+	case errors.Is(err, ErrUnsupportedMediaType):
+		errorJSON(w, problemUnsupportedMediaType, "content type must be application/json or application/x-www-form-urlencoded")
+	default:
+		errorJSON(w, problemInvalidRequest, strings.TrimPrefix(err.Error(), ErrInvalidInputData.Error()+": "))
+	}
+}
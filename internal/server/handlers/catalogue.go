@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/service"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// importMaxBodySize limits imported files, they are much larger than other request bodies
+const importMaxBodySize = 10 << 20
+
+type catalogueService interface {
+	ImportCatalogue(ctx context.Context, r io.Reader, format service.CatalogueFormat, dryRun bool) (models.ImportReport, error)
+	ExportCatalogue(ctx context.Context, w io.Writer, format service.CatalogueFormat) error
+}
+
+// ImportCatalogue creates or updates the currencies and rates of a CSV or JSON file sent as the body
+// or as the file field of a multipart form. Nothing is written when a record is rejected or with
+// ?dryRun=true, the report tells what was or would be done with every record.
+func (h *Handlers) ImportCatalogue(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.ImportCatalogue"
+
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			log.Printf("%s: %v", op, ErrInvalidInputData)
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "dryRun", Code: "invalid_format", Message: "dryRun must be true or false"})
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBodySize)
+
+	file, format, err := catalogueFile(r)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		catalogueErrorJSON(w, err)
+		return
+	}
+	defer file.Close()
+
+	report, err := h.catalogueSrv.ImportCatalogue(r.Context(), file, format, dryRun)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, service.ErrImportRejected) {
+			invalid := make([]FieldError, len(report.Errors))
+			for i, e := range report.Errors {
+				invalid[i] = FieldError{Field: e.Ref + "." + e.Field, Code: e.Code, Message: e.Message}
+			}
+			errorJSON(w, problemImportRejected, "no record was imported, fix the rejected ones and retry", invalid...)
+			return
+		}
+		catalogueErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// catalogueFile returns the imported file and its format, taken from the content type of the
+// body or of the file field and then from the file name
+func catalogueFile(r *http.Request) (io.ReadCloser, service.CatalogueFormat, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, "", ErrUnsupportedMediaType
+	}
+
+	if mediaType != "multipart/form-data" {
+		format, ok := catalogueFormat(mediaType, "")
+		if !ok {
+			return nil, "", ErrUnsupportedMediaType
+		}
+		return r.Body, format, nil
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, "", fmt.Errorf("%w: file field is required", ErrInvalidInputData)
+		}
+		return nil, "", bodyReadError(err, "invalid form data")
+	}
+
+	partType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	format, ok := catalogueFormat(partType, header.Filename)
+	if !ok {
+		file.Close()
+		return nil, "", ErrUnsupportedMediaType
+	}
+
+	return file, format, nil
+}
+
+func catalogueFormat(mediaType, filename string) (service.CatalogueFormat, bool) {
+	switch {
+	case mediaType == "text/csv" || strings.EqualFold(path.Ext(filename), ".csv"):
+		return service.CatalogueCSV, true
+	case mediaType == "application/json" || strings.EqualFold(path.Ext(filename), ".json"):
+		return service.CatalogueJSON, true
+	default:
+		return "", false
+	}
+}
+
+func catalogueErrorJSON(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		errorJSON(w, problemBodyTooLarge, fmt.Sprintf("imported file must not exceed %d bytes", importMaxBodySize))
+	case errors.Is(err, ErrUnsupportedMediaType):
+		errorJSON(w, problemUnsupportedMediaType, "file must be text/csv or application/json")
+	case errors.Is(err, service.ErrInvalidCatalogue):
+		_, detail, _ := strings.Cut(err.Error(), service.ErrInvalidCatalogue.Error()+": ")
+		errorJSON(w, problemInvalidRequest, detail)
+	case errors.Is(err, ErrInvalidInputData), errors.Is(err, ErrBodyTooLarge):
+		bodyErrorJSON(w, err)
+	default:
+		errorJSON(w, problemInternal, "internal server error")
+	}
+}
+
+// ExportCatalogue streams every currency and active rate as ?format=csv or ?format=json, the default
+func (h *Handlers) ExportCatalogue(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.ExportCatalogue"
+
+	format := service.CatalogueFormat(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		format = service.CatalogueJSON
+		fallthrough
+	case service.CatalogueJSON:
+		w.Header().Set("Content-Type", "application/json")
+	case service.CatalogueCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	default:
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		errorJSON(w, problemValidationFailed, "request has invalid fields",
+			FieldError{Field: "format", Code: "invalid", Message: "format must be csv or json"})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="exchanger-catalogue.%s"`, format))
+
+	out := &startedWriter{w: w}
+	if err := h.catalogueSrv.ExportCatalogue(r.Context(), out, format); err != nil {
+		log.Printf("%s: %v", op, err)
+		// once the export has started the status is sent and the failure can only be logged
+		if !out.started {
+			w.Header().Del("Content-Disposition")
+			errorJSON(w, problemInternal, "internal server error")
+		}
+	}
+}
+
+// startedWriter tells whether anything was written
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+	return s.w.Write(p)
+}
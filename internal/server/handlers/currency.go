@@ -6,32 +6,70 @@ import (
 	"errors"
 	"exchanger/internal/models"
 	"exchanger/internal/repository"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// maxBasketComponents limits how many currencies the basket of a synthetic currency may hold
+const maxBasketComponents = 20
+
 type currencyService interface {
 	GetAllCurrencies(ctx context.Context) ([]models.Currency, error)
+	ListCurrencies(ctx context.Context, filter models.CurrencyFilter, page models.PageRequest) (models.Page[models.Currency], error)
 	GetCurrencyByCode(ctx context.Context, code string) (models.Currency, error)
 	AddCurrency(ctx context.Context, currency models.Currency) (models.Currency, error)
+	DeactivateCurrency(ctx context.Context, code string, cascade bool) (models.Currency, error)
+	ActivateCurrency(ctx context.Context, code string) (models.Currency, error)
 }
 
+// GetCurrencies lists active currencies a page at a time, ?codePrefix=U narrows the list,
+// ?includeInactive=true adds deactivated ones and ?sort=code|name|id (with "-" for descending
+// order) orders it
 func (h *Handlers) GetCurrencies(w http.ResponseWriter, r *http.Request) {
 	const op = "internal.server.handlers.handlers.GetCurrencies"
 
-	currencies, err := h.currencySrv.GetAllCurrencies(r.Context())
+	query := r.URL.Query()
+
+	var invalid validation
+	page := parsePageRequest(query, &invalid)
+
+	filter := models.CurrencyFilter{
+		CodePrefix: query.Get("codePrefix"),
+	}
+	if v := query.Get("includeInactive"); v != "" {
+		includeInactive, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid.add("includeInactive", "invalid_format", "includeInactive must be true or false")
+		}
+		filter.IncludeInactive = includeInactive
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	result, err := h.currencySrv.ListCurrencies(r.Context(), filter, page)
 	if err != nil {
 		log.Printf("%s: %v", op, err)
+		if pageErrorJSON(w, err) {
+			return
+		}
 		if errors.Is(err, repository.ErrCurrencyNotFound) {
-			errorJSON(w, "currency not found", http.StatusNotFound)
+			errorJSON(w, problemCurrencyNotFound, "currency not found")
 			return
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		errorJSON(w, problemInternal, "internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(currencies)
+	writePageHeaders(w, r, result.Total, result.NextCursor)
+	writeTaggedJSON(w, r, "", result.Items)
 }
 
 func (h *Handlers) GetCurrency(w http.ResponseWriter, r *http.Request) {
@@ -40,7 +78,8 @@ func (h *Handlers) GetCurrency(w http.ResponseWriter, r *http.Request) {
 	code := r.PathValue("code")
 	if code == "" {
 		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "currency code is required", http.StatusBadRequest)
+		errorJSON(w, problemValidationFailed, "currency code is required",
+			FieldError{Field: "code", Code: "required", Message: "code is required"})
 		return
 	}
 
@@ -48,49 +87,191 @@ func (h *Handlers) GetCurrency(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("%s: %v", op, err)
 		if errors.Is(err, repository.ErrCurrencyNotFound) {
-			errorJSON(w, "currency not found", http.StatusNotFound)
+			errorJSON(w, problemCurrencyNotFound, "currency not found")
 			return
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		errorJSON(w, problemInternal, "internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(currency)
+	writeTaggedJSON(w, r, versionETag(currency.Version), currency)
+}
+
+type createCurrencyRequest struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+	Sign string `json:"sign"`
+	// Basket makes the currency synthetic
+	Basket basketRequest `json:"basket"`
+}
+
+// basketRequest is the basket of a synthetic currency, a JSON array of components or, in a form
+// and as a JSON string, the components written as "USD:0.5,EUR:0.5"
+type basketRequest []models.BasketComponent
+
+func (b *basketRequest) UnmarshalText(text []byte) error {
+	if strings.TrimSpace(string(text)) == "" {
+		*b = nil
+		return nil
+	}
+
+	var basket basketRequest
+	for _, part := range strings.Split(string(text), ",") {
+		code, amount, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return fmt.Errorf("%w: basket component %q is not CODE:amount", ErrInvalidInputData, part)
+		}
+		a, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return err
+		}
+		basket = append(basket, models.BasketComponent{CurrencyCode: code, Amount: a})
+	}
+
+	*b = basket
+	return nil
+}
+
+func (b *basketRequest) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return b.UnmarshalText([]byte(text))
+	}
+	return json.Unmarshal(data, (*[]models.BasketComponent)(b))
+}
+
+// validateBasket checks the components of the basket of the currency code, the ones that are
+// no active real currency are left to the repository
+func validateBasket(code string, basket basketRequest, invalid *validation) {
+	if len(basket) > maxBasketComponents {
+		invalid.add("basket", "too_many", fmt.Sprintf("basket must not hold more than %d currencies", maxBasketComponents))
+		return
+	}
+
+	seen := make(map[string]bool, len(basket))
+	for _, c := range basket {
+		switch {
+		case c.CurrencyCode == "":
+			invalid.add("basket", "required", "every basket component needs a currencyCode")
+		case c.CurrencyCode == code:
+			invalid.add("basket", "invalid", "a currency can not be a component of its own basket")
+		case seen[c.CurrencyCode]:
+			invalid.add("basket", "duplicate", fmt.Sprintf("%s is in the basket more than once", c.CurrencyCode))
+		case !(c.Amount > 0) || math.IsInf(c.Amount, 1):
+			invalid.add("basket", "out_of_range", fmt.Sprintf("amount of %s must be a finite number greater than zero", c.CurrencyCode))
+		}
+		seen[c.CurrencyCode] = true
+	}
 }
 
 func (h *Handlers) CreateCurrency(w http.ResponseWriter, r *http.Request) {
 	const op = "internal.server.handlers.handlers.CreateCurrency"
 
-	if err := r.ParseForm(); err != nil {
-		errorJSON(w, "invalid form data", http.StatusBadRequest)
+	var req createCurrencyRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		bodyErrorJSON(w, err)
 		return
 	}
 
-	currency := models.Currency{
-		Name: r.PostFormValue("name"),
-		Code: r.PostFormValue("code"),
-		Sign: r.PostFormValue("sign"),
+	invalid.require(req.Name != "", "name")
+	invalid.require(req.Code != "", "code")
+	invalid.require(req.Sign != "", "sign")
+	validateBasket(req.Code, req.Basket, &invalid)
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
 	}
 
-	if currency.Name == "" || currency.Code == "" || currency.Sign == "" {
-		log.Printf("%s: %v", op, errors.New("invalid input data"))
-		errorJSON(w, "all fields are required", http.StatusBadRequest)
-		return
+	currency := models.Currency{
+		Name:   req.Name,
+		Code:   req.Code,
+		Sign:   req.Sign,
+		Basket: req.Basket,
 	}
 
 	createdCurrency, err := h.currencySrv.AddCurrency(r.Context(), currency)
 	if err != nil {
 		log.Printf("%s: %v", op, err)
-		if errors.Is(err, repository.ErrCurrencyExists) {
-			errorJSON(w, "currency already exists", http.StatusConflict)
-			return
+		switch {
+		case errors.Is(err, repository.ErrCurrencyExists):
+			errorJSON(w, problemCurrencyExists, "currency already exists")
+		case errors.Is(err, repository.ErrCurrencyNotFound):
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "basket", Code: "not_found", Message: "every basket component must be a known currency"})
+		case errors.Is(err, repository.ErrCurrencyInactive):
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "basket", Code: "inactive", Message: "every basket component must be an active currency"})
+		case errors.Is(err, repository.ErrNestedBasket):
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "basket", Code: "synthetic", Message: "a basket component can not be a synthetic currency"})
+		default:
+			errorJSON(w, problemInternal, "internal server error")
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(createdCurrency.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdCurrency)
 }
+
+// DeactivateCurrency hides a currency from lists and conversions, a currency used by active
+// rates is only deactivated with ?cascade=true, which deletes those rates too
+func (h *Handlers) DeactivateCurrency(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.DeactivateCurrency"
+
+	code := r.PathValue("code")
+
+	cascade := false
+	if v := r.URL.Query().Get("cascade"); v != "" {
+		var err error
+		if cascade, err = strconv.ParseBool(v); err != nil {
+			log.Printf("%s: %v", op, ErrInvalidInputData)
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "cascade", Code: "invalid_format", Message: "cascade must be true or false"})
+			return
+		}
+	}
+
+	currency, err := h.currencySrv.DeactivateCurrency(r.Context(), code, cascade)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrCurrencyNotFound) {
+			errorJSON(w, problemCurrencyNotFound, "currency not found")
+			return
+		}
+		if errors.Is(err, repository.ErrCurrencyInUse) {
+			errorJSON(w, problemCurrencyInUse, "currency is used by active exchange rates, delete them or pass cascade=true")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(currency.Version))
+	json.NewEncoder(w).Encode(currency)
+}
+
+func (h *Handlers) ActivateCurrency(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.ActivateCurrency"
+
+	currency, err := h.currencySrv.ActivateCurrency(r.Context(), r.PathValue("code"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrCurrencyNotFound) {
+			errorJSON(w, problemCurrencyNotFound, "currency not found")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(currency.Version))
+	json.NewEncoder(w).Encode(currency)
+}
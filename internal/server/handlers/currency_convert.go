@@ -6,6 +6,7 @@ import (
 	"errors"
 	"exchanger/internal/models"
 	"exchanger/internal/repository"
+	"exchanger/internal/service"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,8 +14,12 @@ import (
 
 type currencyConvertService interface {
 	ConvertCurrency(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, error)
+	ConvertCurrencyWithLegs(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, []models.ExchangeRate, error)
+	ExplainConversion(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, error)
+	GetRateMatrix(ctx context.Context, codes []string) (models.RateMatrix, error)
 }
 
+// ExchangeCurrency converts amount, ?explain=true adds how the result was computed
 func (h *Handlers) ExchangeCurrency(w http.ResponseWriter, r *http.Request) {
 	const op = "internal.server.handlers.handlers.ExchangeCurrency"
 
@@ -22,40 +27,65 @@ func (h *Handlers) ExchangeCurrency(w http.ResponseWriter, r *http.Request) {
 	toCode := r.URL.Query().Get("to")
 	amountStr := r.URL.Query().Get("amount")
 
-	if fromCode == "" || toCode == "" || amountStr == "" {
-		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "from, to, and amount parameters are required", http.StatusBadRequest)
-		return
+	var invalid validation
+	invalid.require(fromCode != "", "from")
+	invalid.require(toCode != "", "to")
+	invalid.require(amountStr != "", "amount")
+
+	var amount float64
+	if amountStr != "" {
+		var err error
+		amount, err = strconv.ParseFloat(amountStr, 64)
+		if err != nil {
+			invalid.add("amount", "invalid_format", "invalid amount format")
+		} else if amount <= 0 {
+			invalid.add("amount", "out_of_range", "amount must be greater than zero")
+		}
 	}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil {
-		log.Printf("%s: %v", op, err)
-		errorJSON(w, "invalid amount format", http.StatusBadRequest)
-		return
+	var explain bool
+	if v := r.URL.Query().Get("explain"); v != "" {
+		var err error
+		if explain, err = strconv.ParseBool(v); err != nil {
+			invalid.add("explain", "invalid_format", "explain must be true or false")
+		}
 	}
 
-	if amount <= 0 {
+	if len(invalid) > 0 {
 		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "amount must be greater than zero", http.StatusBadRequest)
+		validationErrorJSON(w, invalid)
 		return
 	}
 
-	result, err := h.currencyConvertSrv.ConvertCurrency(r.Context(), fromCode, toCode, amount)
+	convert := h.currencyConvertSrv.ConvertCurrency
+	if explain {
+		convert = h.currencyConvertSrv.ExplainConversion
+	}
+
+	result, err := convert(r.Context(), fromCode, toCode, amount)
 	if err != nil {
 		log.Printf("%s: %v", op, err)
-		if errors.Is(err, repository.ErrCurrencyNotFound) {
-			errorJSON(w, "currency not found", http.StatusNotFound)
-			return
-		}
-		if errors.Is(err, repository.ErrExchangeRateNotFound) {
-			errorJSON(w, "exchange rate not found", http.StatusNotFound)
-			return
-		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		problem, message := conversionError(err)
+		errorJSON(w, problem, message)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// conversionError maps conversion errors to a problem and a message for the client
+func conversionError(err error) (problemType, string) {
+	switch {
+	case errors.Is(err, repository.ErrCurrencyNotFound):
+		return problemCurrencyNotFound, "currency not found"
+	case errors.Is(err, repository.ErrExchangeRateNotFound):
+		return problemRateNotFound, "exchange rate not found"
+	case errors.Is(err, repository.ErrCurrencyInactive):
+		return problemCurrencyInactive, "currency is inactive"
+	case errors.Is(err, service.ErrExchangeRateStale):
+		return problemRateStale, "exchange rate is stale"
+	default:
+		return problemInternal, "internal server error"
+	}
+}
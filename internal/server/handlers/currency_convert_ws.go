@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	quoteWriteWait        = 10 * time.Second
+	quotePongWait         = 60 * time.Second
+	quotePingPeriod       = quotePongWait * 9 / 10
+	quoteMaxMessageSize   = 4096
+	quoteMaxSubscriptions = 100
+	// quoteMaxOutbox bounds the messages waiting for a slow client, quotes for the same
+	// subscription replace each other so only acknowledgements and errors pile up
+	quoteMaxOutbox = 2*quoteMaxSubscriptions + 32
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type quoteRequest struct {
+	Type   string  `json:"type"`
+	ID     string  `json:"id"`
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+type quoteMessage struct {
+	Type       string                     `json:"type"`
+	ID         string                     `json:"id,omitempty"`
+	Conversion *models.CurrencyConversion `json:"conversion,omitempty"`
+	Message    string                     `json:"message,omitempty"`
+}
+
+type quoteSubscription struct {
+	from, to string
+	amount   float64
+	// pairs are the stored rates the last quote was computed from
+	pairs   map[string]bool
+	last    *models.CurrencyConversion
+	lastErr string
+}
+
+type outboxItem struct {
+	msg quoteMessage
+	// conflate marks quote updates that may be replaced by a newer one for the same subscription
+	conflate bool
+}
+
+type quoteSession struct {
+	h      *Handlers
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	subs   map[string]*quoteSubscription
+	outbox []outboxItem
+
+	wake    chan struct{}
+	refresh chan string
+}
+
+// ExchangeCurrencyWS serves live conversion quotes over a WebSocket. Clients send
+// {"type":"subscribe","id":"q1","from":"USD","to":"EUR","amount":100} and
+// {"type":"unsubscribe","id":"q1"}, the server answers with "subscribed", "unsubscribed",
+// "quote" and "error" messages and sends a new quote whenever a rate it depends on changes.
+func (h *Handlers) ExchangeCurrencyWS(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.ExchangeCurrencyWS"
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an error
+		log.Printf("%s: %v", op, err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	s := &quoteSession{
+		h:       h,
+		conn:    conn,
+		ctx:     ctx,
+		cancel:  cancel,
+		subs:    make(map[string]*quoteSubscription),
+		wake:    make(chan struct{}, 1),
+		refresh: make(chan string, quoteMaxSubscriptions),
+	}
+
+	go s.writeLoop()
+	go s.watchRates()
+
+	if err := s.readLoop(); err != nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		log.Printf("%s: %v", op, err)
+	}
+}
+
+func (s *quoteSession) readLoop() error {
+	defer s.cancel()
+
+	s.conn.SetReadLimit(quoteMaxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(quotePongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(quotePongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var req quoteRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.enqueue(quoteMessage{Type: "error", Message: "invalid message format"}, false)
+			continue
+		}
+
+		switch req.Type {
+		case "subscribe":
+			s.subscribe(req)
+		case "unsubscribe":
+			s.unsubscribe(req.ID)
+		default:
+			s.enqueue(quoteMessage{Type: "error", ID: req.ID, Message: "unknown message type"}, false)
+		}
+	}
+}
+
+func (s *quoteSession) subscribe(req quoteRequest) {
+	if req.ID == "" || req.From == "" || req.To == "" {
+		s.enqueue(quoteMessage{Type: "error", ID: req.ID, Message: "id, from and to are required"}, false)
+		return
+	}
+
+	if req.Amount <= 0 {
+		s.enqueue(quoteMessage{Type: "error", ID: req.ID, Message: "amount must be greater than zero"}, false)
+		return
+	}
+
+	s.mu.Lock()
+	if _, ok := s.subs[req.ID]; ok {
+		s.mu.Unlock()
+		s.enqueue(quoteMessage{Type: "error", ID: req.ID, Message: "subscription already exists"}, false)
+		return
+	}
+	if len(s.subs) >= quoteMaxSubscriptions {
+		s.mu.Unlock()
+		s.enqueue(quoteMessage{Type: "error", ID: req.ID, Message: "too many subscriptions"}, false)
+		return
+	}
+	s.subs[req.ID] = &quoteSubscription{from: req.From, to: req.To, amount: req.Amount}
+	s.mu.Unlock()
+
+	s.enqueue(quoteMessage{Type: "subscribed", ID: req.ID}, false)
+
+	// quotes are only computed by watchRates so updates of one subscription never reorder
+	select {
+	case s.refresh <- req.ID:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *quoteSession) unsubscribe(id string) {
+	s.mu.Lock()
+	_, ok := s.subs[id]
+	delete(s.subs, id)
+
+	if ok {
+		outbox := s.outbox[:0]
+		for _, item := range s.outbox {
+			if !(item.conflate && item.msg.ID == id) {
+				outbox = append(outbox, item)
+			}
+		}
+		s.outbox = outbox
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		s.enqueue(quoteMessage{Type: "error", ID: id, Message: "subscription not found"}, false)
+		return
+	}
+
+	s.enqueue(quoteMessage{Type: "unsubscribed", ID: id}, false)
+}
+
+// watchRates recomputes the quotes affected by every rate change
+func (s *quoteSession) watchRates() {
+	feedSub, _, _ := s.h.rateFeed.Subscribe(0, false, nil)
+	defer func() { s.h.rateFeed.Unsubscribe(feedSub) }()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+
+		case id := <-s.refresh:
+			s.quote(id)
+
+		case event, ok := <-feedSub.Events:
+			if !ok {
+				// we fell behind the feed, every quote may be outdated
+				feedSub, _, _ = s.h.rateFeed.Subscribe(0, false, nil)
+				for _, id := range s.affected(nil) {
+					s.quote(id)
+				}
+				continue
+			}
+
+			for _, id := range s.affected(&event) {
+				s.quote(id)
+			}
+		}
+	}
+}
+
+// affected returns subscriptions whose quote may change after the event, all of them for nil
+func (s *quoteSession) affected(event *models.RateEvent) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, sub := range s.subs {
+		if event == nil {
+			ids = append(ids, id)
+			continue
+		}
+
+		baseCode := event.Current.BaseCurrency.Code
+		targetCode := event.Current.TargetCurrency.Code
+
+		// a new pair touching either currency may also open a better route
+		if sub.pairs[baseCode+targetCode] ||
+			baseCode == sub.from || baseCode == sub.to ||
+			targetCode == sub.from || targetCode == sub.to {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func (s *quoteSession) quote(id string) {
+	const op = "internal.server.handlers.handlers.ExchangeCurrencyWS"
+
+	s.mu.Lock()
+	sub, ok := s.subs[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	from, to, amount := sub.from, sub.to, sub.amount
+	s.mu.Unlock()
+
+	conversion, legs, err := s.h.currencyConvertSrv.ConvertCurrencyWithLegs(s.ctx, from, to, amount)
+
+	s.mu.Lock()
+	// the subscription could have been removed while the quote was computed
+	if s.subs[id] != sub {
+		s.mu.Unlock()
+		return
+	}
+
+	var msg quoteMessage
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		_, message := conversionError(err)
+		if sub.lastErr == message {
+			s.mu.Unlock()
+			return
+		}
+		sub.last, sub.lastErr = nil, message
+		msg = quoteMessage{Type: "error", ID: id, Message: message}
+	} else {
+		if sub.last != nil && sub.last.Rate == conversion.Rate && sub.last.Stale == conversion.Stale {
+			s.mu.Unlock()
+			return
+		}
+		sub.pairs = make(map[string]bool, len(legs))
+		for _, leg := range legs {
+			sub.pairs[leg.BaseCurrency.Code+leg.TargetCurrency.Code] = true
+		}
+		sub.last, sub.lastErr = &conversion, ""
+		msg = quoteMessage{Type: "quote", ID: id, Conversion: &conversion}
+	}
+	s.mu.Unlock()
+
+	s.enqueue(msg, true)
+}
+
+// enqueue adds a message to the outbox, a conflated message replaces the queued update
+// of the same subscription, a client that lets the outbox overflow is disconnected
+func (s *quoteSession) enqueue(msg quoteMessage, conflate bool) {
+	const op = "internal.server.handlers.handlers.ExchangeCurrencyWS"
+
+	s.mu.Lock()
+
+	replaced := false
+	if conflate {
+		for i, item := range s.outbox {
+			if item.conflate && item.msg.ID == msg.ID {
+				s.outbox[i].msg = msg
+				replaced = true
+				break
+			}
+		}
+	}
+
+	if !replaced {
+		if len(s.outbox) >= quoteMaxOutbox {
+			s.mu.Unlock()
+			log.Printf("%s: client is too slow, closing the connection", op)
+			s.cancel()
+			return
+		}
+		s.outbox = append(s.outbox, outboxItem{msg: msg, conflate: conflate})
+	}
+
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *quoteSession) writeLoop() {
+	const op = "internal.server.handlers.handlers.ExchangeCurrencyWS"
+
+	ticker := time.NewTicker(quotePingPeriod)
+	defer ticker.Stop()
+	// closing the connection unblocks readLoop when writing fails
+	defer s.conn.Close()
+	defer s.cancel()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(quoteWriteWait))
+			return
+
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(quoteWriteWait))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-s.wake:
+			s.mu.Lock()
+			outbox := s.outbox
+			s.outbox = nil
+			s.mu.Unlock()
+
+			for _, item := range outbox {
+				s.conn.SetWriteDeadline(time.Now().Add(quoteWriteWait))
+				if err := s.conn.WriteJSON(item.msg); err != nil {
+					if !errors.Is(err, websocket.ErrCloseSent) {
+						log.Printf("%s: %v", op, err)
+					}
+					return
+				}
+			}
+		}
+	}
+}
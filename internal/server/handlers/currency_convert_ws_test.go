@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// quoteRoute is how a stubbed conversion is computed
+type quoteRoute struct {
+	rate float64
+	legs []string
+}
+
+// routedConversions converts along fixed routes by the pair they convert, other pairs have no rate
+type routedConversions struct {
+	currencyConvertService
+
+	mu     sync.Mutex
+	routes map[string]quoteRoute
+}
+
+func (c *routedConversions) setRoute(pair string, route quoteRoute) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routes[pair] = route
+}
+
+func (c *routedConversions) ConvertCurrencyWithLegs(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, []models.ExchangeRate, error) {
+	c.mu.Lock()
+	route, ok := c.routes[fromCode+toCode]
+	c.mu.Unlock()
+	if !ok {
+		return models.CurrencyConversion{}, nil, fmt.Errorf("convert %s%s: %w", fromCode, toCode, repository.ErrExchangeRateNotFound)
+	}
+
+	legs := make([]models.ExchangeRate, len(route.legs))
+	for i, leg := range route.legs {
+		legs[i] = models.ExchangeRate{BaseCurrency: models.Currency{Code: leg[:3]}, TargetCurrency: models.Currency{Code: leg[3:]}}
+	}
+
+	return models.CurrencyConversion{
+		BaseCurrency:    models.Currency{Code: fromCode},
+		TargetCurrency:  models.Currency{Code: toCode},
+		Rate:            route.rate,
+		Amount:          amount,
+		ConvertedAmount: amount * route.rate,
+	}, legs, nil
+}
+
+// dialQuotes connects to ExchangeCurrencyWS served with the conversions and the feed
+func dialQuotes(t *testing.T, conversions currencyConvertService, feed rateFeed) *websocket.Conn {
+	t.Helper()
+
+	h := New(nil, nil, conversions, nil, feed, nil, nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(h.ExchangeCurrencyWS))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func sendQuoteRequest(t *testing.T, conn *websocket.Conn, req quoteRequest) {
+	t.Helper()
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("write %+v: %v", req, err)
+	}
+}
+
+// readQuoteMessage reads the next message and checks its type and subscription
+func readQuoteMessage(t *testing.T, conn *websocket.Conn, wantType, wantID string) quoteMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg quoteMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if msg.Type != wantType || msg.ID != wantID {
+		t.Fatalf("message = %+v, want %s of %q", msg, wantType, wantID)
+	}
+	return msg
+}
+
+func TestExchangeCurrencyWSSubscribeAndUnsubscribe(t *testing.T) {
+	conversions := &routedConversions{routes: map[string]quoteRoute{"USDEUR": {rate: 0.9, legs: []string{"USDEUR"}}}}
+	feed := service.NewRateFeed(10)
+	conn := dialQuotes(t, conversions, feed)
+
+	sendQuoteRequest(t, conn, quoteRequest{Type: "subscribe", ID: "q1", From: "USD", To: "EUR", Amount: 100})
+	readQuoteMessage(t, conn, "subscribed", "q1")
+	if msg := readQuoteMessage(t, conn, "quote", "q1"); msg.Conversion.ConvertedAmount != 90 {
+		t.Errorf("quote = %+v, want 90 EUR", msg.Conversion)
+	}
+
+	sendQuoteRequest(t, conn, quoteRequest{Type: "subscribe", ID: "q1", From: "USD", To: "EUR", Amount: 5})
+	readQuoteMessage(t, conn, "error", "q1")
+
+	sendQuoteRequest(t, conn, quoteRequest{Type: "unsubscribe", ID: "q1"})
+	readQuoteMessage(t, conn, "unsubscribed", "q1")
+
+	// no quote follows a change once the subscription is gone, the next message answers the
+	// second unsubscribe
+	conversions.setRoute("USDEUR", quoteRoute{rate: 0.95, legs: []string{"USDEUR"}})
+	feed.RateChanged(context.Background(), rateChange("USDEUR", 0.95))
+	sendQuoteRequest(t, conn, quoteRequest{Type: "unsubscribe", ID: "q1"})
+	if msg := readQuoteMessage(t, conn, "error", "q1"); msg.Message != "subscription not found" {
+		t.Errorf("error = %q, want subscription not found", msg.Message)
+	}
+}
+
+func TestExchangeCurrencyWSRequotesOnLegChange(t *testing.T) {
+	// RUB to JPY goes through USD and EUR, a change of USDEUR touches neither currency
+	legs := []string{"USDRUB", "USDEUR", "EURJPY"}
+	conversions := &routedConversions{routes: map[string]quoteRoute{"RUBJPY": {rate: 1.6, legs: legs}}}
+	feed := service.NewRateFeed(10)
+	conn := dialQuotes(t, conversions, feed)
+
+	sendQuoteRequest(t, conn, quoteRequest{Type: "subscribe", ID: "q1", From: "RUB", To: "JPY", Amount: 1000})
+	readQuoteMessage(t, conn, "subscribed", "q1")
+	readQuoteMessage(t, conn, "quote", "q1")
+
+	conversions.setRoute("RUBJPY", quoteRoute{rate: 1.7, legs: legs})
+	feed.RateChanged(context.Background(), rateChange("USDEUR", 0.95))
+
+	if msg := readQuoteMessage(t, conn, "quote", "q1"); msg.Conversion.Rate != 1.7 {
+		t.Errorf("requote = %+v, want rate 1.7", msg.Conversion)
+	}
+}
+
+func TestExchangeCurrencyWSUnknownPair(t *testing.T) {
+	conn := dialQuotes(t, &routedConversions{routes: map[string]quoteRoute{}}, service.NewRateFeed(10))
+
+	sendQuoteRequest(t, conn, quoteRequest{Type: "subscribe", ID: "q1", From: "XXX", To: "YYY", Amount: 1})
+	readQuoteMessage(t, conn, "subscribed", "q1")
+	if msg := readQuoteMessage(t, conn, "error", "q1"); msg.Message != "exchange rate not found" {
+		t.Errorf("error = %q, want exchange rate not found", msg.Message)
+	}
+}
+
+func TestQuoteSessionClosesSlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// nothing drains the outbox, like a client that stopped reading
+	s := &quoteSession{ctx: ctx, cancel: cancel, subs: make(map[string]*quoteSubscription), wake: make(chan struct{}, 1)}
+
+	s.enqueue(quoteMessage{Type: "quote", ID: "q1"}, true)
+	for len(s.outbox) < quoteMaxOutbox {
+		s.enqueue(quoteMessage{Type: "error", Message: "invalid message format"}, false)
+	}
+
+	// a newer quote replaces the queued one and takes no room
+	s.enqueue(quoteMessage{Type: "quote", ID: "q1"}, true)
+	if ctx.Err() != nil {
+		t.Fatal("session closed while the outbox still had room")
+	}
+
+	s.enqueue(quoteMessage{Type: "error", Message: "invalid message format"}, false)
+	if ctx.Err() == nil {
+		t.Error("session still open after the outbox overflowed")
+	}
+	if len(s.outbox) != quoteMaxOutbox {
+		t.Errorf("outbox holds %d messages, want %d", len(s.outbox), quoteMaxOutbox)
+	}
+}
@@ -6,34 +6,88 @@ import (
 	"errors"
 	"exchanger/internal/models"
 	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"sort"
+	"time"
 )
 
+// maxRateUpdates limits how many pairs one bulk update may set
+const maxRateUpdates = 1000
+
 type exchangeRateService interface {
 	GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	ListExchangeRates(ctx context.Context, filter models.ExchangeRateFilter, page models.PageRequest) (models.Page[models.ExchangeRate], error)
 	GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error)
+	GetExchangeRatesByCurrencyCodes(ctx context.Context, codes []string) ([]models.ExchangeRate, error)
+	GetExchangeRateHistory(ctx context.Context, exchangeRateIDs []int, since time.Time, limit int) ([]models.RateHistoryPoint, error)
 	AddExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error)
-	UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error)
+	UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, version int64) (models.ExchangeRate, error)
+	UpdateExchangeRates(ctx context.Context, updates []models.RateUpdate) (models.RateGeneration, error)
+	DeleteExchangeRate(ctx context.Context, baseCode, targetCode string) error
+	ListRateProposals(ctx context.Context, filter models.RateProposalFilter, page models.PageRequest) (models.Page[models.RateProposal], error)
+	GetRateProposal(ctx context.Context, id int) (models.RateProposal, error)
+	ApproveRateProposal(ctx context.Context, id int) (models.RateProposal, error)
+	RejectRateProposal(ctx context.Context, id int, reason string) (models.RateProposal, error)
+	ScheduleExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, effectiveFrom time.Time) (models.ScheduledRate, error)
+	ScheduleExchangeRateUpdate(ctx context.Context, baseCode, targetCode string, rate float64, version int64, effectiveFrom time.Time) (models.ScheduledRate, error)
+	ListScheduledRates(ctx context.Context, filter models.ScheduledRateFilter, page models.PageRequest) (models.Page[models.ScheduledRate], error)
+	GetScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error)
+	CancelScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error)
+	FindArbitrage(ctx context.Context, filter models.ArbitrageFilter) (models.ArbitrageReport, error)
+	GetRateCandles(ctx context.Context, baseCode, targetCode, interval string, from, to time.Time) ([]models.RateCandle, error)
+	GetRateStats(ctx context.Context, baseCode, targetCode, interval string, window int, to time.Time) (models.RateStats, error)
 }
 
+// GetExchangeRates lists rates a page at a time. ?base=USD, ?target=EUR, ?codePrefix=U,
+// ?updatedSince=<RFC 3339 time> and ?source=api narrow the list, ?sort=base|target|rate|updatedAt|id
+// (with "-" for descending order) orders it.
 func (h *Handlers) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
 	const op = "internal.server.handlers.handlers.GetExchangeRates"
 
-	rates, err := h.exchangeRateSrv.GetAllExchangeRates(r.Context())
+	query := r.URL.Query()
+
+	var invalid validation
+	page := parsePageRequest(query, &invalid)
+
+	filter := models.ExchangeRateFilter{
+		BaseCode:   query.Get("base"),
+		TargetCode: query.Get("target"),
+		CodePrefix: query.Get("codePrefix"),
+		Source:     query.Get("source"),
+	}
+	if v := query.Get("updatedSince"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			invalid.add("updatedSince", "invalid_format", "updatedSince must be an RFC 3339 time")
+		}
+		filter.UpdatedSince = since
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	result, err := h.exchangeRateSrv.ListExchangeRates(r.Context(), filter, page)
 	if err != nil {
 		log.Printf("%s: %v", op, err)
+		if pageErrorJSON(w, err) {
+			return
+		}
 		if errors.Is(err, repository.ErrExchangeRateNotFound) {
-			errorJSON(w, "exchange rates not found", http.StatusNotFound)
+			errorJSON(w, problemRateNotFound, "exchange rates not found")
 			return
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		errorJSON(w, problemInternal, "internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rates)
+	writePageHeaders(w, r, result.Total, result.NextCursor)
+	writeTaggedJSON(w, r, "", result.Items)
 }
 
 func (h *Handlers) GetExchangeRate(w http.ResponseWriter, r *http.Request) {
@@ -42,7 +96,8 @@ func (h *Handlers) GetExchangeRate(w http.ResponseWriter, r *http.Request) {
 	pair := r.PathValue("pair")
 	if len(pair) < 6 {
 		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "invalid currency pair format", http.StatusBadRequest)
+		errorJSON(w, problemInvalidPair, "invalid currency pair format",
+			FieldError{Field: "pair", Code: "invalid_format", Message: "pair must be two currency codes, e.g. USDEUR"})
 		return
 	}
 
@@ -54,74 +109,100 @@ func (h *Handlers) GetExchangeRate(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("%s: %v", op, err)
 		if errors.Is(err, repository.ErrExchangeRateNotFound) {
-			errorJSON(w, "exchange rate not found", http.StatusNotFound)
+			errorJSON(w, problemRateNotFound, "exchange rate not found")
 			return
 		}
 		if errors.Is(err, repository.ErrCurrencyNotFound) {
-			errorJSON(w, "currency not found", http.StatusNotFound)
+			errorJSON(w, problemCurrencyNotFound, "currency not found")
 			return
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		errorJSON(w, problemInternal, "internal server error")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rate)
+	writeTaggedJSON(w, r, versionETag(rate.Version), rate)
+}
+
+type createExchangeRateRequest struct {
+	BaseCurrencyCode   string   `json:"baseCurrencyCode"`
+	TargetCurrencyCode string   `json:"targetCurrencyCode"`
+	Rate               *float64 `json:"rate"`
+	// EffectiveFrom is an RFC 3339 time that schedules the rate instead of setting it now
+	EffectiveFrom string `json:"effectiveFrom"`
+}
+
+type updateExchangeRateRequest struct {
+	Rate          *float64 `json:"rate"`
+	EffectiveFrom string   `json:"effectiveFrom"`
 }
 
 func (h *Handlers) CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
 	const op = "internal.server.handlers.handlers.CreateExchangeRate"
 
-	if err := r.ParseForm(); err != nil {
+	var req createExchangeRateRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
 		log.Printf("%s: %v", op, err)
-		errorJSON(w, "invalid form data", http.StatusBadRequest)
+		bodyErrorJSON(w, err)
 		return
 	}
 
-	baseCode := r.PostFormValue("baseCurrencyCode")
-	targetCode := r.PostFormValue("targetCurrencyCode")
-	rateStr := r.PostFormValue("rate")
-
-	if baseCode == "" || targetCode == "" || rateStr == "" {
+	invalid.require(req.BaseCurrencyCode != "", "baseCurrencyCode")
+	invalid.require(req.TargetCurrencyCode != "", "targetCurrencyCode")
+	invalid.require(req.Rate != nil, "rate")
+	effectiveFrom := parseEffectiveFrom(req.EffectiveFrom, &invalid)
+	if len(invalid) > 0 {
 		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "all fields are required", http.StatusBadRequest)
+		validationErrorJSON(w, invalid)
 		return
 	}
 
-	rate, err := strconv.ParseFloat(rateStr, 64)
-	if err != nil {
-		log.Printf("%s: %v", op, err)
-		errorJSON(w, "invalid rate format", http.StatusBadRequest)
+	if !effectiveFrom.IsZero() {
+		scheduled, err := h.exchangeRateSrv.ScheduleExchangeRate(r.Context(), req.BaseCurrencyCode, req.TargetCurrencyCode, *req.Rate, effectiveFrom)
+		writeScheduledRate(w, op, scheduled, err)
 		return
 	}
 
-	createdRate, err := h.exchangeRateSrv.AddExchangeRate(r.Context(), baseCode, targetCode, rate)
+	createdRate, err := h.exchangeRateSrv.AddExchangeRate(r.Context(), req.BaseCurrencyCode, req.TargetCurrencyCode, *req.Rate)
 	if err != nil {
 		log.Printf("%s: %v", op, err)
+		if rateErrorJSON(w, err, createRateField) {
+			return
+		}
 		if errors.Is(err, repository.ErrCurrencyNotFound) {
-			errorJSON(w, "one or both currencies not found", http.StatusNotFound)
+			errorJSON(w, problemCurrencyNotFound, "one or both currencies not found")
 			return
 		}
 		if errors.Is(err, repository.ErrExchangeRateExists) {
-			errorJSON(w, "exchange rate already exists", http.StatusConflict)
+			errorJSON(w, problemRateExists, "exchange rate already exists")
 			return
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		if errors.Is(err, repository.ErrCurrencyInactive) {
+			errorJSON(w, problemCurrencyInactive, "one or both currencies are inactive")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(createdRate.Version))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdRate)
 }
 
+// UpdateExchangeRate sets the rate of a pair. With If-Match it is only set while the pair is still
+// at the version of that ETag, so concurrent edits do not overwrite each other. A change that
+// needs approval is answered with 202 and the proposal waiting for it, one with effectiveFrom
+// with 202 and the scheduled change, which is only set while the pair is still at that version.
 func (h *Handlers) UpdateExchangeRate(w http.ResponseWriter, r *http.Request) {
 	const op = "internal.server.handlers.handlers.UpdateExchangeRate"
 
 	pair := r.PathValue("pair")
 	if len(pair) < 6 {
 		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "invalid currency pair format", http.StatusBadRequest)
+		errorJSON(w, problemInvalidPair, "invalid currency pair format",
+			FieldError{Field: "pair", Code: "invalid_format", Message: "pair must be two currency codes, e.g. USDEUR"})
 		return
 	}
 
@@ -129,41 +210,251 @@ func (h *Handlers) UpdateExchangeRate(w http.ResponseWriter, r *http.Request) {
 	baseCode := pair[:3]
 	targetCode := pair[3:]
 
-	if err := r.ParseForm(); err != nil {
+	var req updateExchangeRateRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
 		log.Printf("%s: %v", op, err)
-		errorJSON(w, "invalid form data", http.StatusBadRequest)
+		bodyErrorJSON(w, err)
 		return
 	}
 
-	rateStr := r.PostFormValue("rate")
-	if rateStr == "" {
+	invalid.require(req.Rate != nil, "rate")
+	effectiveFrom := parseEffectiveFrom(req.EffectiveFrom, &invalid)
+	if len(invalid) > 0 {
 		log.Printf("%s: %v", op, ErrInvalidInputData)
-		errorJSON(w, "rate is required", http.StatusBadRequest)
+		validationErrorJSON(w, invalid)
 		return
 	}
 
-	rate, err := strconv.ParseFloat(rateStr, 64)
-	if err != nil {
-		log.Printf("%s: %v", op, err)
-		errorJSON(w, "invalid rate format", http.StatusBadRequest)
+	version, err := h.rateIfMatch(r, baseCode, targetCode)
+	if !effectiveFrom.IsZero() {
+		var scheduled models.ScheduledRate
+		if err == nil {
+			scheduled, err = h.exchangeRateSrv.ScheduleExchangeRateUpdate(r.Context(), baseCode, targetCode, *req.Rate, version, effectiveFrom)
+		}
+		writeScheduledRate(w, op, scheduled, err)
 		return
 	}
 
-	updatedRate, err := h.exchangeRateSrv.UpdateExchangeRate(r.Context(), baseCode, targetCode, rate)
+	var updatedRate models.ExchangeRate
+	if err == nil {
+		updatedRate, err = h.exchangeRateSrv.UpdateExchangeRate(r.Context(), baseCode, targetCode, *req.Rate, version)
+	}
+	var pending *service.PendingApprovalError
+	if errors.As(err, &pending) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/v1/rateProposal/%d", pending.Proposal.ID))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(pending.Proposal)
+		return
+	}
 	if err != nil {
 		log.Printf("%s: %v", op, err)
+		if rateErrorJSON(w, err, updateRateField) {
+			return
+		}
 		if errors.Is(err, repository.ErrExchangeRateNotFound) {
-			errorJSON(w, "exchange rate not found", http.StatusNotFound)
+			errorJSON(w, problemRateNotFound, "exchange rate not found")
 			return
 		}
 		if errors.Is(err, repository.ErrCurrencyNotFound) {
-			errorJSON(w, "one or both currencies not found", http.StatusNotFound)
+			errorJSON(w, problemCurrencyNotFound, "one or both currencies not found")
 			return
 		}
-		errorJSON(w, "internal server error", http.StatusInternalServerError)
+		if errors.Is(err, repository.ErrVersionMismatch) {
+			errorJSON(w, problemPreconditionFailed, "exchange rate was changed since it was read, get it again and retry")
+			return
+		}
+		if errors.Is(err, service.ErrVersionRequired) {
+			errorJSON(w, problemPreconditionRequired, "If-Match header with the ETag of the exchange rate is required")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(updatedRate.Version))
 	json.NewEncoder(w).Encode(updatedRate)
 }
+
+type updateExchangeRatesRequest struct {
+	Rates    map[string]float64 `json:"rates"`
+	Versions map[string]int64   `json:"versions"`
+}
+
+// UpdateExchangeRates sets {"rates": {"USDEUR": 0.92, ...}} in one transaction, conversions see
+// either none or all of the new rates. When a pair has no rate none of them is updated, neither
+// when one is no longer at its version in the optional {"versions": {"USDEUR": 3, ...}}.
+func (h *Handlers) UpdateExchangeRates(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.UpdateExchangeRates"
+
+	var req updateExchangeRatesRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		bodyErrorJSON(w, err)
+		return
+	}
+
+	invalid.require(len(req.Rates) > 0, "rates")
+	if len(req.Rates) > maxRateUpdates {
+		invalid.add("rates", "too_many", fmt.Sprintf("at most %d rates can be updated at once", maxRateUpdates))
+	}
+
+	pairs := make([]string, 0, len(req.Rates))
+	for pair := range req.Rates {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	updates := make([]models.RateUpdate, 0, len(pairs))
+	for _, pair := range pairs {
+		rate := req.Rates[pair]
+		// it supposed that each code is three symbols length
+		if len(pair) != 6 {
+			invalid.add("rates."+pair, "invalid_format", "pair must be two currency codes, e.g. USDEUR")
+			continue
+		}
+		if rate <= 0 {
+			invalid.add("rates."+pair, "invalid", "rate must be greater than zero")
+			continue
+		}
+		updates = append(updates, models.RateUpdate{BaseCurrencyCode: pair[:3], TargetCurrencyCode: pair[3:], Rate: rate, Version: req.Versions[pair]})
+	}
+	versioned := make([]string, 0, len(req.Versions))
+	for pair := range req.Versions {
+		versioned = append(versioned, pair)
+	}
+	sort.Strings(versioned)
+	for _, pair := range versioned {
+		if _, ok := req.Rates[pair]; !ok {
+			invalid.add("versions."+pair, "invalid", "pair is not in rates")
+		} else if req.Versions[pair] <= 0 {
+			invalid.add("versions."+pair, "invalid", "version must be greater than zero")
+		}
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	generation, err := h.exchangeRateSrv.UpdateExchangeRates(r.Context(), updates)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if rateErrorJSON(w, err, func(rateErr *service.RateError) string { return "rates." + rateErr.Pair }) {
+			return
+		}
+		var pairsErr *repository.PairsError
+		if errors.As(err, &pairsErr) && errors.Is(err, repository.ErrExchangeRateNotFound) {
+			missing := make([]FieldError, len(pairsErr.Pairs))
+			for i, pair := range pairsErr.Pairs {
+				missing[i] = FieldError{Field: "rates." + pair, Code: "rate_not_found", Message: "exchange rate not found"}
+			}
+			errorJSON(w, problemRateNotFound, "no rate was updated, create the missing pairs first", missing...)
+			return
+		}
+		if errors.As(err, &pairsErr) && errors.Is(err, service.ErrApprovalRequired) {
+			held := make([]FieldError, len(pairsErr.Pairs))
+			for i, pair := range pairsErr.Pairs {
+				held[i] = FieldError{Field: "rates." + pair, Code: "approval_required", Message: "change needs approval"}
+			}
+			errorJSON(w, problemApprovalRequired, "no rate was updated, update the held pairs one at a time to propose them", held...)
+			return
+		}
+		if errors.As(err, &pairsErr) && errors.Is(err, service.ErrVersionRequired) {
+			unversioned := make([]FieldError, len(pairsErr.Pairs))
+			for i, pair := range pairsErr.Pairs {
+				unversioned[i] = FieldError{Field: "versions." + pair, Code: "required", Message: "version of the exchange rate is required"}
+			}
+			errorJSON(w, problemPreconditionRequired, "no rate was updated, give the version of every pair", unversioned...)
+			return
+		}
+		if errors.As(err, &pairsErr) && errors.Is(err, repository.ErrVersionMismatch) {
+			changed := make([]FieldError, len(pairsErr.Pairs))
+			for i, pair := range pairsErr.Pairs {
+				changed[i] = FieldError{Field: "versions." + pair, Code: "version_mismatch", Message: "exchange rate was changed since it was read"}
+			}
+			errorJSON(w, problemPreconditionFailed, "no rate was updated, get the changed pairs again and retry", changed...)
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(generation)
+}
+
+// rateErrorJSON writes the problem of the rates the guard rejected, field names the request field
+// of each. It reports false when err is not about rejected rates.
+func rateErrorJSON(w http.ResponseWriter, err error, field func(rateErr *service.RateError) string) bool {
+	var rateErrs service.RateErrors
+	var rateErr *service.RateError
+	switch {
+	case errors.As(err, &rateErrs):
+	case errors.As(err, &rateErr):
+		rateErrs = service.RateErrors{rateErr}
+	default:
+		return false
+	}
+
+	fields := make([]FieldError, len(rateErrs))
+	for i, e := range rateErrs {
+		fields[i] = FieldError{Field: field(e), Code: e.Code, Message: e.Message}
+	}
+	errorJSON(w, problemValidationFailed, "request has invalid fields", fields...)
+	return true
+}
+
+func createRateField(rateErr *service.RateError) string {
+	switch rateErr.Code {
+	case "same_currency":
+		return "targetCurrencyCode"
+	case "derived":
+		return "baseCurrencyCode"
+	}
+	return "rate"
+}
+
+func updateRateField(rateErr *service.RateError) string {
+	if rateErr.Code == "same_currency" || rateErr.Code == "derived" {
+		return "pair"
+	}
+	return "rate"
+}
+
+// DeleteExchangeRate removes a pair from lists and conversions, its history stays readable and
+// creating the pair again brings it back
+func (h *Handlers) DeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.DeleteExchangeRate"
+
+	pair := r.PathValue("pair")
+	if len(pair) < 6 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		errorJSON(w, problemInvalidPair, "invalid currency pair format",
+			FieldError{Field: "pair", Code: "invalid_format", Message: "pair must be two currency codes, e.g. USDEUR"})
+		return
+	}
+
+	// it supposed that each code is three symbols length
+	baseCode := pair[:3]
+	targetCode := pair[3:]
+
+	if err := h.exchangeRateSrv.DeleteExchangeRate(r.Context(), baseCode, targetCode); err != nil {
+		log.Printf("%s: %v", op, err)
+		if rateErrorJSON(w, err, updateRateField) {
+			return
+		}
+		if errors.Is(err, repository.ErrExchangeRateNotFound) {
+			errorJSON(w, problemRateNotFound, "exchange rate not found")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
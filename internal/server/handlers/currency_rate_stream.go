@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"exchanger/internal/models"
+	"exchanger/internal/service"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type rateFeed interface {
+	Subscribe(lastEventID uint64, resume bool, filter func(models.RateEvent) bool) (*service.RateSubscription, []models.RateEvent, bool)
+	Unsubscribe(sub *service.RateSubscription)
+}
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamRetryMillis       = 3000
+)
+
+// StreamExchangeRates pushes rate changes as Server-Sent Events. The stream can be
+// narrowed with ?pair=USDRUB,EURUSD or ?currency=USD,RUB and resumed with Last-Event-ID,
+// a "reset" event means some changes were missed and the client should refetch the rates.
+// A deleted pair is sent as a "delete" event.
+func (h *Handlers) StreamExchangeRates(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.StreamExchangeRates"
+
+	var lastEventID uint64
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("lastEventId")
+	}
+	resume := lastEventIDStr != ""
+	if resume {
+		var err error
+		lastEventID, err = strconv.ParseUint(lastEventIDStr, 10, 64)
+		if err != nil {
+			log.Printf("%s: %v", op, err)
+			errorJSON(w, problemInvalidRequest, "invalid Last-Event-ID",
+				FieldError{Field: "Last-Event-ID", Code: "invalid_format", Message: "Last-Event-ID must be an event id"})
+			return
+		}
+	}
+
+	filter := service.RateEventFilter(
+		strings.Split(r.URL.Query().Get("pair"), ","),
+		strings.Split(r.URL.Query().Get("currency"), ","),
+	)
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sub, backlog, complete := h.rateFeed.Subscribe(lastEventID, resume, filter)
+	defer h.rateFeed.Unsubscribe(sub)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		if err := writeRateEvent(w, event); err != nil {
+			log.Printf("%s: %v", op, err)
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events:
+			if !ok {
+				// the client fell too far behind, it reconnects with Last-Event-ID
+				return
+			}
+			if err := writeRateEvent(w, event); err != nil {
+				log.Printf("%s: %v", op, err)
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeRateEvent(w http.ResponseWriter, event models.RateEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	name := "rate"
+	if event.Deleted {
+		name = "delete"
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, name, data)
+	return err
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"exchanger/internal/models"
+	"exchanger/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one event read from the stream, the retry block and heartbeats are skipped
+type sseEvent struct {
+	id    uint64
+	name  string
+	event models.RateEvent
+}
+
+// rateStream is a connection to StreamExchangeRates
+type rateStream struct {
+	t      *testing.T
+	reader *bufio.Reader
+}
+
+func rateChange(pair string, rate float64) models.RateChange {
+	return models.RateChange{Current: models.ExchangeRate{
+		BaseCurrency:   models.Currency{Code: pair[:3]},
+		TargetCurrency: models.Currency{Code: pair[3:]},
+		Rate:           rate,
+	}}
+}
+
+// publishingFeed is the rate feed of the service, which the stream reads and the tests publish to
+type publishingFeed interface {
+	rateFeed
+	RateChanged(ctx context.Context, change models.RateChange)
+}
+
+// loggedIDs publishes the changes and returns the ids the feed gave them
+func loggedIDs(t *testing.T, feed publishingFeed, changes ...models.RateChange) []uint64 {
+	t.Helper()
+
+	// a subscriber sees every change, also those the log no longer holds
+	sub, _, _ := feed.Subscribe(0, false, nil)
+	defer feed.Unsubscribe(sub)
+
+	ids := make([]uint64, len(changes))
+	for i, change := range changes {
+		feed.RateChanged(context.Background(), change)
+		ids[i] = (<-sub.Events).ID
+	}
+	return ids
+}
+
+// openRateStream connects to the stream of feed with the query and Last-Event-ID, when it is not empty
+func openRateStream(t *testing.T, feed rateFeed, query, lastEventID string) *rateStream {
+	t.Helper()
+
+	h := New(nil, nil, nil, nil, feed, nil, nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(h.StreamExchangeRates))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/exchangeRates/stream?"+query, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream answered %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	return &rateStream{t: t, reader: bufio.NewReader(resp.Body)}
+}
+
+// next reads the next named event
+func (s *rateStream) next() sseEvent {
+	s.t.Helper()
+
+	var e sseEvent
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("read stream: %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			if e.id, err = strconv.ParseUint(value, 10, 64); err != nil {
+				s.t.Fatalf("event id %q: %v", value, err)
+			}
+		case "event":
+			e.name = value
+		case "data":
+			if err := json.Unmarshal([]byte(value), &e.event); err != nil {
+				s.t.Fatalf("event data %q: %v", value, err)
+			}
+		case "":
+			if e.name != "" {
+				return e
+			}
+		}
+	}
+}
+
+func TestStreamExchangeRatesResumes(t *testing.T) {
+	feed := service.NewRateFeed(10)
+	ids := loggedIDs(t, feed, rateChange("USDEUR", 0.9), rateChange("USDEUR", 0.91), rateChange("USDEUR", 0.92))
+
+	stream := openRateStream(t, feed, "", strconv.FormatUint(ids[0], 10))
+
+	// the changes after Last-Event-ID without a reset, the stream has subscribed once they are read
+	for _, want := range ids[1:] {
+		if e := stream.next(); e.name != "rate" || e.id != want {
+			t.Fatalf("event = %s %d, want rate %d", e.name, e.id, want)
+		}
+	}
+
+	feed.RateChanged(context.Background(), rateChange("USDEUR", 0.93))
+	e := stream.next()
+	if e.name != "rate" || e.id != ids[2]+1 || e.event.Current.Rate != 0.93 {
+		t.Errorf("live event = %s %d %+v, want rate %d at 0.93", e.name, e.id, e.event.Current, ids[2]+1)
+	}
+}
+
+func TestStreamExchangeRatesResetsAfterGap(t *testing.T) {
+	feed := service.NewRateFeed(2)
+	ids := loggedIDs(t, feed, rateChange("USDEUR", 0.9), rateChange("USDEUR", 0.91), rateChange("USDEUR", 0.92), rateChange("USDEUR", 0.93))
+
+	// the event after ids[0] has already left the log of 2
+	stream := openRateStream(t, feed, "", strconv.FormatUint(ids[0], 10))
+
+	if e := stream.next(); e.name != "reset" {
+		t.Fatalf("first event = %s, want reset", e.name)
+	}
+	for _, want := range ids[2:] {
+		if e := stream.next(); e.name != "rate" || e.id != want {
+			t.Fatalf("event = %s %d, want rate %d", e.name, e.id, want)
+		}
+	}
+}
+
+func TestStreamExchangeRatesFiltersPairs(t *testing.T) {
+	feed := service.NewRateFeed(10)
+	ids := loggedIDs(t, feed, rateChange("USDEUR", 0.9), rateChange("USDRUB", 90), rateChange("EURGBP", 0.85))
+
+	stream := openRateStream(t, feed, "pair=usdrub", strconv.FormatUint(ids[0]-1, 10))
+
+	if e := stream.next(); e.name != "rate" || e.id != ids[1] {
+		t.Fatalf("backlog event = %s %d, want rate %d of USDRUB", e.name, e.id, ids[1])
+	}
+
+	feed.RateChanged(context.Background(), rateChange("USDEUR", 0.91))
+	deleted := rateChange("USDRUB", 91)
+	deleted.Deleted = true
+	feed.RateChanged(context.Background(), deleted)
+
+	e := stream.next()
+	if e.name != "delete" || e.event.Current.BaseCurrency.Code+e.event.Current.TargetCurrency.Code != "USDRUB" {
+		t.Errorf("live event = %s of %+v, want the delete of USDRUB", e.name, e.event.Current)
+	}
+}
+
+func TestStreamExchangeRatesInvalidLastEventID(t *testing.T) {
+	h := New(nil, nil, nil, nil, service.NewRateFeed(10), nil, nil, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/exchangeRates/stream", nil)
+	r.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+	h.StreamExchangeRates(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"exchanger/api"
+	"log"
+	"net/http"
+)
+
+func (h *Handlers) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetOpenAPI"
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(api.OpenAPI); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+}
+
+func (h *Handlers) GetDocs(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetDocs"
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(api.Docs); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"exchanger/internal/repository"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag is the entity tag of a single currency or rate
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// etagMatches tells whether an If-Match or If-None-Match header lists the tag. If-None-Match
+// compares weakly, so a W/ prefix is ignored, If-Match only accepts the strong tag.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTaggedJSON answers a read with v and its ETag, or with 304 Not Modified when If-None-Match
+// lists the tag. An empty etag is taken from the hash of the body, which is how lists are tagged.
+func writeTaggedJSON(w http.ResponseWriter, r *http.Request, etag string, v any) {
+	const op = "internal.server.handlers.handlers.writeTaggedJSON"
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+	body = append(body, '\n')
+
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+	w.Header().Set("ETag", etag)
+
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// rateIfMatch returns the version the If-Match header of a rate write requires, 0 without the
+// header. A tag that is not the current one fails with repository.ErrVersionMismatch, as does the
+// write when the rate changes after this check.
+func (h *Handlers) rateIfMatch(r *http.Request, baseCode, targetCode string) (int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	current, err := h.exchangeRateSrv.GetExchangeRate(r.Context(), baseCode, targetCode)
+	if err != nil {
+		return 0, err
+	}
+	if !etagMatches(header, versionETag(current.Version), false) {
+		return 0, repository.ErrVersionMismatch
+	}
+
+	return current.Version, nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, false, true},
+		{`"2", "3"`, false, true},
+		{`*`, false, true},
+		{`"2"`, false, false},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{`3`, true, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, versionETag(3), tt.weak); got != tt.want {
+			t.Errorf("etagMatches(%q, weak %v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTaggedJSON(t *testing.T) {
+	get := func(ifNoneMatch, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		writeTaggedJSON(w, r, etag, map[string]int{"rate": 1})
+		return w
+	}
+
+	first := get("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.Len() == 0 {
+		t.Fatalf("first read = %d with ETag %q, want 200 with a body hash tag", first.Code, etag)
+	}
+	if again := get("", ""); again.Header().Get("ETag") != etag {
+		t.Errorf("ETag of the same body = %q, want %q", again.Header().Get("ETag"), etag)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        int
+	}{
+		{"hash tag", etag, "", http.StatusNotModified},
+		{"weak hash tag", "W/" + etag, "", http.StatusNotModified},
+		{"version tag", `"7"`, versionETag(7), http.StatusNotModified},
+		{"old version tag", `"6"`, versionETag(7), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.ifNoneMatch, tt.etag)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 has a body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+// currentRate answers every rate read with a rate at version
+type currentRate struct {
+	exchangeRateService
+	version int64
+}
+
+func (s currentRate) GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error) {
+	return models.ExchangeRate{Version: s.version}, nil
+}
+
+func TestRateIfMatch(t *testing.T) {
+	h := New(nil, currentRate{version: 4}, nil, nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int64
+		wantErr error
+	}{
+		{"no header", "", 0, nil},
+		{"current version", `"4"`, 4, nil},
+		{"any version", "*", 4, nil},
+		{"old version", `"3"`, 0, repository.ErrVersionMismatch},
+		{"weak tag", `W/"4"`, 0, repository.ErrVersionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/v1/exchangeRate/USDEUR", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			version, err := h.rateIfMatch(r, "USD", "EUR")
+			if !errors.Is(err, tt.wantErr) || version != tt.want {
+				t.Errorf("rateIfMatch = %d, %v, want %d, %v", version, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// schedulingRate records the version a rate change is scheduled against
+type schedulingRate struct {
+	currentRate
+	scheduledVersion *int64
+}
+
+func (s schedulingRate) ScheduleExchangeRateUpdate(ctx context.Context, baseCode, targetCode string, rate float64, version int64, effectiveFrom time.Time) (models.ScheduledRate, error) {
+	*s.scheduledVersion = version
+	return models.ScheduledRate{ID: 1, RateVersion: version}, nil
+}
+
+func TestUpdateExchangeRateScheduledIfMatch(t *testing.T) {
+	body := fmt.Sprintf(`{"rate": 0.9, "effectiveFrom": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+
+	tests := []struct {
+		name        string
+		ifMatch     string
+		wantStatus  int
+		wantVersion int64
+	}{
+		{"no header", "", http.StatusAccepted, 0},
+		{"current version", `"4"`, http.StatusAccepted, 4},
+		{"old version", `"3"`, http.StatusPreconditionFailed, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := int64(-1)
+			h := New(nil, schedulingRate{currentRate{version: 4}, &version}, nil, nil, nil, nil, nil, nil)
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/exchangeRate/USDEUR", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			r.SetPathValue("pair", "USDEUR")
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			h.UpdateExchangeRate(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("scheduled against version %d, want %d", version, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+const graphqlMaxBodySize = 1 << 20
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// GraphQL executes queries sent as POST {"query": ..., "variables": ..., "operationName": ...}
+// or as GET ?query=...&variables=...&operationName=..., mutations are only accepted over POST
+func (h *Handlers) GraphQL(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GraphQL"
+
+	schema, err := h.graphqlSchema()
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	var req graphqlRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				log.Printf("%s: %v", op, err)
+				errorJSON(w, problemInvalidRequest, "invalid variables",
+					FieldError{Field: "variables", Code: "invalid_format", Message: "variables must be a JSON object"})
+				return
+			}
+		}
+	} else {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphqlMaxBodySize)).Decode(&req); err != nil {
+			log.Printf("%s: %v", op, err)
+			errorJSON(w, problemInvalidRequest, "invalid request body")
+			return
+		}
+	}
+
+	if req.Query == "" {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		errorJSON(w, problemValidationFailed, "query is required",
+			FieldError{Field: "query", Code: "required", Message: "query is required"})
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		graphqlErrorsJSON(w, gqlerrors.FormatErrors(err), http.StatusBadRequest)
+		return
+	}
+
+	if err := checkGraphQLLimits(doc); err != nil {
+		log.Printf("%s: %v", op, err)
+		graphqlErrorsJSON(w, gqlerrors.FormatErrors(err), http.StatusBadRequest)
+		return
+	}
+
+	validation := graphql.ValidateDocument(&schema, doc, nil)
+	if !validation.IsValid {
+		graphqlErrorsJSON(w, validation.Errors, http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet && hasMutation(doc, req.OperationName) {
+		w.Header().Set("Allow", http.MethodPost)
+		graphqlErrorsJSON(w, gqlerrors.FormatErrors(errors.New("mutations must be sent with POST")), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlLoadersKey{}, h.newGraphQLLoaders(r.Context()))
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+}
+
+func hasMutation(doc *ast.Document, operationName string) bool {
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (operation.Name == nil || operation.Name.Value != operationName) {
+			continue
+		}
+		if operation.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+
+	return false
+}
+
+func graphqlErrorsJSON(w http.ResponseWriter, errs []gqlerrors.FormattedError, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(&graphql.Result{Errors: errs}); err != nil {
+		log.Printf("failed to encode GraphQL errors: %v", err)
+	}
+}
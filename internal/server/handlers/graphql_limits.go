@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	graphqlMaxDepth      = 8
+	graphqlMaxComplexity = 5000
+	// graphqlListFactor is the assumed size of a list when estimating the cost of its items
+	graphqlListFactor = 10
+)
+
+// graphqlListFields are the fields that return lists, their selections cost graphqlListFactor times more
+var graphqlListFields = map[string]bool{
+	"currencies":    true,
+	"exchangeRates": true,
+	"ratesAsBase":   true,
+	"ratesAsTarget": true,
+	"history":       true,
+}
+
+// checkGraphQLLimits rejects documents whose operations nest deeper than graphqlMaxDepth
+// or whose estimated cost is above graphqlMaxComplexity, before anything is resolved
+func checkGraphQLLimits(doc *ast.Document) error {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		depth, complexity := measureSelectionSet(operation.SelectionSet, fragments, map[string]bool{})
+		if depth > graphqlMaxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, graphqlMaxDepth)
+		}
+		if complexity > graphqlMaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, graphqlMaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+func measureSelectionSet(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, visiting map[string]bool) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			childDepth, childComplexity := measureSelectionSet(s.SelectionSet, fragments, visiting)
+			if graphqlListFields[s.Name.Value] {
+				childComplexity *= graphqlListFactor
+			}
+			d, c = childDepth+1, childComplexity+1
+
+		case *ast.InlineFragment:
+			d, c = measureSelectionSet(s.SelectionSet, fragments, visiting)
+
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := fragments[name]
+			// cycles and unknown fragments are reported by validation
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			d, c = measureSelectionSet(fragment.SelectionSet, fragments, visiting)
+			delete(visiting, name)
+		}
+
+		depth = max(depth, d)
+		complexity += c
+	}
+
+	return depth, complexity
+}
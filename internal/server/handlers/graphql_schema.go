@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+const graphqlDefaultHistoryLimit = 100
+
+type graphqlLoadersKey struct{}
+
+// graphqlLoaders live for one request so results are never shared between clients
+type graphqlLoaders struct {
+	ratesByCurrency *batchLoader[string, []models.ExchangeRate]
+	history         *batchLoader[historyKey, []models.RateHistoryPoint]
+}
+
+type historyKey struct {
+	exchangeRateID int
+	since          time.Time
+	limit          int
+}
+
+func (h *Handlers) newGraphQLLoaders(ctx context.Context) *graphqlLoaders {
+	return &graphqlLoaders{
+		ratesByCurrency: newBatchLoader(func(codes []string) (map[string][]models.ExchangeRate, error) {
+			rates, err := h.exchangeRateSrv.GetExchangeRatesByCurrencyCodes(ctx, codes)
+			if err != nil {
+				return nil, err
+			}
+
+			byCode := make(map[string][]models.ExchangeRate, len(codes))
+			for _, rate := range rates {
+				byCode[rate.BaseCurrency.Code] = append(byCode[rate.BaseCurrency.Code], rate)
+				if rate.TargetCurrency.Code != rate.BaseCurrency.Code {
+					byCode[rate.TargetCurrency.Code] = append(byCode[rate.TargetCurrency.Code], rate)
+				}
+			}
+			return byCode, nil
+		}),
+
+		history: newBatchLoader(func(keys []historyKey) (map[historyKey][]models.RateHistoryPoint, error) {
+			// rates asked with the same arguments share one query
+			type window struct {
+				since time.Time
+				limit int
+			}
+			groups := make(map[window][]int)
+			for _, key := range keys {
+				w := window{key.since, key.limit}
+				groups[w] = append(groups[w], key.exchangeRateID)
+			}
+
+			result := make(map[historyKey][]models.RateHistoryPoint, len(keys))
+			for w, ids := range groups {
+				points, err := h.exchangeRateSrv.GetExchangeRateHistory(ctx, ids, w.since, w.limit)
+				if err != nil {
+					return nil, err
+				}
+				for _, point := range points {
+					key := historyKey{point.ExchangeRateID, w.since, w.limit}
+					result[key] = append(result[key], point)
+				}
+			}
+			return result, nil
+		}),
+	}
+}
+
+func loadersFrom(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+// graphqlProblem is an error whose extensions carry the code of the matching problem of the HTTP
+// API and its field errors
+type graphqlProblem struct {
+	problem problemType
+	message string
+	fields  []FieldError
+	// extensions are set besides code and errors, e.g. the proposalId of a pending change
+	extensions map[string]interface{}
+}
+
+func (e *graphqlProblem) Error() string {
+	return e.message
+}
+
+func (e *graphqlProblem) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.problem.code}
+	if len(e.fields) > 0 {
+		extensions["errors"] = e.fields
+	}
+	for k, v := range e.extensions {
+		extensions[k] = v
+	}
+	return extensions
+}
+
+// graphqlError logs err and hides internal details from the client, the code extension of the
+// returned error is the one the HTTP API answers the same error with
+func graphqlError(op string, err error) error {
+	log.Printf("%s: %v", op, err)
+
+	var rateErrs service.RateErrors
+	var rateErr *service.RateError
+	var pending *service.PendingApprovalError
+	switch {
+	case errors.As(err, &rateErrs) || errors.As(err, &rateErr):
+		if rateErrs == nil {
+			rateErrs = service.RateErrors{rateErr}
+		}
+		fields := make([]FieldError, len(rateErrs))
+		for i, e := range rateErrs {
+			fields[i] = FieldError{Field: createRateField(e), Code: e.Code, Message: e.Message}
+		}
+		return &graphqlProblem{problem: problemValidationFailed, message: "request has invalid fields", fields: fields}
+	case errors.Is(err, ErrInvalidInputData):
+		return &graphqlProblem{problem: problemInvalidRequest, message: err.Error()}
+	case errors.Is(err, repository.ErrCurrencyNotFound):
+		return &graphqlProblem{problem: problemCurrencyNotFound, message: "currency not found"}
+	case errors.Is(err, repository.ErrExchangeRateNotFound):
+		return &graphqlProblem{problem: problemRateNotFound, message: "exchange rate not found"}
+	case errors.Is(err, repository.ErrCurrencyExists):
+		return &graphqlProblem{problem: problemCurrencyExists, message: "currency already exists"}
+	case errors.Is(err, repository.ErrExchangeRateExists):
+		return &graphqlProblem{problem: problemRateExists, message: "exchange rate already exists"}
+	case errors.Is(err, repository.ErrCurrencyInactive):
+		return &graphqlProblem{problem: problemCurrencyInactive, message: "currency is inactive"}
+	case errors.Is(err, service.ErrExchangeRateStale):
+		return &graphqlProblem{problem: problemRateStale, message: "exchange rate is stale"}
+	case errors.Is(err, service.ErrInvalidRate):
+		return &graphqlProblem{problem: problemValidationFailed, message: err.Error()}
+	case errors.Is(err, service.ErrVersionRequired):
+		return &graphqlProblem{problem: problemPreconditionRequired, message: "version of the exchange rate is required"}
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return &graphqlProblem{problem: problemPreconditionFailed, message: "exchange rate was changed since it was read, get it again and retry"}
+	case errors.As(err, &pending):
+		return &graphqlProblem{
+			problem:    problemApprovalRequired,
+			message:    fmt.Sprintf("rate change needs approval, it is waiting as proposal %d", pending.Proposal.ID),
+			extensions: map[string]interface{}{"proposalId": pending.Proposal.ID},
+		}
+	default:
+		return &graphqlProblem{problem: problemInternal, message: "internal server error"}
+	}
+}
+
+func (h *Handlers) buildGraphQLSchema() (graphql.Schema, error) {
+	currencyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Currency",
+		Fields: graphql.Fields{
+			"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"code":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"sign":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"active":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"version": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	ratePointType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RatePoint",
+		Fields: graphql.Fields{
+			"rate":       &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"recordedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	exchangeRateType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ExchangeRate",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			// currencies come from the same query as the rate, resolving them costs nothing
+			"baseCurrency":   &graphql.Field{Type: graphql.NewNonNull(currencyType)},
+			"targetCurrency": &graphql.Field{Type: graphql.NewNonNull(currencyType)},
+			"rate":           &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"updatedAt":      &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"source":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"generation":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"version":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"history": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(ratePointType))),
+				Description: "Latest recorded values, oldest first",
+				Args: graphql.FieldConfigArgument{
+					"since": &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphqlDefaultHistoryLimit},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					rate := p.Source.(models.ExchangeRate)
+
+					var since time.Time
+					if v, ok := p.Args["since"].(time.Time); ok {
+						since = v
+					}
+
+					limit, _ := p.Args["limit"].(int)
+					if limit <= 0 || limit > graphqlDefaultHistoryLimit {
+						return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidInputData, graphqlDefaultHistoryLimit)
+					}
+
+					return loadersFrom(p.Context).history.load(historyKey{rate.ID, since, limit}), nil
+				},
+			},
+		},
+	})
+
+	conversionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CurrencyConversion",
+		Fields: graphql.Fields{
+			"baseCurrency":    &graphql.Field{Type: graphql.NewNonNull(currencyType)},
+			"targetCurrency":  &graphql.Field{Type: graphql.NewNonNull(currencyType)},
+			"rate":            &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"amount":          &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"convertedAmount": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"stale":           &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"pivot": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Currency the cross rate was derived through, null for the rate of the pair or its inverse",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if pivot := p.Source.(models.CurrencyConversion).Pivot; pivot != "" {
+						return pivot, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	ratesOfCurrency := func(asBase bool) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (interface{}, error) {
+			currency := p.Source.(models.Currency)
+			thunk := loadersFrom(p.Context).ratesByCurrency.load(currency.Code)
+
+			return func() (interface{}, error) {
+				v, err := thunk()
+				if err != nil {
+					return nil, graphqlError("internal.server.handlers.handlers.GraphQL", err)
+				}
+
+				rates := []models.ExchangeRate{}
+				for _, rate := range v.([]models.ExchangeRate) {
+					if asBase && rate.BaseCurrency.Code == currency.Code ||
+						!asBase && rate.TargetCurrency.Code == currency.Code {
+						rates = append(rates, rate)
+					}
+				}
+				return rates, nil
+			}, nil
+		}
+	}
+
+	currencyType.AddFieldConfig("ratesAsBase", &graphql.Field{
+		Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(exchangeRateType))),
+		Resolve: ratesOfCurrency(true),
+	})
+	currencyType.AddFieldConfig("ratesAsTarget", &graphql.Field{
+		Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(exchangeRateType))),
+		Resolve: ratesOfCurrency(false),
+	})
+	currencyType.AddFieldConfig("convert", &graphql.Field{
+		Type: conversionType,
+		Args: graphql.FieldConfigArgument{
+			"to":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+			"amount": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			currency := p.Source.(models.Currency)
+			return h.resolveConversion(p.Context, currency.Code, p.Args["to"].(string), p.Args["amount"].(float64))
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"currencies": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(currencyType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					currencies, err := h.currencySrv.GetAllCurrencies(p.Context)
+					if errors.Is(err, repository.ErrCurrencyNotFound) {
+						return []models.Currency{}, nil
+					} else if err != nil {
+						return nil, graphqlError("internal.server.handlers.handlers.GraphQL", err)
+					}
+					return currencies, nil
+				},
+			},
+			"currency": &graphql.Field{
+				Type: currencyType,
+				Args: graphql.FieldConfigArgument{
+					"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					currency, err := h.currencySrv.GetCurrencyByCode(p.Context, p.Args["code"].(string))
+					if errors.Is(err, repository.ErrCurrencyNotFound) {
+						return nil, nil
+					} else if err != nil {
+						return nil, graphqlError("internal.server.handlers.handlers.GraphQL", err)
+					}
+					return currency, nil
+				},
+			},
+			"exchangeRates": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(exchangeRateType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					rates, err := h.exchangeRateSrv.GetAllExchangeRates(p.Context)
+					if errors.Is(err, repository.ErrExchangeRateNotFound) {
+						return []models.ExchangeRate{}, nil
+					} else if err != nil {
+						return nil, graphqlError("internal.server.handlers.handlers.GraphQL", err)
+					}
+					return rates, nil
+				},
+			},
+			"exchangeRate": &graphql.Field{
+				Type: exchangeRateType,
+				Args: graphql.FieldConfigArgument{
+					"baseCurrencyCode":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"targetCurrencyCode": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					rate, err := h.exchangeRateSrv.GetExchangeRate(p.Context,
+						p.Args["baseCurrencyCode"].(string), p.Args["targetCurrencyCode"].(string))
+					if errors.Is(err, repository.ErrExchangeRateNotFound) {
+						return nil, nil
+					} else if err != nil {
+						return nil, graphqlError("internal.server.handlers.handlers.GraphQL", err)
+					}
+					return rate, nil
+				},
+			},
+			"convert": &graphql.Field{
+				Type: conversionType,
+				Args: graphql.FieldConfigArgument{
+					"from":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"to":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"amount": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.resolveConversion(p.Context, p.Args["from"].(string), p.Args["to"].(string), p.Args["amount"].(float64))
+				},
+			},
+		},
+	})
+
+	rateArgs := graphql.FieldConfigArgument{
+		"baseCurrencyCode":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"targetCurrencyCode": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+		"rate":               &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+	}
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createCurrency": &graphql.Field{
+				Type: graphql.NewNonNull(currencyType),
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"sign": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					const op = "internal.server.handlers.handlers.GraphQL.createCurrency"
+
+					currency := models.Currency{
+						Name: p.Args["name"].(string),
+						Code: p.Args["code"].(string),
+						Sign: p.Args["sign"].(string),
+					}
+					if currency.Name == "" || currency.Code == "" || currency.Sign == "" {
+						return nil, graphqlError(op, fmt.Errorf("%w: all fields are required", ErrInvalidInputData))
+					}
+
+					created, err := h.currencySrv.AddCurrency(p.Context, currency)
+					if err != nil {
+						return nil, graphqlError(op, err)
+					}
+					return created, nil
+				},
+			},
+			"createExchangeRate": &graphql.Field{
+				Type: graphql.NewNonNull(exchangeRateType),
+				Args: rateArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					const op = "internal.server.handlers.handlers.GraphQL.createExchangeRate"
+
+					created, err := h.exchangeRateSrv.AddExchangeRate(p.Context,
+						p.Args["baseCurrencyCode"].(string), p.Args["targetCurrencyCode"].(string), p.Args["rate"].(float64))
+					if err != nil {
+						return nil, graphqlError(op, err)
+					}
+					return created, nil
+				},
+			},
+			"updateExchangeRate": &graphql.Field{
+				Type: graphql.NewNonNull(exchangeRateType),
+				// version is the one the rate was read at, it is only set while the rate is still at it
+				Args: graphql.FieldConfigArgument{
+					"baseCurrencyCode":   rateArgs["baseCurrencyCode"],
+					"targetCurrencyCode": rateArgs["targetCurrencyCode"],
+					"rate":               rateArgs["rate"],
+					"version":            &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					const op = "internal.server.handlers.handlers.GraphQL.updateExchangeRate"
+
+					version, _ := p.Args["version"].(int)
+					if version < 0 {
+						return nil, graphqlError(op, fmt.Errorf("%w: version must be greater than zero", ErrInvalidInputData))
+					}
+					updated, err := h.exchangeRateSrv.UpdateExchangeRate(p.Context,
+						p.Args["baseCurrencyCode"].(string), p.Args["targetCurrencyCode"].(string), p.Args["rate"].(float64), int64(version))
+					if err != nil {
+						return nil, graphqlError(op, err)
+					}
+					return updated, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+func (h *Handlers) resolveConversion(ctx context.Context, fromCode, toCode string, amount float64) (interface{}, error) {
+	const op = "internal.server.handlers.handlers.GraphQL.convert"
+
+	if amount <= 0 {
+		return nil, graphqlError(op, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidInputData))
+	}
+
+	conversion, err := h.currencyConvertSrv.ConvertCurrency(ctx, fromCode, toCode, amount)
+	if err != nil {
+		return nil, graphqlError(op, err)
+	}
+
+	return conversion, nil
+}
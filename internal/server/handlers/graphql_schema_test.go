@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestGraphQLErrorCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"invalid input", fmt.Errorf("%w: amount must be greater than zero", ErrInvalidInputData), "invalid_request"},
+		{"currency not found", repository.ErrCurrencyNotFound, "currency_not_found"},
+		{"rate not found", repository.ErrExchangeRateNotFound, "rate_not_found"},
+		{"currency exists", repository.ErrCurrencyExists, "currency_exists"},
+		{"rate exists", repository.ErrExchangeRateExists, "rate_exists"},
+		{"currency inactive", repository.ErrCurrencyInactive, "currency_inactive"},
+		{"stale rate", service.ErrExchangeRateStale, "rate_stale"},
+		{"rate error", &service.RateError{Pair: "USDEUR", Code: "jump_too_large"}, "validation_failed"},
+		{"rate errors", service.RateErrors{{Pair: "USDEUR", Code: "out_of_range"}}, "validation_failed"},
+		{"version required", service.ErrVersionRequired, "precondition_required"},
+		{"version mismatch", fmt.Errorf("op: %w", repository.ErrVersionMismatch), "precondition_failed"},
+		{"pending approval", &service.PendingApprovalError{Proposal: models.RateProposal{ID: 7}}, "approval_required"},
+		{"unknown", errors.New("disk is full"), "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var problem *graphqlProblem
+			if !errors.As(graphqlError("test", tt.err), &problem) {
+				t.Fatal("graphqlError did not return a graphqlProblem")
+			}
+			if code := problem.Extensions()["code"]; code != tt.code {
+				t.Errorf("code = %v, want %s", code, tt.code)
+			}
+		})
+	}
+}
+
+// updateRates answers every rate update with err
+type updateRates struct {
+	exchangeRateService
+	err error
+}
+
+func (s updateRates) UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, version int64) (models.ExchangeRate, error) {
+	return models.ExchangeRate{}, s.err
+}
+
+func TestGraphQLErrorExtensions(t *testing.T) {
+	rateErr := &service.RateError{Pair: "USDEUR", Code: "jump_too_large", Message: "rate moved too far"}
+	h := New(nil, updateRates{err: fmt.Errorf("op: %w", rateErr)}, nil, nil, nil, nil, nil, nil)
+	schema, err := h.graphqlSchema()
+	if err != nil {
+		t.Fatalf("graphqlSchema: %v", err)
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `mutation { updateExchangeRate(baseCurrencyCode: "USD", targetCurrencyCode: "EUR", rate: 2, version: 1) { rate } }`,
+		Context:       context.Background(),
+	})
+	if len(result.Errors) != 1 {
+		t.Fatalf("errors = %v, want one", result.Errors)
+	}
+
+	extensions := result.Errors[0].Extensions
+	if extensions["code"] != "validation_failed" {
+		t.Errorf("code = %v, want validation_failed", extensions["code"])
+	}
+	fields, _ := extensions["errors"].([]FieldError)
+	if len(fields) != 1 || fields[0].Field != "rate" || fields[0].Code != "jump_too_large" {
+		t.Errorf("errors = %v, want the jump_too_large error of rate", extensions["errors"])
+	}
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
 	"errors"
-	"log"
-	"net/http"
+	"sync"
+
+	"github.com/graphql-go/graphql"
 )
 
 var (
@@ -15,26 +15,36 @@ type Handlers struct {
 	currencySrv        currencyService
 	exchangeRateSrv    exchangeRateService
 	currencyConvertSrv currencyConvertService
+	alertSrv           alertService
+	rateFeed           rateFeed
+	catalogueSrv       catalogueService
+	auditSrv           auditService
+	pegSrv             pegService
+
+	graphqlSchema func() (graphql.Schema, error)
 }
 
-func New(currencySrv currencyService, exchangeRateSrv exchangeRateService, currencyConvertSrv currencyConvertService) *Handlers {
-	return &Handlers{
+func New(
+	currencySrv currencyService,
+	exchangeRateSrv exchangeRateService,
+	currencyConvertSrv currencyConvertService,
+	alertSrv alertService,
+	rateFeed rateFeed,
+	catalogueSrv catalogueService,
+	auditSrv auditService,
+	pegSrv pegService,
+) *Handlers {
+	h := &Handlers{
 		currencySrv:        currencySrv,
 		exchangeRateSrv:    exchangeRateSrv,
 		currencyConvertSrv: currencyConvertSrv,
+		alertSrv:           alertSrv,
+		rateFeed:           rateFeed,
+		catalogueSrv:       catalogueSrv,
+		auditSrv:           auditSrv,
+		pegSrv:             pegSrv,
 	}
-}
+	h.graphqlSchema = sync.OnceValues(h.buildGraphQLSchema)
 
-func errorJSON(w http.ResponseWriter, message string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	err := json.NewEncoder(w).Encode(map[string]string{
-		"message": message,
-	})
-
-	if err != nil {
-		log.Printf("failed to encode error JSON: %v", err)
-		http.Error(w, message, statusCode)
-	}
+	return h
 }
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// parsePageRequest reads the limit, cursor and sort query parameters of a list
+func parsePageRequest(query url.Values, invalid *validation) models.PageRequest {
+	page := models.PageRequest{
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+		Limit:  defaultPageSize,
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			invalid.add("limit", "invalid_format", "invalid limit format")
+		} else if limit < 1 || limit > maxPageSize {
+			invalid.add("limit", "out_of_range", fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+		} else {
+			page.Limit = limit
+		}
+	}
+
+	return page
+}
+
+// writePageHeaders sets X-Total-Count and a Link header pointing to the first and the next page
+func writePageHeaders(w http.ResponseWriter, r *http.Request, total int, nextCursor string) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	// RequestURI keeps the /v1 prefix the router strips from URL.Path
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return
+	}
+
+	query := u.Query()
+	query.Del("cursor")
+	u.RawQuery = query.Encode()
+	links := fmt.Sprintf(`<%s>; rel="first"`, u.String())
+
+	if nextCursor != "" {
+		query.Set("cursor", nextCursor)
+		u.RawQuery = query.Encode()
+		links = fmt.Sprintf(`<%s>; rel="next", `, u.String()) + links
+	}
+
+	// added rather than set so the successor-version link of the legacy routes is kept
+	w.Header().Add("Link", links)
+}
+
+// pageErrorJSON reports a cursor or sort the repository rejected, it returns false for other errors
+func pageErrorJSON(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrInvalidCursor):
+		errorJSON(w, problemValidationFailed, "request has invalid fields",
+			FieldError{Field: "cursor", Code: "invalid", Message: "cursor is invalid or was made for another sort"})
+	case errors.Is(err, repository.ErrInvalidSort):
+		errorJSON(w, problemValidationFailed, "request has invalid fields",
+			FieldError{Field: "sort", Code: "invalid", Message: "sort field is not supported"})
+	default:
+		return false
+	}
+	return true
+}
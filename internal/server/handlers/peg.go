@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"log"
+	"math"
+	"net/http"
+)
+
+type pegService interface {
+	GetPegs(ctx context.Context) ([]models.Peg, error)
+	GetPeg(ctx context.Context, code string) (models.Peg, error)
+	AddPeg(ctx context.Context, peg models.Peg) (models.Peg, error)
+	DeletePeg(ctx context.Context, code string) error
+}
+
+func (h *Handlers) GetPegs(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetPegs"
+
+	pegs, err := h.pegSrv.GetPegs(r.Context())
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		pegErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pegs)
+}
+
+func (h *Handlers) GetPeg(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetPeg"
+
+	peg, err := h.pegSrv.GetPeg(r.Context(), r.PathValue("code"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		pegErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(peg)
+}
+
+type createPegRequest struct {
+	CurrencyCode       string   `json:"currencyCode"`
+	AnchorCurrencyCode string   `json:"anchorCurrencyCode"`
+	CentralRate        *float64 `json:"centralRate"`
+	// Band is relative to the central rate, zero fixes the rate
+	Band float64 `json:"band"`
+}
+
+func (h *Handlers) CreatePeg(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.CreatePeg"
+
+	var req createPegRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		bodyErrorJSON(w, err)
+		return
+	}
+
+	invalid.require(req.CurrencyCode != "", "currencyCode")
+	invalid.require(req.AnchorCurrencyCode != "", "anchorCurrencyCode")
+	invalid.require(req.CentralRate != nil, "centralRate")
+	if req.CurrencyCode != "" && req.CurrencyCode == req.AnchorCurrencyCode {
+		invalid.add("anchorCurrencyCode", "same_currency", "a currency can not be pegged to itself")
+	}
+	if req.CentralRate != nil && (!(*req.CentralRate > 0) || math.IsInf(*req.CentralRate, 1)) {
+		invalid.add("centralRate", "out_of_range", "centralRate must be a finite number greater than zero")
+	}
+	if !(req.Band >= 0 && req.Band < 1) {
+		invalid.add("band", "out_of_range", "band must be at least 0 and less than 1")
+	}
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	peg, err := h.pegSrv.AddPeg(r.Context(), models.Peg{
+		CurrencyCode:       req.CurrencyCode,
+		AnchorCurrencyCode: req.AnchorCurrencyCode,
+		CentralRate:        *req.CentralRate,
+		Band:               req.Band,
+	})
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		pegErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(peg)
+}
+
+// DeletePeg drops a peg and the rates it derived
+func (h *Handlers) DeletePeg(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.DeletePeg"
+
+	if err := h.pegSrv.DeletePeg(r.Context(), r.PathValue("code")); err != nil {
+		log.Printf("%s: %v", op, err)
+		pegErrorJSON(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func pegErrorJSON(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPegNotFound):
+		errorJSON(w, problemPegNotFound, "peg not found")
+	case errors.Is(err, repository.ErrPegExists):
+		errorJSON(w, problemPegExists, "currency is already pegged")
+	case errors.Is(err, repository.ErrCurrencyNotFound):
+		errorJSON(w, problemCurrencyNotFound, "one or both currencies not found")
+	case errors.Is(err, repository.ErrCurrencyInactive):
+		errorJSON(w, problemCurrencyInactive, "one or both currencies are inactive")
+	case errors.Is(err, repository.ErrPegConflict):
+		errorJSON(w, problemValidationFailed, "request has invalid fields",
+			FieldError{Field: "currencyCode", Code: "conflict", Message: "pegs do not chain and synthetic currencies can not be pegged or anchors"})
+	default:
+		errorJSON(w, problemInternal, "internal server error")
+	}
+}
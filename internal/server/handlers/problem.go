@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// RequestIDHeader carries the ID of a request, problems repeat it so clients can report it
+const RequestIDHeader = "X-Request-ID"
+
+// problemTypeBase prefixes the code of a problem to form its type URI
+const problemTypeBase = "urn:exchanger:problem:"
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points at a request field that was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// problemType is a kind of problem, its code is part of the API and must not change
+type problemType struct {
+	code   string
+	title  string
+	status int
+}
+
+var (
+	problemInvalidRequest       = problemType{"invalid_request", "Invalid request", http.StatusBadRequest}
+	problemValidationFailed     = problemType{"validation_failed", "Validation failed", http.StatusBadRequest}
+	problemInvalidPair          = problemType{"invalid_pair", "Invalid currency pair", http.StatusBadRequest}
+	problemInvalidID            = problemType{"invalid_id", "Invalid ID", http.StatusBadRequest}
+	problemCurrencyNotFound     = problemType{"currency_not_found", "Currency not found", http.StatusNotFound}
+	problemRateNotFound         = problemType{"rate_not_found", "Exchange rate not found", http.StatusNotFound}
+	problemAlertNotFound        = problemType{"alert_not_found", "Alert subscription not found", http.StatusNotFound}
+	problemDeliveryNotFound     = problemType{"delivery_not_found", "Alert delivery not found", http.StatusNotFound}
+	problemAuditEntryNotFound   = problemType{"audit_entry_not_found", "Audit entry not found", http.StatusNotFound}
+	problemProposalNotFound     = problemType{"rate_proposal_not_found", "Rate proposal not found", http.StatusNotFound}
+	problemScheduledNotFound    = problemType{"scheduled_rate_not_found", "Scheduled rate not found", http.StatusNotFound}
+	problemPegNotFound          = problemType{"peg_not_found", "Peg not found", http.StatusNotFound}
+	problemApprovalForbidden    = problemType{"approval_forbidden", "Approval forbidden", http.StatusForbidden}
+	problemCurrencyExists       = problemType{"currency_exists", "Currency already exists", http.StatusConflict}
+	problemCurrencyInUse        = problemType{"currency_in_use", "Currency is used by exchange rates", http.StatusConflict}
+	problemRateExists           = problemType{"rate_exists", "Exchange rate already exists", http.StatusConflict}
+	problemDeliveryNotDead      = problemType{"delivery_not_dead", "Alert delivery is not dead", http.StatusConflict}
+	problemApprovalRequired     = problemType{"approval_required", "Rate change requires approval", http.StatusConflict}
+	problemProposalNotPending   = problemType{"rate_proposal_not_pending", "Rate proposal is not pending", http.StatusConflict}
+	problemProposalOutdated     = problemType{"rate_proposal_outdated", "Rate proposal is outdated", http.StatusConflict}
+	problemScheduledNotPending  = problemType{"scheduled_rate_not_pending", "Scheduled rate is not pending", http.StatusConflict}
+	problemPegExists            = problemType{"peg_exists", "Currency is already pegged", http.StatusConflict}
+	problemPreconditionFailed   = problemType{"precondition_failed", "Precondition failed", http.StatusPreconditionFailed}
+	problemBodyTooLarge         = problemType{"body_too_large", "Request body too large", http.StatusRequestEntityTooLarge}
+	problemUnsupportedMediaType = problemType{"unsupported_media_type", "Unsupported media type", http.StatusUnsupportedMediaType}
+	problemPreconditionRequired = problemType{"precondition_required", "Precondition required", http.StatusPreconditionRequired}
+	problemRateStale            = problemType{"rate_stale", "Exchange rate is stale", http.StatusUnprocessableEntity}
+	problemCurrencyInactive     = problemType{"currency_inactive", "Currency is inactive", http.StatusUnprocessableEntity}
+	problemImportRejected       = problemType{"import_rejected", "Import rejected", http.StatusUnprocessableEntity}
+	problemInternal             = problemType{"internal_error", "Internal server error", http.StatusInternalServerError}
+)
+
+// validation collects the field errors of a request so they are reported together
+type validation []FieldError
+
+func (v *validation) add(field, code, message string) {
+	*v = append(*v, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (v validation) has(field string) bool {
+	for _, fieldErr := range v {
+		if fieldErr.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// require reports a missing field unless it was already rejected
+func (v *validation) require(ok bool, field string) {
+	if !ok && !v.has(field) {
+		v.add(field, "required", field+" is required")
+	}
+}
+
+func errorJSON(w http.ResponseWriter, p problemType, detail string, fields ...FieldError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.status)
+
+	err := json.NewEncoder(w).Encode(Problem{
+		Type:      problemTypeBase + p.code,
+		Title:     p.title,
+		Status:    p.status,
+		Detail:    detail,
+		Code:      p.code,
+		RequestID: w.Header().Get(RequestIDHeader),
+		Errors:    fields,
+	})
+
+	if err != nil {
+		log.Printf("failed to encode problem JSON: %v", err)
+	}
+}
+
+// validationErrorJSON reports every rejected field of a request
+func validationErrorJSON(w http.ResponseWriter, invalid validation) {
+	errorJSON(w, problemValidationFailed, "request has invalid fields", invalid...)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxCandles limits how many candles one request may ask for, a few more are returned when
+	// from is not at the start of one
+	maxCandles = 1000
+	// defaultCandles is how many candles are returned before to when from is absent
+	defaultCandles = 100
+	// defaultStatsWindow is how many candles the stats sum up when ?window is absent
+	defaultStatsWindow = 30
+)
+
+// parseCandlePair splits the pair path value the way GetExchangeRate does
+func parseCandlePair(w http.ResponseWriter, op string, r *http.Request) (string, string, bool) {
+	pair := r.PathValue("pair")
+	if len(pair) < 6 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		errorJSON(w, problemInvalidPair, "invalid currency pair format",
+			FieldError{Field: "pair", Code: "invalid_format", Message: "pair must be two currency codes, e.g. USDEUR"})
+		return "", "", false
+	}
+	return pair[:3], pair[3:], true
+}
+
+// parseCandleQuery reads ?interval, 1d when absent, and the RFC 3339 ?to, now when absent
+func parseCandleQuery(query url.Values, invalid *validation) (string, time.Duration, time.Time) {
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = "1d"
+	}
+	length, ok := service.CandleIntervals[interval]
+	if !ok {
+		invalid.add("interval", "invalid", "interval must be 1h, 1d or 1w")
+	}
+
+	to := time.Now()
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			invalid.add("to", "invalid_format", "to must be an RFC 3339 time")
+		}
+		to = t
+	}
+
+	return interval, length, to
+}
+
+func candleErrorJSON(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrExchangeRateNotFound):
+		errorJSON(w, problemRateNotFound, "exchange rate not found")
+	case errors.Is(err, service.ErrNoRateHistory):
+		errorJSON(w, problemRateNotFound, "the pair has no rate in the window")
+	default:
+		errorJSON(w, problemInternal, "internal server error")
+	}
+}
+
+// GetExchangeRateCandles returns what the rate of a pair did interval by interval.
+// ?interval=1h|1d|1w sets the length of a candle, days start at midnight UTC and weeks on Monday.
+// ?from and ?to are RFC 3339 times, the last 100 candles until now by default.
+func (h *Handlers) GetExchangeRateCandles(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetExchangeRateCandles"
+
+	baseCode, targetCode, ok := parseCandlePair(w, op, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	var invalid validation
+	interval, length, to := parseCandleQuery(query, &invalid)
+
+	from := to.Add(-defaultCandles * length)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			invalid.add("from", "invalid_format", "from must be an RFC 3339 time")
+		}
+		from = t
+	}
+
+	if len(invalid) == 0 {
+		switch {
+		case !from.Before(to):
+			invalid.add("from", "out_of_range", "from must be before to")
+		case to.Sub(from) > maxCandles*length:
+			invalid.add("from", "out_of_range", "at most "+strconv.Itoa(maxCandles)+" candles can be asked for at once")
+		}
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	candles, err := h.exchangeRateSrv.GetRateCandles(r.Context(), baseCode, targetCode, interval, from, to)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		candleErrorJSON(w, err)
+		return
+	}
+
+	writeTaggedJSON(w, r, "", candles)
+}
+
+// GetExchangeRateStats sums up the last ?window candles of a pair until ?to: the change, the
+// moving averages of the closes and their volatility. ?interval and ?to are read as by
+// GetExchangeRateCandles, the window is 30 candles by default.
+func (h *Handlers) GetExchangeRateStats(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetExchangeRateStats"
+
+	baseCode, targetCode, ok := parseCandlePair(w, op, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	var invalid validation
+	interval, _, to := parseCandleQuery(query, &invalid)
+
+	window := defaultStatsWindow
+	if v := query.Get("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil:
+			invalid.add("window", "invalid_format", "window must be an integer")
+		case n < 2 || n > maxCandles:
+			invalid.add("window", "out_of_range", "window must be between 2 and "+strconv.Itoa(maxCandles))
+		default:
+			window = n
+		}
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	stats, err := h.exchangeRateSrv.GetRateStats(r.Context(), baseCode, targetCode, interval, window, to)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		candleErrorJSON(w, err)
+		return
+	}
+
+	writeTaggedJSON(w, r, "", stats)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// GetExchangeRateMatrix returns the rate between every two currencies of ?currencies=USD,EUR,RUB,
+// every active currency without it, resolved the way /exchange resolves a single pair. Either is
+// limited to service.MaxMatrixCurrencies currencies.
+func (h *Handlers) GetExchangeRateMatrix(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetExchangeRateMatrix"
+
+	var codes []string
+	for _, code := range strings.Split(r.URL.Query().Get("currencies"), ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	matrix, err := h.currencyConvertSrv.GetRateMatrix(r.Context(), codes)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		switch {
+		case errors.Is(err, service.ErrMatrixTooLarge):
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "currencies", Code: "out_of_range", Message: fmt.Sprintf("at most %d currencies are allowed, list them in currencies", service.MaxMatrixCurrencies)})
+		case errors.Is(err, repository.ErrCurrencyNotFound):
+			errorJSON(w, problemCurrencyNotFound, "one or more currencies not found")
+		case errors.Is(err, repository.ErrCurrencyInactive):
+			errorJSON(w, problemCurrencyInactive, "one or more currencies are inactive")
+		default:
+			errorJSON(w, problemInternal, "internal server error")
+		}
+		return
+	}
+
+	writeTaggedJSON(w, r, "", matrix)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// GetRateProposals lists rate proposals a page at a time. ?status=pending|approved|rejected|expired,
+// ?base=USD and ?target=EUR narrow the list, ?sort=id|expiresAt (with "-" for descending order)
+// orders it.
+func (h *Handlers) GetRateProposals(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetRateProposals"
+
+	query := r.URL.Query()
+
+	var invalid validation
+	page := parsePageRequest(query, &invalid)
+
+	filter := models.RateProposalFilter{
+		Status:     models.RateProposalStatus(query.Get("status")),
+		BaseCode:   query.Get("base"),
+		TargetCode: query.Get("target"),
+	}
+	switch filter.Status {
+	case "", models.RateProposalPending, models.RateProposalApproved, models.RateProposalRejected, models.RateProposalExpired:
+	default:
+		invalid.add("status", "invalid", "status must be pending, approved, rejected or expired")
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	result, err := h.exchangeRateSrv.ListRateProposals(r.Context(), filter, page)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if pageErrorJSON(w, err) {
+			return
+		}
+		if errors.Is(err, repository.ErrRateProposalNotFound) {
+			errorJSON(w, problemProposalNotFound, "no rate proposals match")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	writePageHeaders(w, r, result.Total, result.NextCursor)
+	writeTaggedJSON(w, r, "", result.Items)
+}
+
+func (h *Handlers) GetRateProposal(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetRateProposal"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid rate proposal id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	proposal, err := h.exchangeRateSrv.GetRateProposal(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		rateProposalErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// ApproveRateProposal applies a pending proposal. The approver named by X-Actor must have the
+// rates:approve scope in X-Actor-Scopes and must not have made the proposal.
+func (h *Handlers) ApproveRateProposal(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.ApproveRateProposal"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid rate proposal id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	proposal, err := h.exchangeRateSrv.ApproveRateProposal(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		rateProposalErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+type rejectRateProposalRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RejectRateProposal closes a pending proposal without applying it, {"reason": "..."} is required
+func (h *Handlers) RejectRateProposal(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.RejectRateProposal"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid rate proposal id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	var req rejectRateProposalRequest
+	invalid, err := decodeBody(w, r, &req)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		bodyErrorJSON(w, err)
+		return
+	}
+
+	invalid.require(req.Reason != "", "reason")
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	proposal, err := h.exchangeRateSrv.RejectRateProposal(r.Context(), id, req.Reason)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		rateProposalErrorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// rateProposalErrorJSON writes the problem of a failed read or decision of a proposal
+func rateProposalErrorJSON(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRateProposalNotFound):
+		errorJSON(w, problemProposalNotFound, "rate proposal not found")
+	case errors.Is(err, service.ErrApprovalForbidden):
+		errorJSON(w, problemApprovalForbidden, err.Error())
+	case errors.Is(err, repository.ErrRateProposalNotPending):
+		errorJSON(w, problemProposalNotPending, "rate proposal was already decided or has expired")
+	case errors.Is(err, repository.ErrVersionMismatch):
+		errorJSON(w, problemProposalOutdated, "exchange rate was changed since the proposal was made, reject it and propose again")
+	case errors.Is(err, repository.ErrExchangeRateNotFound):
+		errorJSON(w, problemRateNotFound, "exchange rate of the proposal no longer exists")
+	default:
+		errorJSON(w, problemInternal, "internal server error")
+	}
+}
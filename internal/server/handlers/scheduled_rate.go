@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// parseEffectiveFrom reads the RFC 3339 effectiveFrom of a rate write, the zero time when it is absent
+func parseEffectiveFrom(v string, invalid *validation) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		invalid.add("effectiveFrom", "invalid_format", "effectiveFrom must be an RFC 3339 time")
+	}
+	return t
+}
+
+// writeScheduledRate answers a rate write with effectiveFrom with 202 and the scheduled change
+func writeScheduledRate(w http.ResponseWriter, op string, scheduled models.ScheduledRate, err error) {
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if rateErrorJSON(w, err, createRateField) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrEffectiveFromPast):
+			errorJSON(w, problemValidationFailed, "request has invalid fields",
+				FieldError{Field: "effectiveFrom", Code: "out_of_range", Message: "effectiveFrom must be in the future"})
+		case errors.Is(err, repository.ErrCurrencyNotFound):
+			errorJSON(w, problemCurrencyNotFound, "one or both currencies not found")
+		case errors.Is(err, repository.ErrExchangeRateNotFound):
+			errorJSON(w, problemRateNotFound, "exchange rate not found")
+		case errors.Is(err, repository.ErrExchangeRateExists):
+			errorJSON(w, problemRateExists, "exchange rate already exists")
+		case errors.Is(err, repository.ErrVersionMismatch):
+			errorJSON(w, problemPreconditionFailed, "exchange rate was changed since it was read, get it again and retry")
+		case errors.Is(err, service.ErrVersionRequired):
+			errorJSON(w, problemPreconditionRequired, "If-Match header with the ETag of the exchange rate is required")
+		case errors.Is(err, repository.ErrCurrencyInactive):
+			errorJSON(w, problemCurrencyInactive, "one or both currencies are inactive")
+		case errors.Is(err, service.ErrApprovalRequired):
+			errorJSON(w, problemApprovalRequired, "the change needs approval and can not be scheduled, update the pair without effectiveFrom to propose it")
+		default:
+			errorJSON(w, problemInternal, "internal server error")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/v1/scheduledRate/%d", scheduled.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+// GetScheduledRates lists scheduled rate changes a page at a time.
+// ?status=pending|applied|canceled|failed, ?base=USD and ?target=EUR narrow the list,
+// ?sort=id|effectiveFrom (with "-" for descending order) orders it.
+func (h *Handlers) GetScheduledRates(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetScheduledRates"
+
+	query := r.URL.Query()
+
+	var invalid validation
+	page := parsePageRequest(query, &invalid)
+
+	filter := models.ScheduledRateFilter{
+		Status:     models.ScheduledRateStatus(query.Get("status")),
+		BaseCode:   query.Get("base"),
+		TargetCode: query.Get("target"),
+	}
+	switch filter.Status {
+	case "", models.ScheduledRatePending, models.ScheduledRateApplied, models.ScheduledRateCanceled, models.ScheduledRateFailed:
+	default:
+		invalid.add("status", "invalid", "status must be pending, applied, canceled or failed")
+	}
+
+	if len(invalid) > 0 {
+		log.Printf("%s: %v", op, ErrInvalidInputData)
+		validationErrorJSON(w, invalid)
+		return
+	}
+
+	result, err := h.exchangeRateSrv.ListScheduledRates(r.Context(), filter, page)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if pageErrorJSON(w, err) {
+			return
+		}
+		if errors.Is(err, repository.ErrScheduledRateNotFound) {
+			errorJSON(w, problemScheduledNotFound, "no scheduled rates match")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	writePageHeaders(w, r, result.Total, result.NextCursor)
+	writeTaggedJSON(w, r, "", result.Items)
+}
+
+func (h *Handlers) GetScheduledRate(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.GetScheduledRate"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid scheduled rate id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	scheduled, err := h.exchangeRateSrv.GetScheduledRate(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrScheduledRateNotFound) {
+			errorJSON(w, problemScheduledNotFound, "scheduled rate not found")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+// CancelScheduledRate drops a scheduled change that has not become effective yet
+func (h *Handlers) CancelScheduledRate(w http.ResponseWriter, r *http.Request) {
+	const op = "internal.server.handlers.handlers.CancelScheduledRate"
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		errorJSON(w, problemInvalidID, "invalid scheduled rate id",
+			FieldError{Field: "id", Code: "invalid_format", Message: "id must be an integer"})
+		return
+	}
+
+	scheduled, err := h.exchangeRateSrv.CancelScheduledRate(r.Context(), id)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		if errors.Is(err, repository.ErrScheduledRateNotFound) {
+			errorJSON(w, problemScheduledNotFound, "scheduled rate not found")
+			return
+		}
+		if errors.Is(err, repository.ErrScheduledRateNotPending) {
+			errorJSON(w, problemScheduledNotPending, "scheduled rate was already applied, canceled or failed")
+			return
+		}
+		errorJSON(w, problemInternal, "internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduled)
+}
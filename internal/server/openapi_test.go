@@ -0,0 +1,430 @@
+package server
+
+import (
+	"encoding/json"
+	"exchanger/api"
+	"exchanger/internal/models"
+	"exchanger/internal/server/handlers"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Required   []string                  `json:"required"`
+	Items      *openAPISchema            `json:"items"`
+}
+
+type openAPIDocument struct {
+	Paths map[string]map[string]struct {
+		Parameters []struct {
+			Name string `json:"name"`
+			In   string `json:"in"`
+		} `json:"parameters"`
+		Responses map[string]struct {
+			Content map[string]struct {
+				Schema *openAPISchema `json:"schema"`
+			} `json:"content"`
+		} `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// openAPIModels are the Go types behind the component schemas
+var openAPIModels = map[string]any{
+	"Currency":              models.Currency{},
+	"BasketComponent":       models.BasketComponent{},
+	"ExchangeRate":          models.ExchangeRate{},
+	"CurrencyConversion":    models.CurrencyConversion{},
+	"ConversionExplanation": models.ConversionExplanation{},
+	"ConversionLeg":         models.ConversionLeg{},
+	"ConversionStep":        models.ConversionStep{},
+	"SkippedRoute":          models.SkippedRoute{},
+	"RateEvent":             models.RateEvent{},
+	"AlertSubscription":     models.AlertSubscription{},
+	"AlertEvent":            models.AlertEvent{},
+	"AlertDelivery":         models.AlertDelivery{},
+	"Catalogue":             models.Catalogue{},
+	"CurrencyRecord":        models.CurrencyRecord{},
+	"RateRecord":            models.RateRecord{},
+	"ImportReport":          models.ImportReport{},
+	"ImportRow":             models.ImportRow{},
+	"ImportError":           models.ImportError{},
+	"RateGeneration":        models.RateGeneration{},
+	"RateProposal":          models.RateProposal{},
+	"ScheduledRate":         models.ScheduledRate{},
+	"Peg":                   models.Peg{},
+	"RateCandle":            models.RateCandle{},
+	"RateStats":             models.RateStats{},
+	"RateMatrix":            models.RateMatrix{},
+	"RateMatrixCell":        models.RateMatrixCell{},
+	"ArbitrageReport":       models.ArbitrageReport{},
+	"ArbitrageCycle":        models.ArbitrageCycle{},
+	"ArbitrageLeg":          models.ArbitrageLeg{},
+	"AuditEntry":            models.AuditEntry{},
+	"Problem":               handlers.Problem{},
+	"FieldError":            handlers.FieldError{},
+}
+
+func loadOpenAPI(t *testing.T) *openAPIDocument {
+	t.Helper()
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(api.OpenAPI, &doc); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+	return &doc
+}
+
+// registeredRoutes reads the patterns passed to mux.HandleFunc in router.go, patterns
+// registered in v1Routes are served under /v1
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "router.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse router.go: %v", err)
+	}
+
+	var routes []string
+	for _, decl := range file.Decls {
+		// the legacy routes are deprecated aliases of /v1 ones and are not documented
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name == "legacyRoutes" {
+			continue
+		}
+		prefix := ""
+		if fn.Name.Name == "v1Routes" {
+			prefix = "/v1"
+		}
+
+		ast.Inspect(fn, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "HandleFunc" {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				t.Errorf("route pattern at %v is not a string literal", call.Pos())
+				return true
+			}
+			pattern, _ := strconv.Unquote(lit.Value)
+			method, path, _ := strings.Cut(pattern, " ")
+			routes = append(routes, method+" "+prefix+path)
+			return true
+		})
+	}
+
+	sort.Strings(routes)
+	return routes
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+	routes := registeredRoutes(t)
+
+	var documented []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	if !reflect.DeepEqual(routes, documented) {
+		t.Errorf("routes and openapi.json differ\nregistered: %q\ndocumented: %q", routes, documented)
+	}
+
+	pathParam := regexp.MustCompile(`\{(\w+)\}`)
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			declared := make(map[string]bool)
+			for _, param := range operation.Parameters {
+				if param.In == "path" {
+					declared[param.Name] = true
+				}
+			}
+			for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+				if !declared[m[1]] {
+					t.Errorf("%s %s: path parameter %q is not declared", method, path, m[1])
+				}
+			}
+			if len(operation.Responses) == 0 {
+				t.Errorf("%s %s: no responses", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIRefs(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	var check func(where string, s *openAPISchema)
+	check = func(where string, s *openAPISchema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			if _, ok := doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]; !ok {
+				t.Errorf("%s: unresolved %s", where, s.Ref)
+			}
+		}
+		for name, p := range s.Properties {
+			check(where+"."+name, p)
+		}
+		check(where+"[]", s.Items)
+	}
+
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			for status, response := range operation.Responses {
+				for _, content := range response.Content {
+					check(method+" "+path+" "+status, content.Schema)
+				}
+			}
+		}
+	}
+	for name, s := range doc.Components.Schemas {
+		check(name, s)
+	}
+}
+
+func TestOpenAPIModels(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	for name := range doc.Components.Schemas {
+		if _, ok := openAPIModels[name]; !ok {
+			t.Errorf("schema %s is not mapped to a model", name)
+		}
+	}
+
+	for name, model := range openAPIModels {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("model %s has no schema", name)
+			continue
+		}
+
+		data, err := json.Marshal(filled(reflect.TypeOf(model)).Interface())
+		if err != nil {
+			t.Fatalf("marshal %s: %v", name, err)
+		}
+		var value any
+		if err := json.Unmarshal(data, &value); err != nil {
+			t.Fatalf("unmarshal %s: %v", name, err)
+		}
+		matchSchema(t, doc, name, schema, value)
+
+		var empty any
+		data, _ = json.Marshal(model)
+		json.Unmarshal(data, &empty)
+		matchRequired(t, doc, name, schema, empty)
+	}
+}
+
+func TestOpenAPIProblemResponse(t *testing.T) {
+	doc := loadOpenAPI(t)
+	mux := Routes(handlers.New(nil, nil, nil, nil, nil, nil, nil, nil))
+
+	// the fields are rejected before any service is called
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/currencies", strings.NewReader(`{"name":1,"extra":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(handlers.RequestIDHeader, "test-request")
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+
+	var value map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		t.Fatalf("unmarshal problem: %v", err)
+	}
+	schema := doc.Components.Schemas["Problem"]
+	matchSchema(t, doc, "Problem", schema, value)
+	for _, field := range schema.Required {
+		if _, ok := value[field]; !ok {
+			t.Errorf("problem has no %q", field)
+		}
+	}
+
+	if value["code"] != "validation_failed" || value["requestId"] != "test-request" {
+		t.Errorf("code = %v, requestId = %v", value["code"], value["requestId"])
+	}
+	// name has the wrong type, extra is unknown, code and sign are missing
+	if errs, _ := value["errors"].([]any); len(errs) != 4 {
+		t.Errorf("got %d field errors, want 4: %v", len(errs), value["errors"])
+	}
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	mux := Routes(handlers.New(nil, nil, nil, nil, nil, nil, nil, nil))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/exchangeRate/USD", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec.Header().Get("Deprecation") == "" {
+		t.Errorf("unversioned route has no Deprecation header")
+	}
+	if rec.Header().Get(handlers.RequestIDHeader) == "" {
+		t.Errorf("response has no request ID")
+	}
+
+	// endpoints added with /v1 have no unversioned alias
+	for _, target := range []string{"/pegs", "/exchangeRates/matrix", "/audit", "/export"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status = %d, want %d", target, rec.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func resolve(doc *openAPIDocument, s *openAPISchema) *openAPISchema {
+	for s != nil && s.Ref != "" {
+		s = doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// matchSchema checks that value has exactly the properties of schema, with matching types
+func matchSchema(t *testing.T, doc *openAPIDocument, where string, schema *openAPISchema, value any) {
+	t.Helper()
+
+	schema = resolve(doc, schema)
+	if schema == nil {
+		t.Errorf("%s: no schema", where)
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if schema.Type != "object" {
+			t.Errorf("%s: got an object, schema says %s", where, schema.Type)
+			return
+		}
+		if schema.Properties == nil {
+			return
+		}
+		for key, field := range v {
+			property, ok := schema.Properties[key]
+			if !ok {
+				t.Errorf("%s: field %q is not in the schema", where, key)
+				continue
+			}
+			matchSchema(t, doc, where+"."+key, property, field)
+		}
+		for key := range schema.Properties {
+			if _, ok := v[key]; !ok {
+				t.Errorf("%s: schema property %q is not in the model", where, key)
+			}
+		}
+	case []any:
+		if schema.Type != "array" {
+			t.Errorf("%s: got an array, schema says %s", where, schema.Type)
+			return
+		}
+		for i, item := range v {
+			matchSchema(t, doc, where+"["+strconv.Itoa(i)+"]", schema.Items, item)
+		}
+	case string:
+		if schema.Type != "string" {
+			t.Errorf("%s: got a string, schema says %s", where, schema.Type)
+		}
+	case float64:
+		if schema.Type != "number" && !(schema.Type == "integer" && v == float64(int64(v))) {
+			t.Errorf("%s: got %v, schema says %s", where, v, schema.Type)
+		}
+	case bool:
+		if schema.Type != "boolean" {
+			t.Errorf("%s: got a boolean, schema says %s", where, schema.Type)
+		}
+	}
+}
+
+// matchRequired checks that the fields a zero model still encodes are exactly the required ones
+func matchRequired(t *testing.T, doc *openAPIDocument, where string, schema *openAPISchema, value any) {
+	t.Helper()
+
+	schema = resolve(doc, schema)
+	object, ok := value.(map[string]any)
+	if !ok || schema == nil {
+		return
+	}
+
+	var present []string
+	for key := range object {
+		present = append(present, key)
+	}
+	required := append([]string(nil), schema.Required...)
+	sort.Strings(present)
+	sort.Strings(required)
+
+	if !reflect.DeepEqual(present, required) {
+		t.Errorf("%s: always present fields %q, required %q", where, present, required)
+	}
+
+	for key, field := range object {
+		matchRequired(t, doc, where+"."+key, schema.Properties[key], field)
+	}
+}
+
+// filled returns a value of typ with every field set, so omitempty fields are encoded too
+func filled(typ reflect.Type) reflect.Value {
+	v := reflect.New(typ).Elem()
+
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		v.Set(reflect.ValueOf(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	case typ == reflect.TypeOf(json.RawMessage{}):
+		v.SetBytes([]byte(`{"key":"value"}`))
+	case typ.Kind() == reflect.Struct:
+		for i := range typ.NumField() {
+			if typ.Field(i).IsExported() {
+				v.Field(i).Set(filled(typ.Field(i).Type))
+			}
+		}
+	case typ.Kind() == reflect.Pointer:
+		p := reflect.New(typ.Elem())
+		p.Elem().Set(filled(typ.Elem()))
+		v.Set(p)
+	case typ.Kind() == reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(typ, 0, 1), filled(typ.Elem())))
+	case typ.Kind() == reflect.String:
+		v.SetString("value")
+	case typ.Kind() == reflect.Bool:
+		v.SetBool(true)
+	case v.CanInt():
+		v.SetInt(1)
+	case v.CanUint():
+		v.SetUint(1)
+	case v.CanFloat():
+		v.SetFloat(1.5)
+	}
+
+	return v
+}
@@ -1,23 +1,163 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"exchanger/internal/models"
 	"exchanger/internal/server/handlers"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func Routes(h *handlers.Handlers) *http.ServeMux {
+// legacyDeprecatedAt is when the unversioned routes were superseded by /v1
+var legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+func Routes(h *handlers.Handlers) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/v1/", http.StripPrefix("/v1", v1Routes(h)))
+	// the unversioned routes are kept for existing clients, endpoints added since /v1 have none
+	mux.Handle("/", deprecated(legacyRoutes(h)))
+
+	mux.HandleFunc("GET /openapi.json", h.GetOpenAPI)
+	mux.HandleFunc("GET /docs", h.GetDocs)
+
+	return withRequestID(withActor(mux))
+}
+
+func v1Routes(h *handlers.Handlers) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /currencies", h.GetCurrencies)
 	mux.HandleFunc("GET /currency/{code}", h.GetCurrency)
 	mux.HandleFunc("POST /currencies", h.CreateCurrency)
+	mux.HandleFunc("DELETE /currency/{code}", h.DeactivateCurrency)
+	mux.HandleFunc("POST /currency/{code}/activate", h.ActivateCurrency)
 
 	mux.HandleFunc("GET /exchangeRates", h.GetExchangeRates)
+	mux.HandleFunc("GET /exchangeRates/stream", h.StreamExchangeRates)
+	mux.HandleFunc("GET /exchangeRates/matrix", h.GetExchangeRateMatrix)
 	mux.HandleFunc("GET /exchangeRate/{pair}", h.GetExchangeRate)
+	mux.HandleFunc("GET /exchangeRate/{pair}/candles", h.GetExchangeRateCandles)
+	mux.HandleFunc("GET /exchangeRate/{pair}/stats", h.GetExchangeRateStats)
 	mux.HandleFunc("POST /exchangeRates", h.CreateExchangeRate)
+	mux.HandleFunc("PATCH /exchangeRates", h.UpdateExchangeRates)
 	mux.HandleFunc("PATCH /exchangeRate/{pair}", h.UpdateExchangeRate)
+	mux.HandleFunc("DELETE /exchangeRate/{pair}", h.DeleteExchangeRate)
+
+	mux.HandleFunc("GET /rateProposals", h.GetRateProposals)
+	mux.HandleFunc("GET /rateProposal/{id}", h.GetRateProposal)
+	mux.HandleFunc("POST /rateProposal/{id}/approve", h.ApproveRateProposal)
+	mux.HandleFunc("POST /rateProposal/{id}/reject", h.RejectRateProposal)
+
+	mux.HandleFunc("GET /scheduledRates", h.GetScheduledRates)
+	mux.HandleFunc("GET /scheduledRate/{id}", h.GetScheduledRate)
+	mux.HandleFunc("POST /scheduledRate/{id}/cancel", h.CancelScheduledRate)
+
+	mux.HandleFunc("GET /pegs", h.GetPegs)
+	mux.HandleFunc("GET /peg/{code}", h.GetPeg)
+	mux.HandleFunc("POST /pegs", h.CreatePeg)
+	mux.HandleFunc("DELETE /peg/{code}", h.DeletePeg)
 
 	mux.HandleFunc("GET /exchange", h.ExchangeCurrency)
+	mux.HandleFunc("GET /exchange/ws", h.ExchangeCurrencyWS)
+
+	mux.HandleFunc("GET /alerts", h.GetAlertSubscriptions)
+	mux.HandleFunc("GET /alert/{id}", h.GetAlertSubscription)
+	mux.HandleFunc("POST /alerts", h.CreateAlertSubscription)
+	mux.HandleFunc("PATCH /alert/{id}", h.UpdateAlertSubscription)
+	mux.HandleFunc("DELETE /alert/{id}", h.DeleteAlertSubscription)
+	mux.HandleFunc("GET /alerts/deadLetters", h.GetAlertDeadLetters)
+	mux.HandleFunc("POST /alerts/deadLetters/{id}/retry", h.RetryAlertDeadLetter)
+
+	mux.HandleFunc("POST /import", h.ImportCatalogue)
+	mux.HandleFunc("GET /export", h.ExportCatalogue)
+
+	mux.HandleFunc("GET /analysis/arbitrage", h.GetArbitrage)
+
+	mux.HandleFunc("GET /audit", h.GetAuditEntries)
+	mux.HandleFunc("GET /audit/export", h.ExportAuditEntries)
+
+	mux.HandleFunc("GET /graphql", h.GraphQL)
+	mux.HandleFunc("POST /graphql", h.GraphQL)
 
 	return mux
 }
+
+// legacyRoutes are the routes served before /v1
+func legacyRoutes(h *handlers.Handlers) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /currencies", h.GetCurrencies)
+	mux.HandleFunc("GET /currency/{code}", h.GetCurrency)
+	mux.HandleFunc("POST /currencies", h.CreateCurrency)
+
+	mux.HandleFunc("GET /exchangeRates", h.GetExchangeRates)
+	mux.HandleFunc("GET /exchangeRate/{pair}", h.GetExchangeRate)
+	mux.HandleFunc("POST /exchangeRates", h.CreateExchangeRate)
+	mux.HandleFunc("PATCH /exchangeRate/{pair}", h.UpdateExchangeRate)
+
+	mux.HandleFunc("GET /exchange", h.ExchangeCurrency)
+
+	return mux
+}
+
+// deprecated marks responses of the unversioned routes with a Deprecation header (RFC 9745)
+// pointing to the /v1 successor
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(legacyDeprecatedAt.Unix(), 10))
+		w.Header().Set("Link", "</v1"+r.URL.EscapedPath()+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRequestID gives every request an ID in the X-Request-ID response header, a valid
+// ID sent by the client is kept so it can correlate its own logs
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(handlers.RequestIDHeader)
+		if !validRequestID(id) {
+			var b [16]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+
+		w.Header().Set(handlers.RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withActor puts who makes the request, from where and its ID into the context, the changes
+// it makes are audited with them
+func withActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		actor := models.Actor{
+			Name:      r.Header.Get(handlers.ActorHeader),
+			Scopes:    strings.Fields(r.Header.Get(handlers.ActorScopesHeader)),
+			IP:        ip,
+			RequestID: w.Header().Get(handlers.RequestIDHeader),
+		}
+		next.ServeHTTP(w, r.WithContext(models.ContextWithActor(r.Context(), actor)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	exchangerv1 "exchanger/api/exchanger/v1"
+	"exchanger/internal/models"
+	"fmt"
+)
+
+func (s *Server) ListCurrencies(ctx context.Context, req *exchangerv1.ListCurrenciesRequest) (*exchangerv1.ListCurrenciesResponse, error) {
+	const op = "internal.server.rpc.rpc.ListCurrencies"
+
+	currencies, err := s.currencySrv.GetAllCurrencies(ctx)
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	resp := &exchangerv1.ListCurrenciesResponse{}
+	for _, c := range currencies {
+		resp.Currencies = append(resp.Currencies, currencyToProto(c))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) GetCurrency(ctx context.Context, req *exchangerv1.GetCurrencyRequest) (*exchangerv1.Currency, error) {
+	const op = "internal.server.rpc.rpc.GetCurrency"
+
+	if req.GetCode() == "" {
+		return nil, statusError(op, fmt.Errorf("%w: currency code is required", ErrInvalidInputData))
+	}
+
+	currency, err := s.currencySrv.GetCurrencyByCode(ctx, req.GetCode())
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	return currencyToProto(currency), nil
+}
+
+func (s *Server) CreateCurrency(ctx context.Context, req *exchangerv1.CreateCurrencyRequest) (*exchangerv1.Currency, error) {
+	const op = "internal.server.rpc.rpc.CreateCurrency"
+
+	currency := models.Currency{
+		Name: req.GetName(),
+		Code: req.GetCode(),
+		Sign: req.GetSign(),
+	}
+
+	if currency.Name == "" || currency.Code == "" || currency.Sign == "" {
+		return nil, statusError(op, fmt.Errorf("%w: all fields are required", ErrInvalidInputData))
+	}
+
+	created, err := s.currencySrv.AddCurrency(ctx, currency)
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	return currencyToProto(created), nil
+}
@@ -0,0 +1,26 @@
+package rpc
+
+import (
+	"context"
+	exchangerv1 "exchanger/api/exchanger/v1"
+	"fmt"
+)
+
+func (s *Server) ConvertCurrency(ctx context.Context, req *exchangerv1.ConvertCurrencyRequest) (*exchangerv1.CurrencyConversion, error) {
+	const op = "internal.server.rpc.rpc.ConvertCurrency"
+
+	if req.GetFrom() == "" || req.GetTo() == "" {
+		return nil, statusError(op, fmt.Errorf("%w: from and to are required", ErrInvalidInputData))
+	}
+
+	if req.GetAmount() <= 0 {
+		return nil, statusError(op, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidInputData))
+	}
+
+	conversion, err := s.currencyConvertSrv.ConvertCurrency(ctx, req.GetFrom(), req.GetTo(), req.GetAmount())
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	return conversionToProto(conversion), nil
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"context"
+	exchangerv1 "exchanger/api/exchanger/v1"
+	"exchanger/internal/service"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+func (s *Server) ListExchangeRates(ctx context.Context, req *exchangerv1.ListExchangeRatesRequest) (*exchangerv1.ListExchangeRatesResponse, error) {
+	const op = "internal.server.rpc.rpc.ListExchangeRates"
+
+	rates, err := s.exchangeRateSrv.GetAllExchangeRates(ctx)
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	resp := &exchangerv1.ListExchangeRatesResponse{}
+	for _, rate := range rates {
+		resp.ExchangeRates = append(resp.ExchangeRates, exchangeRateToProto(rate))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) GetExchangeRate(ctx context.Context, req *exchangerv1.GetExchangeRateRequest) (*exchangerv1.ExchangeRate, error) {
+	const op = "internal.server.rpc.rpc.GetExchangeRate"
+
+	if req.GetBaseCurrencyCode() == "" || req.GetTargetCurrencyCode() == "" {
+		return nil, statusError(op, fmt.Errorf("%w: both currency codes are required", ErrInvalidInputData))
+	}
+
+	rate, err := s.exchangeRateSrv.GetExchangeRate(ctx, req.GetBaseCurrencyCode(), req.GetTargetCurrencyCode())
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	return exchangeRateToProto(rate), nil
+}
+
+func (s *Server) CreateExchangeRate(ctx context.Context, req *exchangerv1.CreateExchangeRateRequest) (*exchangerv1.ExchangeRate, error) {
+	const op = "internal.server.rpc.rpc.CreateExchangeRate"
+
+	if req.GetBaseCurrencyCode() == "" || req.GetTargetCurrencyCode() == "" {
+		return nil, statusError(op, fmt.Errorf("%w: both currency codes are required", ErrInvalidInputData))
+	}
+
+	rate, err := s.exchangeRateSrv.AddExchangeRate(ctx, req.GetBaseCurrencyCode(), req.GetTargetCurrencyCode(), req.GetRate())
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	return exchangeRateToProto(rate), nil
+}
+
+func (s *Server) UpdateExchangeRate(ctx context.Context, req *exchangerv1.UpdateExchangeRateRequest) (*exchangerv1.ExchangeRate, error) {
+	const op = "internal.server.rpc.rpc.UpdateExchangeRate"
+
+	if req.GetBaseCurrencyCode() == "" || req.GetTargetCurrencyCode() == "" {
+		return nil, statusError(op, fmt.Errorf("%w: both currency codes are required", ErrInvalidInputData))
+	}
+
+	version, err := ifMatchVersion(ctx)
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	rate, err := s.exchangeRateSrv.UpdateExchangeRate(ctx, req.GetBaseCurrencyCode(), req.GetTargetCurrencyCode(), req.GetRate(), version)
+	if err != nil {
+		return nil, statusError(op, err)
+	}
+
+	return exchangeRateToProto(rate), nil
+}
+
+func (s *Server) WatchExchangeRates(req *exchangerv1.WatchExchangeRatesRequest, stream grpc.ServerStreamingServer[exchangerv1.RateEvent]) error {
+	const op = "internal.server.rpc.rpc.WatchExchangeRates"
+
+	filter := service.RateEventFilter(req.GetPairs(), req.GetCurrencies())
+
+	sub, backlog, complete := s.rateFeed.Subscribe(req.GetLastEventId(), req.LastEventId != nil, filter)
+	defer s.rateFeed.Unsubscribe(sub)
+
+	if !complete {
+		if err := stream.Send(&exchangerv1.RateEvent{MissedEvents: true}); err != nil {
+			return statusError(op, err)
+		}
+	}
+
+	for _, event := range backlog {
+		if err := stream.Send(rateEventToProto(event)); err != nil {
+			return statusError(op, err)
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-sub.Events:
+			if !ok {
+				// the client fell too far behind, it can resume with the last received id
+				return statusError(op, fmt.Errorf("%w: client is too slow", service.ErrRateSubscriberDropped))
+			}
+			if err := stream.Send(rateEventToProto(event)); err != nil {
+				return statusError(op, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	exchangerv1 "exchanger/api/exchanger/v1"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ErrInvalidInputData = errors.New("invalid input data")
+
+type currencyService interface {
+	GetAllCurrencies(ctx context.Context) ([]models.Currency, error)
+	GetCurrencyByCode(ctx context.Context, code string) (models.Currency, error)
+	AddCurrency(ctx context.Context, currency models.Currency) (models.Currency, error)
+}
+
+type exchangeRateService interface {
+	GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error)
+	AddExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error)
+	UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, version int64) (models.ExchangeRate, error)
+}
+
+type currencyConvertService interface {
+	ConvertCurrency(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, error)
+}
+
+type rateFeed interface {
+	Subscribe(lastEventID uint64, resume bool, filter func(models.RateEvent) bool) (*service.RateSubscription, []models.RateEvent, bool)
+	Unsubscribe(sub *service.RateSubscription)
+}
+
+// Server exposes the same services as the HTTP handlers over gRPC
+type Server struct {
+	exchangerv1.UnimplementedCurrencyServiceServer
+	exchangerv1.UnimplementedExchangeRateServiceServer
+	exchangerv1.UnimplementedConversionServiceServer
+
+	currencySrv        currencyService
+	exchangeRateSrv    exchangeRateService
+	currencyConvertSrv currencyConvertService
+	rateFeed           rateFeed
+}
+
+func New(
+	currencySrv currencyService,
+	exchangeRateSrv exchangeRateService,
+	currencyConvertSrv currencyConvertService,
+	rateFeed rateFeed,
+) *Server {
+	return &Server{
+		currencySrv:        currencySrv,
+		exchangeRateSrv:    exchangeRateSrv,
+		currencyConvertSrv: currencyConvertSrv,
+		rateFeed:           rateFeed,
+	}
+}
+
+// Register adds every gRPC service of s to the server
+func (s *Server) Register(server *grpc.Server) {
+	exchangerv1.RegisterCurrencyServiceServer(server, s)
+	exchangerv1.RegisterExchangeRateServiceServer(server, s)
+	exchangerv1.RegisterConversionServiceServer(server, s)
+}
+
+// WithActor puts who makes a unary call into its context so the changes it makes are audited,
+// the actor, its scopes and request ID come from the x-actor, x-actor-scopes and x-request-id metadata
+func WithActor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var actor models.Actor
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-actor"); len(v) > 0 {
+			actor.Name = v[0]
+		}
+		if v := md.Get("x-request-id"); len(v) > 0 {
+			actor.RequestID = v[0]
+		}
+		for _, v := range md.Get("x-actor-scopes") {
+			actor.Scopes = append(actor.Scopes, strings.Fields(v)...)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		actor.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(actor.IP); err == nil {
+			actor.IP = host
+		}
+	}
+
+	return handler(models.ContextWithActor(ctx, actor), req)
+}
+
+// ifMatchVersion returns the version in the if-match metadata of a rate write, 0 without it. The
+// value is the version or the ETag of the rate, e.g. 3 or "3".
+func ifMatchVersion(ctx context.Context) (int64, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, nil
+	}
+	v := md.Get("if-match")
+	if len(v) == 0 {
+		return 0, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(v[0]), `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: if-match must be the version of the exchange rate", ErrInvalidInputData)
+	}
+	return version, nil
+}
+
+// statusError logs err and converts it to a gRPC status, the same errors the
+// HTTP handlers map to 404, 409, 412, 422 or 428 get the matching codes here. Rejected rates
+// carry a BadRequest detail with the field error codes of the HTTP API, rejected pairs a
+// PreconditionFailure one.
+func statusError(op string, err error) error {
+	log.Printf("%s: %v", op, err)
+
+	var rateErrs service.RateErrors
+	var rateErr *service.RateError
+	var pairsErr *repository.PairsError
+	switch {
+	case errors.As(err, &rateErrs):
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(rateErrs))
+		for i, rateErr := range rateErrs {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: rateErr.Pair, Reason: rateErr.Code, Description: rateErr.Message}
+		}
+		return withDetails(status.New(codes.InvalidArgument, "some rates were rejected, nothing was written"), &errdetails.BadRequest{FieldViolations: violations})
+	case errors.As(err, &rateErr):
+		violation := &errdetails.BadRequest_FieldViolation{Field: "rate", Reason: rateErr.Code, Description: rateErr.Message}
+		return withDetails(status.New(codes.InvalidArgument, rateErr.Message), &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{violation}})
+	case errors.As(err, &pairsErr) && errors.Is(err, repository.ErrExchangeRateNotFound):
+		return pairsStatus(codes.NotFound, "rate_not_found", "some pairs have no exchange rate, nothing was written", pairsErr.Pairs)
+	case errors.As(err, &pairsErr) && errors.Is(err, service.ErrApprovalRequired):
+		return pairsStatus(codes.FailedPrecondition, "approval_required", "some changes need approval, nothing was written", pairsErr.Pairs)
+	case errors.As(err, &pairsErr) && errors.Is(err, service.ErrVersionRequired):
+		return pairsStatus(codes.FailedPrecondition, "precondition_required", "versions are required, nothing was written", pairsErr.Pairs)
+	case errors.As(err, &pairsErr) && errors.Is(err, repository.ErrVersionMismatch):
+		return pairsStatus(codes.Aborted, "precondition_failed", "some pairs were changed since they were read, nothing was written", pairsErr.Pairs)
+	case errors.Is(err, ErrInvalidInputData):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrCurrencyNotFound):
+		return status.Error(codes.NotFound, "currency not found")
+	case errors.Is(err, repository.ErrExchangeRateNotFound):
+		return status.Error(codes.NotFound, "exchange rate not found")
+	case errors.Is(err, repository.ErrCurrencyExists):
+		return status.Error(codes.AlreadyExists, "currency already exists")
+	case errors.Is(err, repository.ErrExchangeRateExists):
+		return status.Error(codes.AlreadyExists, "exchange rate already exists")
+	case errors.Is(err, repository.ErrCurrencyInactive):
+		return status.Error(codes.FailedPrecondition, "currency is inactive")
+	case errors.Is(err, repository.ErrCurrencyInUse):
+		return status.Error(codes.FailedPrecondition, "currency is used by active exchange rates")
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return status.Error(codes.Aborted, "exchange rate was changed since it was read, get it again and retry")
+	case errors.Is(err, service.ErrExchangeRateStale):
+		return status.Error(codes.FailedPrecondition, "exchange rate is stale")
+	case errors.Is(err, service.ErrInvalidRate):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrVersionRequired):
+		return status.Error(codes.FailedPrecondition, "if-match metadata with the version of the exchange rate is required")
+	case errors.As(err, new(*service.PendingApprovalError)):
+		var pending *service.PendingApprovalError
+		errors.As(err, &pending)
+		return status.Errorf(codes.FailedPrecondition, "rate change needs approval, it is waiting as proposal %d", pending.Proposal.ID)
+	case errors.Is(err, service.ErrApprovalRequired):
+		return status.Error(codes.FailedPrecondition, "rate change needs approval")
+	case errors.Is(err, service.ErrRateSubscriberDropped):
+		return status.Error(codes.Unavailable, "client is too slow, resume with the last received event id")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// pairsStatus is the status of a write rejected for some of its pairs, each of them is a
+// violation of the given type
+func pairsStatus(c codes.Code, violationType, message string, pairs []string) error {
+	violations := make([]*errdetails.PreconditionFailure_Violation, len(pairs))
+	for i, pair := range pairs {
+		violations[i] = &errdetails.PreconditionFailure_Violation{Type: violationType, Subject: pair}
+	}
+	return withDetails(status.New(c, message), &errdetails.PreconditionFailure{Violations: violations})
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func currencyToProto(c models.Currency) *exchangerv1.Currency {
+	return &exchangerv1.Currency{
+		Id:      int64(c.ID),
+		Name:    c.Name,
+		Code:    c.Code,
+		Sign:    c.Sign,
+		Version: c.Version,
+	}
+}
+
+func exchangeRateToProto(er models.ExchangeRate) *exchangerv1.ExchangeRate {
+	return &exchangerv1.ExchangeRate{
+		Id:             int64(er.ID),
+		BaseCurrency:   currencyToProto(er.BaseCurrency),
+		TargetCurrency: currencyToProto(er.TargetCurrency),
+		Rate:           er.Rate,
+		UpdatedAt:      timestamppb.New(er.UpdatedAt),
+		Version:        er.Version,
+	}
+}
+
+func conversionToProto(c models.CurrencyConversion) *exchangerv1.CurrencyConversion {
+	return &exchangerv1.CurrencyConversion{
+		BaseCurrency:    currencyToProto(c.BaseCurrency),
+		TargetCurrency:  currencyToProto(c.TargetCurrency),
+		Rate:            c.Rate,
+		Amount:          c.Amount,
+		ConvertedAmount: c.ConvertedAmount,
+		Stale:           c.Stale,
+		Pivot:           c.Pivot,
+	}
+}
+
+func rateEventToProto(event models.RateEvent) *exchangerv1.RateEvent {
+	pb := &exchangerv1.RateEvent{Id: event.ID}
+	if !event.Deleted {
+		pb.Current = exchangeRateToProto(event.Current)
+	}
+	if event.Previous != nil {
+		pb.Previous = exchangeRateToProto(*event.Previous)
+	}
+	return pb
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"exchanger/internal/service"
+	"fmt"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+		// reasons are the BadRequest reasons or PreconditionFailure types of the details
+		reasons []string
+	}{
+		{"invalid input", fmt.Errorf("%w: rate is required", ErrInvalidInputData), codes.InvalidArgument, nil},
+		{"currency not found", repository.ErrCurrencyNotFound, codes.NotFound, nil},
+		{"rate not found", repository.ErrExchangeRateNotFound, codes.NotFound, nil},
+		{"currency exists", repository.ErrCurrencyExists, codes.AlreadyExists, nil},
+		{"rate exists", repository.ErrExchangeRateExists, codes.AlreadyExists, nil},
+		{"currency inactive", repository.ErrCurrencyInactive, codes.FailedPrecondition, nil},
+		{"currency in use", fmt.Errorf("op: %w", repository.ErrCurrencyInUse), codes.FailedPrecondition, nil},
+		{"stale rate", service.ErrExchangeRateStale, codes.FailedPrecondition, nil},
+		{"version mismatch", fmt.Errorf("op: %w", repository.ErrVersionMismatch), codes.Aborted, nil},
+		{"version required", service.ErrVersionRequired, codes.FailedPrecondition, nil},
+		{"approval required", service.ErrApprovalRequired, codes.FailedPrecondition, nil},
+		{"pending approval", &service.PendingApprovalError{Proposal: models.RateProposal{ID: 7}}, codes.FailedPrecondition, nil},
+		{"rate error", fmt.Errorf("op: %w", &service.RateError{Pair: "USDEUR", Code: "jump_too_large", Message: "rate moved too far"}),
+			codes.InvalidArgument, []string{"jump_too_large"}},
+		{"rate errors", service.RateErrors{{Pair: "USDEUR", Code: "out_of_range"}, {Pair: "USDRUB", Code: "arbitrage"}},
+			codes.InvalidArgument, []string{"out_of_range", "arbitrage"}},
+		{"pairs not found", fmt.Errorf("op: %w", &repository.PairsError{Err: repository.ErrExchangeRateNotFound, Pairs: []string{"USDEUR"}}),
+			codes.NotFound, []string{"rate_not_found"}},
+		{"pairs need approval", &repository.PairsError{Err: service.ErrApprovalRequired, Pairs: []string{"USDEUR", "USDRUB"}},
+			codes.FailedPrecondition, []string{"approval_required", "approval_required"}},
+		{"pairs need versions", &repository.PairsError{Err: service.ErrVersionRequired, Pairs: []string{"USDEUR"}},
+			codes.FailedPrecondition, []string{"precondition_required"}},
+		{"pairs changed", &repository.PairsError{Err: repository.ErrVersionMismatch, Pairs: []string{"USDEUR"}},
+			codes.Aborted, []string{"precondition_failed"}},
+		{"subscriber dropped", service.ErrRateSubscriberDropped, codes.Unavailable, nil},
+		{"canceled", context.Canceled, codes.Canceled, nil},
+		{"unknown", errors.New("disk is full"), codes.Internal, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(statusError("test", tt.err))
+			if st.Code() != tt.code {
+				t.Errorf("code = %v, want %v", st.Code(), tt.code)
+			}
+
+			var reasons []string
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.BadRequest:
+					for _, v := range d.GetFieldViolations() {
+						reasons = append(reasons, v.GetReason())
+					}
+				case *errdetails.PreconditionFailure:
+					for _, v := range d.GetViolations() {
+						reasons = append(reasons, v.GetType())
+					}
+				}
+			}
+			if fmt.Sprint(reasons) != fmt.Sprint(tt.reasons) {
+				t.Errorf("details = %v, want %v", reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      metadata.MD
+		version int64
+		wantErr bool
+	}{
+		{"no metadata", nil, 0, false},
+		{"version", metadata.Pairs("if-match", "3"), 3, false},
+		{"etag", metadata.Pairs("if-match", `"3"`), 3, false},
+		{"not a number", metadata.Pairs("if-match", "abc"), 0, true},
+		{"zero", metadata.Pairs("if-match", "0"), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			version, err := ifMatchVersion(ctx)
+			if (err != nil) != tt.wantErr || version != tt.version {
+				t.Errorf("ifMatchVersion = %d, %v, want %d, error %v", version, err, tt.version, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeRateToProtoVersion(t *testing.T) {
+	er := exchangeRateToProto(models.ExchangeRate{
+		BaseCurrency:   models.Currency{Code: "USD", Version: 2},
+		TargetCurrency: models.Currency{Code: "EUR", Version: 3},
+		Version:        7,
+	})
+
+	// the version is what UpdateExchangeRate takes as if-match metadata
+	if er.GetVersion() != 7 || er.GetBaseCurrency().GetVersion() != 2 || er.GetTargetCurrency().GetVersion() != 3 {
+		t.Errorf("versions = %d, %d, %d, want 7, 2, 3",
+			er.GetVersion(), er.GetBaseCurrency().GetVersion(), er.GetTargetCurrency().GetVersion())
+	}
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrInvalidAlertSubscription = errors.New("invalid alert subscription")
+	ErrAlertDeliveryNotDead     = errors.New("alert delivery is not in the dead letter list")
+)
+
+type AlertConfig struct {
+	// MaxAttempts is the number of failed deliveries after which a webhook goes to the dead letter list
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// PollInterval is how often due deliveries and staleness subscriptions are checked
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+type alertRepository interface {
+	GetAllAlertSubscriptions(ctx context.Context) ([]models.AlertSubscription, error)
+	GetAlertSubscriptionsByPair(ctx context.Context, baseCode, targetCode string) ([]models.AlertSubscription, error)
+	GetAlertSubscriptionsByCondition(ctx context.Context, condition models.AlertCondition) ([]models.AlertSubscription, error)
+	GetAlertSubscription(ctx context.Context, id int) (models.AlertSubscription, error)
+	AddAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error)
+	UpdateAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error)
+	DeleteAlertSubscription(ctx context.Context, id int) error
+	TriggerAlert(ctx context.Context, subscription models.AlertSubscription, payload []byte, triggeredAt time.Time) error
+	GetDueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]models.AlertDelivery, error)
+	GetAlertDeliveriesByStatus(ctx context.Context, status models.AlertDeliveryStatus) ([]models.AlertDelivery, error)
+	GetAlertDelivery(ctx context.Context, id int) (models.AlertDelivery, error)
+	UpdateAlertDelivery(ctx context.Context, delivery models.AlertDelivery) error
+}
+
+type rateHistoryRepository interface {
+	GetExchangeRateAt(ctx context.Context, baseCode, targetCode string, at time.Time) (models.ExchangeRate, error)
+}
+
+type alertService struct {
+	alertRepo        alertRepository
+	currencyRepo     currencyRepository
+	exchangeRateRepo exchangeRateRepository
+	historyRepo      rateHistoryRepository
+	client           *http.Client
+	cfg              AlertConfig
+	wake             chan struct{}
+}
+
+func NewAlertService(
+	alertRepo alertRepository,
+	currencyRepo currencyRepository,
+	exchangeRateRepo exchangeRateRepository,
+	historyRepo rateHistoryRepository,
+	cfg AlertConfig,
+) *alertService {
+	return &alertService{
+		alertRepo:        alertRepo,
+		currencyRepo:     currencyRepo,
+		exchangeRateRepo: exchangeRateRepo,
+		historyRepo:      historyRepo,
+		client:           &http.Client{Timeout: cfg.Timeout},
+		cfg:              cfg,
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+func (s *alertService) GetAllAlertSubscriptions(ctx context.Context) ([]models.AlertSubscription, error) {
+	subscriptions, err := s.alertRepo.GetAllAlertSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	return subscriptions, nil
+}
+
+func (s *alertService) GetAlertSubscription(ctx context.Context, id int) (models.AlertSubscription, error) {
+	subscription, err := s.alertRepo.GetAlertSubscription(ctx, id)
+	if err != nil {
+		return models.AlertSubscription{}, err
+	}
+
+	subscription.Secret = ""
+
+	return subscription, nil
+}
+
+// AddAlertSubscription generates a signing secret when none is given, the secret is only returned here
+func (s *alertService) AddAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error) {
+	const op = "internal.service.service.AddAlertSubscription"
+
+	if err := s.validateAlertSubscription(ctx, subscription); err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if subscription.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+		}
+		subscription.Secret = secret
+	}
+
+	return s.alertRepo.AddAlertSubscription(ctx, subscription)
+}
+
+// UpdateAlertSubscription keeps the stored secret when the new one is empty
+func (s *alertService) UpdateAlertSubscription(ctx context.Context, subscription models.AlertSubscription) (models.AlertSubscription, error) {
+	const op = "internal.service.service.UpdateAlertSubscription"
+
+	if err := s.validateAlertSubscription(ctx, subscription); err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if subscription.Secret == "" {
+		existing, err := s.alertRepo.GetAlertSubscription(ctx, subscription.ID)
+		if err != nil {
+			return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+		}
+		subscription.Secret = existing.Secret
+	}
+
+	updated, err := s.alertRepo.UpdateAlertSubscription(ctx, subscription)
+	if err != nil {
+		return models.AlertSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated.Secret = ""
+
+	return updated, nil
+}
+
+func (s *alertService) DeleteAlertSubscription(ctx context.Context, id int) error {
+	return s.alertRepo.DeleteAlertSubscription(ctx, id)
+}
+
+func (s *alertService) GetDeadLetters(ctx context.Context) ([]models.AlertDelivery, error) {
+	return s.alertRepo.GetAlertDeliveriesByStatus(ctx, models.AlertDeliveryDead)
+}
+
+// RetryDeadLetter moves a dead delivery back to the queue with a fresh attempt budget
+func (s *alertService) RetryDeadLetter(ctx context.Context, id int) (models.AlertDelivery, error) {
+	const op = "internal.service.service.RetryDeadLetter"
+
+	delivery, err := s.alertRepo.GetAlertDelivery(ctx, id)
+	if err != nil {
+		return models.AlertDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if delivery.Status != models.AlertDeliveryDead {
+		return models.AlertDelivery{}, fmt.Errorf("%s: %w", op, ErrAlertDeliveryNotDead)
+	}
+
+	delivery.Status = models.AlertDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+
+	if err := s.alertRepo.UpdateAlertDelivery(ctx, delivery); err != nil {
+		return models.AlertDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.wakeUp()
+
+	return delivery, nil
+}
+
+func (s *alertService) validateAlertSubscription(ctx context.Context, subscription models.AlertSubscription) error {
+	if _, err := s.currencyRepo.GetCurrencyByCode(ctx, subscription.BaseCurrencyCode); err != nil {
+		return err
+	}
+
+	if _, err := s.currencyRepo.GetCurrencyByCode(ctx, subscription.TargetCurrencyCode); err != nil {
+		return err
+	}
+
+	invalid := &ValidationError{Err: ErrInvalidAlertSubscription}
+
+	u, err := url.Parse(subscription.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid.add("url", "url must be an absolute http or https url")
+	}
+
+	switch subscription.Condition {
+	case models.AlertThreshold:
+		if subscription.Level <= 0 {
+			invalid.add("level", "level must be greater than zero")
+		}
+	case models.AlertPercentChange:
+		if subscription.Percent <= 0 {
+			invalid.add("percent", "percent must be greater than zero")
+		}
+		if subscription.WindowSeconds <= 0 {
+			invalid.add("windowSeconds", "window must be greater than zero")
+		}
+	case models.AlertStaleness:
+		if subscription.MaxAgeSeconds <= 0 {
+			invalid.add("maxAgeSeconds", "max age must be greater than zero")
+		}
+	default:
+		invalid.add("condition", fmt.Sprintf("unknown condition %q", subscription.Condition))
+	}
+
+	return invalid.errOrNil()
+}
+
+// RateChanged evaluates threshold and percent change subscriptions of the written pair
+func (s *alertService) RateChanged(ctx context.Context, change models.RateChange) {
+	const op = "internal.service.service.RateChanged"
+
+	// a deleted pair crosses no level
+	if change.Deleted {
+		return
+	}
+
+	// deliveries are queued even if the client that wrote the rate has gone
+	ctx = context.WithoutCancel(ctx)
+
+	current := change.Current
+	baseCode, targetCode := current.BaseCurrency.Code, current.TargetCurrency.Code
+
+	subscriptions, err := s.alertRepo.GetAlertSubscriptionsByPair(ctx, baseCode, targetCode)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	for _, subscription := range subscriptions {
+		switch subscription.Condition {
+		case models.AlertThreshold:
+			if change.Previous == nil || !crossed(change.Previous.Rate, current.Rate, subscription.Level) {
+				continue
+			}
+			s.trigger(ctx, subscription, current, change.Previous.Rate, now)
+
+		case models.AlertPercentChange:
+			window := time.Duration(subscription.WindowSeconds) * time.Second
+			if subscription.LastTriggeredAt != nil && now.Sub(*subscription.LastTriggeredAt) < window {
+				continue
+			}
+
+			var reference float64
+			if past, err := s.historyRepo.GetExchangeRateAt(ctx, baseCode, targetCode, now.Add(-window)); err == nil {
+				reference = past.Rate
+			} else if change.Previous != nil {
+				reference = change.Previous.Rate
+			} else {
+				continue
+			}
+
+			if reference == 0 || math.Abs(current.Rate-reference)/reference*100 < subscription.Percent {
+				continue
+			}
+			s.trigger(ctx, subscription, current, reference, now)
+		}
+	}
+}
+
+// crossed reports whether the move from previous to current reached level in either direction
+func crossed(previous, current, level float64) bool {
+	return (previous < level && current >= level) || (previous > level && current <= level)
+}
+
+func (s *alertService) trigger(ctx context.Context, subscription models.AlertSubscription, rate models.ExchangeRate, reference float64, now time.Time) {
+	const op = "internal.service.service.trigger"
+
+	payload, err := json.Marshal(models.AlertEvent{
+		SubscriptionID:     subscription.ID,
+		Condition:          subscription.Condition,
+		BaseCurrencyCode:   subscription.BaseCurrencyCode,
+		TargetCurrencyCode: subscription.TargetCurrencyCode,
+		Rate:               rate.Rate,
+		ReferenceRate:      reference,
+		UpdatedAt:          rate.UpdatedAt,
+		TriggeredAt:        now,
+	})
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	if err := s.alertRepo.TriggerAlert(ctx, subscription, payload, now); err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	s.wakeUp()
+}
+
+func (s *alertService) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run checks staleness subscriptions and delivers queued webhooks until ctx is done
+func (s *alertService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkStaleness(ctx)
+		case <-s.wake:
+		}
+
+		s.deliverDue(ctx)
+	}
+}
+
+func (s *alertService) checkStaleness(ctx context.Context) {
+	const op = "internal.service.service.checkStaleness"
+
+	subscriptions, err := s.alertRepo.GetAlertSubscriptionsByCondition(ctx, models.AlertStaleness)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	for _, subscription := range subscriptions {
+		rate, err := s.exchangeRateRepo.GetExchangeRate(ctx, subscription.BaseCurrencyCode, subscription.TargetCurrencyCode)
+		if err != nil {
+			continue
+		}
+
+		maxAge := time.Duration(subscription.MaxAgeSeconds) * time.Second
+		if now.Sub(rate.UpdatedAt) <= maxAge {
+			continue
+		}
+
+		// fire once per stale period, a new write to the pair starts the next one
+		if subscription.LastTriggeredAt != nil && subscription.LastTriggeredAt.After(rate.UpdatedAt) {
+			continue
+		}
+
+		s.trigger(ctx, subscription, rate, 0, now)
+	}
+}
+
+const deliveryBatchSize = 100
+
+func (s *alertService) deliverDue(ctx context.Context) {
+	const op = "internal.service.service.deliverDue"
+
+	deliveries, err := s.alertRepo.GetDueAlertDeliveries(ctx, time.Now(), deliveryBatchSize)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := s.deliver(ctx, delivery)
+
+		delivery.Attempts++
+		switch {
+		case err == nil:
+			delivery.Status = models.AlertDeliveryDelivered
+			delivery.LastError = ""
+		case delivery.Attempts >= s.cfg.MaxAttempts:
+			delivery.Status = models.AlertDeliveryDead
+			delivery.LastError = err.Error()
+		default:
+			delivery.LastError = err.Error()
+			delivery.NextAttemptAt = time.Now().Add(s.backoff(delivery.Attempts))
+		}
+
+		if err := s.alertRepo.UpdateAlertDelivery(ctx, delivery); err != nil {
+			log.Printf("%s: %v", op, err)
+		}
+	}
+}
+
+func (s *alertService) deliver(ctx context.Context, delivery models.AlertDelivery) error {
+	subscription, err := s.alertRepo.GetAlertSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	return sendWebhook(ctx, s.client, delivery, subscription.Secret)
+}
+
+// backoff doubles the delay after every failed attempt up to MaxRetryBackoff
+func (s *alertService) backoff(attempts int) time.Duration {
+	delay := s.cfg.RetryBackoff
+	for i := 1; i < attempts && delay < s.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxRetryBackoff)
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
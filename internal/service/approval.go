@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ApprovalScope lets an actor approve and reject the rate proposals of other actors
+const ApprovalScope = "rates:approve"
+
+var (
+	ErrApprovalRequired  = errors.New("rate change requires approval")
+	ErrApprovalForbidden = errors.New("actor may not decide on the rate proposal")
+)
+
+// PendingApprovalError is returned instead of an updated rate when the change was stored as a
+// proposal that waits for approval
+type PendingApprovalError struct {
+	Proposal models.RateProposal
+}
+
+func (e *PendingApprovalError) Error() string {
+	return fmt.Sprintf("%v: proposal %d", ErrApprovalRequired, e.Proposal.ID)
+}
+
+func (e *PendingApprovalError) Unwrap() error {
+	return ErrApprovalRequired
+}
+
+type ApprovalPolicy struct {
+	// MaxDeviation is the relative change of a rate, e.g. 0.05 for 5%, above which the change
+	// needs approval, zero disables the check
+	MaxDeviation float64
+	// Pairs need approval for every change, keyed by base and target codes, e.g. "USDRUB"
+	Pairs map[string]bool
+	// TTL is how long a proposal waits for a decision before it expires
+	TTL time.Duration
+}
+
+// requiresApproval tells whether changing the current rate to rate needs a second pair of eyes
+func (p ApprovalPolicy) requiresApproval(current models.ExchangeRate, rate float64) bool {
+	if p.Pairs[current.BaseCurrency.Code+current.TargetCurrency.Code] {
+		return true
+	}
+	if p.MaxDeviation <= 0 || current.Rate == 0 {
+		return false
+	}
+	return math.Abs(rate-current.Rate)/current.Rate > p.MaxDeviation
+}
+
+// canDecide checks that the actor in ctx may approve or reject the proposal: it has the approval
+// scope and did not make the proposal itself
+func canDecide(ctx context.Context, proposal models.RateProposal) (models.Actor, error) {
+	actor := models.ActorFromContext(ctx)
+	if actor.Name == "" {
+		return models.Actor{}, fmt.Errorf("%w: the actor is unknown", ErrApprovalForbidden)
+	}
+	if !actor.HasScope(ApprovalScope) {
+		return models.Actor{}, fmt.Errorf("%w: %s lacks the %s scope", ErrApprovalForbidden, actor.Name, ApprovalScope)
+	}
+	if actor.Name == proposal.ProposedBy {
+		return models.Actor{}, fmt.Errorf("%w: %s made the proposal", ErrApprovalForbidden, actor.Name)
+	}
+	return actor, nil
+}
+
+func (s *exchangeRateService) ListRateProposals(ctx context.Context, filter models.RateProposalFilter, page models.PageRequest) (models.Page[models.RateProposal], error) {
+	return s.exchangeRateRepo.ListRateProposals(ctx, filter, page)
+}
+
+func (s *exchangeRateService) GetRateProposal(ctx context.Context, id int) (models.RateProposal, error) {
+	return s.exchangeRateRepo.GetRateProposal(ctx, id)
+}
+
+// ApproveRateProposal applies a pending proposal to its rate, the approver must be another actor
+// with the approval scope
+func (s *exchangeRateService) ApproveRateProposal(ctx context.Context, id int) (models.RateProposal, error) {
+	proposal, err := s.exchangeRateRepo.GetRateProposal(ctx, id)
+	if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	actor, err := canDecide(ctx, proposal)
+	if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	proposal, change, err := s.exchangeRateRepo.ApproveRateProposal(ctx, id, actor.Name)
+	if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	s.notify(ctx, change)
+
+	return proposal, nil
+}
+
+func (s *exchangeRateService) RejectRateProposal(ctx context.Context, id int, reason string) (models.RateProposal, error) {
+	proposal, err := s.exchangeRateRepo.GetRateProposal(ctx, id)
+	if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	actor, err := canDecide(ctx, proposal)
+	if err != nil {
+		return models.RateProposal{}, err
+	}
+
+	return s.exchangeRateRepo.RejectRateProposal(ctx, id, actor.Name, reason)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestApprovalPolicyRequiresApproval(t *testing.T) {
+	current := models.ExchangeRate{BaseCurrency: models.Currency{Code: "USD"}, TargetCurrency: models.Currency{Code: "EUR"}, Rate: 1}
+
+	tests := []struct {
+		name   string
+		policy ApprovalPolicy
+		rate   float64
+		want   bool
+	}{
+		{"disabled", ApprovalPolicy{}, 2, false},
+		{"within the deviation", ApprovalPolicy{MaxDeviation: 0.05}, 1.04, false},
+		{"above the deviation", ApprovalPolicy{MaxDeviation: 0.05}, 1.06, true},
+		{"below the deviation", ApprovalPolicy{MaxDeviation: 0.05}, 0.94, true},
+		{"listed pair", ApprovalPolicy{Pairs: map[string]bool{"USDEUR": true}}, 1.01, true},
+		{"other pair", ApprovalPolicy{Pairs: map[string]bool{"EURUSD": true}}, 1.01, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.requiresApproval(current, tt.rate); got != tt.want {
+			t.Errorf("%s: requiresApproval(%v) = %v, want %v", tt.name, tt.rate, got, tt.want)
+		}
+	}
+}
+
+// propose makes alice change USDEUR to rate and returns the proposal the change is held in
+func propose(t *testing.T, s *exchangeRateService, rate float64) models.RateProposal {
+	t.Helper()
+	ctx := models.ContextWithActor(context.Background(), models.Actor{Name: "alice", Scopes: []string{ApprovalScope}})
+
+	var pending *PendingApprovalError
+	if _, err := s.UpdateExchangeRate(ctx, "USD", "EUR", rate, 0); !errors.As(err, &pending) {
+		t.Fatalf("UpdateExchangeRate(%v): err = %v, want a pending approval", rate, err)
+	}
+	if pending.Proposal.ProposedBy != "alice" || pending.Proposal.Status != models.RateProposalPending {
+		t.Fatalf("proposal = %+v, want a pending one of alice", pending.Proposal)
+	}
+	return pending.Proposal
+}
+
+func TestApproveRateProposal(t *testing.T) {
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	changes := &recorder{}
+	s := NewExchangeRateService(repo, ApprovalPolicy{MaxDeviation: 0.05, TTL: time.Hour}, RateGuardPolicy{}, false, changes)
+	proposal := propose(t, s, 1)
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.9 {
+		t.Fatalf("USDEUR = %v while the change waits for approval, want 0.9", got.Rate)
+	}
+
+	actors := []struct {
+		name  string
+		actor models.Actor
+	}{
+		{"unknown actor", models.Actor{}},
+		{"actor without the scope", models.Actor{Name: "bob"}},
+		{"proposer", models.Actor{Name: "alice", Scopes: []string{ApprovalScope}}},
+	}
+	for _, tt := range actors {
+		ctx := models.ContextWithActor(context.Background(), tt.actor)
+		if _, err := s.ApproveRateProposal(ctx, proposal.ID); !errors.Is(err, ErrApprovalForbidden) {
+			t.Errorf("approval of the %s: err = %v, want ErrApprovalForbidden", tt.name, err)
+		}
+		if _, err := s.RejectRateProposal(ctx, proposal.ID, "no"); !errors.Is(err, ErrApprovalForbidden) {
+			t.Errorf("rejection of the %s: err = %v, want ErrApprovalForbidden", tt.name, err)
+		}
+	}
+
+	carol := models.ContextWithActor(context.Background(), models.Actor{Name: "carol", Scopes: []string{ApprovalScope}})
+	approved, err := s.ApproveRateProposal(carol, proposal.ID)
+	if err != nil {
+		t.Fatalf("ApproveRateProposal: %v", err)
+	}
+	if approved.Status != models.RateProposalApproved || approved.DecidedBy != "carol" {
+		t.Errorf("approved proposal = %+v, want one approved by carol", approved)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 1 {
+		t.Errorf("USDEUR = %v after the approval, want 1", got.Rate)
+	}
+	if len(changes.changes) != 1 || changes.changes[0].Current.Rate != 1 {
+		t.Errorf("changes = %+v, want the approved rate", changes.changes)
+	}
+
+	if _, err := s.ApproveRateProposal(carol, proposal.ID); !errors.Is(err, repository.ErrRateProposalNotPending) {
+		t.Errorf("second approval: err = %v, want ErrRateProposalNotPending", err)
+	}
+}
+
+func TestApproveOutdatedRateProposal(t *testing.T) {
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{MaxDeviation: 0.05, TTL: time.Hour}, RateGuardPolicy{}, false)
+	proposal := propose(t, s, 1)
+
+	// a small change goes through and leaves the proposal behind the rate
+	if _, err := s.UpdateExchangeRate(context.Background(), "USD", "EUR", 0.91, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+
+	carol := models.ContextWithActor(context.Background(), models.Actor{Name: "carol", Scopes: []string{ApprovalScope}})
+	if _, err := s.ApproveRateProposal(carol, proposal.ID); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("approval of an outdated proposal: err = %v, want ErrVersionMismatch", err)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.91 {
+		t.Errorf("USDEUR = %v, want 0.91", got.Rate)
+	}
+
+	rejected, err := s.RejectRateProposal(carol, proposal.ID, "outdated")
+	if err != nil {
+		t.Fatalf("RejectRateProposal: %v", err)
+	}
+	if rejected.Status != models.RateProposalRejected || rejected.Reason != "outdated" {
+		t.Errorf("rejected proposal = %+v, want it rejected as outdated", rejected)
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// maxArbitrageCycles bounds the loops one search reports, every loop found costs a Bellman-Ford run
+const maxArbitrageCycles = 100
+
+// arbitrageEpsilon keeps rounding errors from passing a loop of exactly inverse rates as profitable
+const arbitrageEpsilon = 1e-12
+
+type ArbitragePolicy struct {
+	// Threshold is the least profit of a reported loop, e.g. 0.001 for 0.1%
+	Threshold float64
+	// Block rejects rate writes that make a loop through a written pair more profitable than
+	// the threshold and than it was before
+	Block bool
+}
+
+// FindArbitrage looks for loops of conversions over the stored rates that return more than they
+// take, the pairs without a rate are converted through the inverse of the reverse pair
+func (s *exchangeRateService) FindArbitrage(ctx context.Context, filter models.ArbitrageFilter) (models.ArbitrageReport, error) {
+	threshold := s.guard.Arbitrage.Threshold
+	if filter.Threshold != nil {
+		threshold = *filter.Threshold
+	}
+
+	rates, err := s.exchangeRateRepo.GetAllExchangeRates(ctx)
+	if err != nil && !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return models.ArbitrageReport{}, err
+	}
+
+	report := models.ArbitrageReport{Threshold: threshold, Cycles: []models.ArbitrageCycle{}}
+	for _, cycle := range newRateBook(rates).graph().cycles(threshold) {
+		if filter.Currency == "" || slices.Contains(cycle.Currencies, filter.Currency) {
+			report.Cycles = append(report.Cycles, cycle)
+		}
+	}
+
+	return report, nil
+}
+
+// checkArbitrage rejects writes the arbitrage policy blocks, a single rejected write is returned
+// as a *RateError and several as RateErrors
+func (s *exchangeRateService) checkArbitrage(ctx context.Context, writes ...models.RateUpdate) error {
+	if !s.guard.Arbitrage.Block {
+		return nil
+	}
+
+	rates, err := s.exchangeRateRepo.GetAllExchangeRates(ctx)
+	if err != nil && !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return err
+	}
+
+	switch rejected := s.guard.Arbitrage.checkWrites(rates, writes); len(rejected) {
+	case 0:
+		return nil
+	case 1:
+		return rejected[0]
+	default:
+		return rejected
+	}
+}
+
+// checkWrites finds the written pairs that take part in a loop which is more profitable than the
+// threshold after the writes and more profitable than before them, rates are the stored ones
+func (p ArbitragePolicy) checkWrites(rates []models.ExchangeRate, writes []models.RateUpdate) RateErrors {
+	before := newRateBook(rates)
+	after := maps.Clone(before)
+	written := make(map[[2]string]bool, len(writes))
+	for _, w := range writes {
+		pair := [2]string{w.BaseCurrencyCode, w.TargetCurrencyCode}
+		after[pair] = w.Rate
+		written[pair] = true
+	}
+
+	var rejected RateErrors
+	for _, cycle := range after.graph().cycles(p.Threshold) {
+		// a write that leaves a loop as it was or makes it less profitable is no worse than the book
+		if cycle.Product <= before.product(cycle.Legs)+arbitrageEpsilon {
+			continue
+		}
+
+		for _, leg := range cycle.Legs {
+			pair := [2]string{leg.BaseCurrencyCode, leg.TargetCurrencyCode}
+			if leg.Inverse {
+				pair = [2]string{leg.TargetCurrencyCode, leg.BaseCurrencyCode}
+			}
+			if !written[pair] {
+				continue
+			}
+			written[pair] = false
+
+			rejected = append(rejected, &RateError{Pair: pair[0] + pair[1], Code: "arbitrage",
+				Message: fmt.Sprintf("rate makes the loop %s return %.6g per unit", strings.Join(cycle.Currencies, "→"), cycle.Product)})
+		}
+	}
+
+	return rejected
+}
+
+// rateBook holds rates by base and target code
+type rateBook map[[2]string]float64
+
+func newRateBook(rates []models.ExchangeRate) rateBook {
+	book := make(rateBook, len(rates))
+	for _, rate := range rates {
+		// conversions refuse inactive currencies, their rates are part of no loop
+		if !rate.BaseCurrency.Active || !rate.TargetCurrency.Active {
+			continue
+		}
+		// derived rates lag their components for the moment a component is written, which is no
+		// loop anyone can trade
+		if rate.Source == models.RateSourceBasket || rate.Source == models.RateSourcePeg {
+			continue
+		}
+		book[[2]string{rate.BaseCurrency.Code, rate.TargetCurrency.Code}] = rate.Rate
+	}
+	return book
+}
+
+// usableRate tells whether a stored rate can be converted with, rates stored before the guard
+// could be zero or negative
+func usableRate(rate float64) bool {
+	return rate > 0 && !math.IsInf(rate, 1)
+}
+
+// rate is what a conversion from one currency to another without a cross currency uses, the
+// stored rate or the inverse of the reverse one
+func (b rateBook) rate(fromCode, toCode string) (rate float64, inverse bool, ok bool) {
+	if r, ok := b[[2]string{fromCode, toCode}]; ok && usableRate(r) {
+		return r, false, true
+	}
+	if r, ok := b[[2]string{toCode, fromCode}]; ok && usableRate(r) {
+		return 1 / r, true, true
+	}
+	return 0, false, false
+}
+
+// product is what one unit becomes going along legs at the rates of the book, zero when a leg
+// has no rate
+func (b rateBook) product(legs []models.ArbitrageLeg) float64 {
+	product := 1.0
+	for _, leg := range legs {
+		rate, _, ok := b.rate(leg.BaseCurrencyCode, leg.TargetCurrencyCode)
+		if !ok {
+			return 0
+		}
+		product *= rate
+	}
+	return product
+}
+
+// rateEdge is a conversion between two currencies of a rateGraph, weighted by -log(rate) so a
+// loop that returns more than it takes is a negative cycle
+type rateEdge struct {
+	from, to int
+	rate     float64
+	inverse  bool
+	weight   float64
+}
+
+type rateGraph struct {
+	codes []string
+	edges []rateEdge
+}
+
+// graph has an edge for every usable rate and one for the inverse of it when the reverse pair
+// has no usable rate
+func (b rateBook) graph() rateGraph {
+	pairs := slices.SortedFunc(maps.Keys(b), func(a, b [2]string) int {
+		return strings.Compare(a[0]+a[1], b[0]+b[1])
+	})
+
+	var g rateGraph
+	index := make(map[string]int)
+	node := func(code string) int {
+		i, ok := index[code]
+		if !ok {
+			i = len(g.codes)
+			index[code] = i
+			g.codes = append(g.codes, code)
+		}
+		return i
+	}
+	edge := func(from, to int, rate float64, inverse bool) {
+		g.edges = append(g.edges, rateEdge{from: from, to: to, rate: rate, inverse: inverse, weight: -math.Log(rate)})
+	}
+
+	for _, pair := range pairs {
+		rate := b[pair]
+		if !usableRate(rate) {
+			continue
+		}
+
+		base, target := node(pair[0]), node(pair[1])
+		edge(base, target, rate, false)
+		if reverse, ok := b[[2]string{pair[1], pair[0]}]; !ok || !usableRate(reverse) {
+			edge(target, base, 1/rate, true)
+		}
+	}
+
+	return g
+}
+
+// cycles finds loops whose profit is above threshold, most profitable first. Bellman-Ford finds
+// one negative cycle per run, an edge of every loop found is dropped before the next run so the
+// search ends, loops sharing that edge with a found one are not reported.
+func (g rateGraph) cycles(threshold float64) []models.ArbitrageCycle {
+	removed := make([]bool, len(g.edges))
+	seen := make(map[string]bool)
+
+	var cycles []models.ArbitrageCycle
+	for range g.edges {
+		found := g.negativeCycle(removed)
+		if found == nil {
+			break
+		}
+
+		removed[found[0]] = true
+
+		cycle := g.arbitrageCycle(found)
+		key := strings.Join(cycle.Currencies, ",")
+		if seen[key] || cycle.Profit <= threshold {
+			continue
+		}
+		seen[key] = true
+
+		cycles = append(cycles, cycle)
+		if len(cycles) == maxArbitrageCycles {
+			break
+		}
+	}
+
+	slices.SortStableFunc(cycles, func(a, b models.ArbitrageCycle) int {
+		return cmp.Compare(b.Profit, a.Profit)
+	})
+
+	return cycles
+}
+
+// negativeCycle runs Bellman-Ford from a virtual source joined to every currency and returns the
+// edges of a negative cycle in order, nil when there is none
+func (g rateGraph) negativeCycle(removed []bool) []int {
+	n := len(g.codes)
+	dist := make([]float64, n)
+	pred := make([]int, n)
+	for i := range pred {
+		pred[i] = -1
+	}
+
+	relaxed := -1
+	for range n {
+		relaxed = -1
+		for e, edge := range g.edges {
+			if removed[e] {
+				continue
+			}
+			if d := dist[edge.from] + edge.weight; d < dist[edge.to]-arbitrageEpsilon {
+				dist[edge.to] = d
+				pred[edge.to] = e
+				relaxed = edge.to
+			}
+		}
+		if relaxed == -1 {
+			return nil
+		}
+	}
+
+	// a currency still relaxed after n rounds is reached through a negative cycle, n steps back
+	// along the predecessors land on the cycle
+	v := relaxed
+	for range n {
+		v = g.edges[pred[v]].from
+	}
+
+	var cycle []int
+	for u := v; ; {
+		e := pred[u]
+		cycle = append(cycle, e)
+		u = g.edges[e].from
+		if u == v {
+			break
+		}
+		if len(cycle) > n {
+			return nil
+		}
+	}
+	slices.Reverse(cycle)
+
+	return cycle
+}
+
+// arbitrageCycle describes the loop of edges starting at its alphabetically first currency
+func (g rateGraph) arbitrageCycle(edges []int) models.ArbitrageCycle {
+	first := 0
+	for i, e := range edges {
+		if g.codes[g.edges[e].from] < g.codes[g.edges[edges[first]].from] {
+			first = i
+		}
+	}
+	edges = slices.Concat(edges[first:], edges[:first])
+
+	cycle := models.ArbitrageCycle{Product: 1}
+	for _, e := range edges {
+		edge := g.edges[e]
+		cycle.Currencies = append(cycle.Currencies, g.codes[edge.from])
+		cycle.Legs = append(cycle.Legs, models.ArbitrageLeg{
+			BaseCurrencyCode:   g.codes[edge.from],
+			TargetCurrencyCode: g.codes[edge.to],
+			Rate:               edge.rate,
+			Inverse:            edge.inverse,
+		})
+		cycle.Product *= edge.rate
+	}
+	cycle.Currencies = append(cycle.Currencies, cycle.Currencies[0])
+	cycle.Profit = cycle.Product - 1
+
+	return cycle
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestFindArbitrage(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "GBP", "RUB")
+	addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "EURGBP", 0.9)
+	// 1 USD → 0.9 EUR → 0.81 GBP → 1.215 USD
+	addRate(t, repo, "GBPUSD", 1.5)
+	addRate(t, repo, "USDRUB", 90)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{Arbitrage: ArbitragePolicy{Threshold: 0.001}}, false)
+
+	report, err := s.FindArbitrage(ctx, models.ArbitrageFilter{})
+	if err != nil {
+		t.Fatalf("FindArbitrage: %v", err)
+	}
+	if len(report.Cycles) != 1 {
+		t.Fatalf("cycles = %+v, want the USD, EUR, GBP loop", report.Cycles)
+	}
+	cycle := report.Cycles[0]
+	for _, code := range []string{"USD", "EUR", "GBP"} {
+		if !slices.Contains(cycle.Currencies, code) {
+			t.Errorf("loop %v does not go through %s", cycle.Currencies, code)
+		}
+	}
+	if math.Abs(cycle.Product-1.215) > 1e-9 || math.Abs(cycle.Profit-0.215) > 1e-9 {
+		t.Errorf("loop product = %v, profit %v, want 1.215 and 0.215", cycle.Product, cycle.Profit)
+	}
+
+	threshold := 0.5
+	filters := []models.ArbitrageFilter{{Threshold: &threshold}, {Currency: "RUB"}}
+	for _, filter := range filters {
+		report, err := s.FindArbitrage(ctx, filter)
+		if err != nil || len(report.Cycles) != 0 {
+			t.Errorf("FindArbitrage(%+v) = %+v, %v, want no loops", filter, report.Cycles, err)
+		}
+	}
+}
+
+func TestArbitrageBlocksWrites(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "GBP")
+	addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "EURGBP", 0.9)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{Arbitrage: ArbitragePolicy{Threshold: 0.001, Block: true}}, false)
+
+	var rateErr *RateError
+	if _, err := s.AddExchangeRate(ctx, "GBP", "USD", 1.5); !errors.As(err, &rateErr) || rateErr.Code != "arbitrage" || rateErr.Pair != "GBPUSD" {
+		t.Fatalf("AddExchangeRate of a profitable loop: err = %v, want arbitrage of GBPUSD", err)
+	}
+	if _, ok := storedRate(t, repo, "GBPUSD"); ok {
+		t.Error("the blocked rate was stored")
+	}
+	// within the threshold of 1 / 0.81
+	if _, err := s.AddExchangeRate(ctx, "GBP", "USD", 1.2350); err != nil {
+		t.Fatalf("AddExchangeRate within the threshold: %v", err)
+	}
+
+	// a loop that was already there does not block a write that makes it less profitable
+	if _, err := repo.UpdateExchangeRate(ctx, "GBP", "USD", 1.5, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+	if _, err := s.UpdateExchangeRate(ctx, "GBP", "USD", 1.4, 0); err != nil {
+		t.Errorf("update that shrinks the loop: %v", err)
+	}
+	if _, err := s.UpdateExchangeRate(ctx, "GBP", "USD", 1.6, 0); !errors.As(err, &rateErr) || rateErr.Code != "arbitrage" {
+		t.Errorf("update that grows the loop: err = %v, want arbitrage", err)
+	}
+}
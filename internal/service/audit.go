@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"io"
+	"strconv"
+	"time"
+)
+
+// auditExportPageSize is how many entries an export reads at a time
+const auditExportPageSize = 1000
+
+// auditColumns are the CSV columns of an audit export
+var auditColumns = []string{"id", "createdAt", "entity", "key", "action", "actor", "sourceIp", "requestId", "oldValue", "newValue"}
+
+type auditService struct {
+	auditRepo auditRepository
+}
+
+func NewAuditService(auditRepo auditRepository) *auditService {
+	return &auditService{
+		auditRepo: auditRepo,
+	}
+}
+
+type auditRepository interface {
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter, page models.PageRequest) (models.Page[models.AuditEntry], error)
+}
+
+func (s *auditService) ListAuditEntries(ctx context.Context, filter models.AuditFilter, page models.PageRequest) (models.Page[models.AuditEntry], error) {
+	return s.auditRepo.ListAuditEntries(ctx, filter, page)
+}
+
+// ExportAuditEntries writes every entry matching the filter as CSV, oldest first. The entries
+// are read a page at a time so a long log is not held in memory.
+func (s *auditService) ExportAuditEntries(ctx context.Context, w io.Writer, filter models.AuditFilter) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(auditColumns); err != nil {
+		return err
+	}
+
+	page := models.PageRequest{Limit: auditExportPageSize}
+	for {
+		result, err := s.auditRepo.ListAuditEntries(ctx, filter, page)
+		if errors.Is(err, repository.ErrAuditEntryNotFound) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		for _, e := range result.Items {
+			row := []string{
+				strconv.Itoa(e.ID), e.CreatedAt.Format(time.RFC3339Nano), string(e.Entity), e.Key, string(e.Action),
+				e.Actor, e.SourceIP, e.RequestID, string(e.OldValue), string(e.NewValue),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor = result.NextCursor
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// basketService derives the rates of synthetic currencies from the rates of their components and
+// stores them like any other rate, so conversions and the rate endpoints use them as they are
+type basketService struct {
+	basketRepo  basketRepository
+	stalePolicy StaleRatePolicy
+	// pivots are the cross currencies of the components without a rate to a currency
+	pivots    []string
+	listeners []RateListener
+
+	mu sync.Mutex
+	// generation is the latest generation of the rates the derived ones were computed from
+	generation int64
+}
+
+func NewBasketService(basketRepo basketRepository, stalePolicy StaleRatePolicy, pivots []string, listeners ...RateListener) *basketService {
+	return &basketService{
+		basketRepo:  basketRepo,
+		stalePolicy: stalePolicy,
+		pivots:      pivots,
+		listeners:   listeners,
+	}
+}
+
+type basketRepository interface {
+	GetAllCurrencies(ctx context.Context) ([]models.Currency, error)
+	GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	GetBasketCurrencies(ctx context.Context) ([]models.Currency, error)
+	SetDerivedRates(ctx context.Context, source string, rates []models.RateUpdate) ([]models.RateChange, error)
+}
+
+// RateChanged derives the rates of the baskets again after a rate changed, the changes of a
+// generation are handled once and every delete on its own
+func (s *basketService) RateChanged(ctx context.Context, change models.RateChange) {
+	const op = "internal.service.service.RateChanged"
+
+	if change.Current.Source == models.RateSourceBasket {
+		return
+	}
+
+	generation := change.Current.Generation
+	if change.Deleted {
+		generation = 0
+	}
+	if err := s.updateBasketRates(ctx, generation); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+}
+
+// UpdateBasketRates derives the rate of every synthetic currency to every real one it can be
+// converted to, the rates that can not be derived, e.g. because a rate of a component is
+// missing or stale, are deleted until they can be derived again
+func (s *basketService) UpdateBasketRates(ctx context.Context) error {
+	return s.updateBasketRates(ctx, 0)
+}
+
+// updateBasketRates does nothing when the rates of generation were already seen, zero forces it
+func (s *basketService) updateBasketRates(ctx context.Context, generation int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if generation != 0 && generation <= s.generation {
+		return nil
+	}
+
+	baskets, err := s.basketRepo.GetBasketCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	currencies, err := s.basketRepo.GetAllCurrencies(ctx)
+	if err != nil && !errors.Is(err, repository.ErrCurrencyNotFound) {
+		return err
+	}
+
+	rates, err := s.basketRepo.GetAllExchangeRates(ctx)
+	if err != nil && !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return err
+	}
+
+	synthetic := make(map[string]bool, len(baskets))
+	for _, basket := range baskets {
+		synthetic[basket.Code] = true
+	}
+	active := make(map[string]bool, len(currencies))
+	for _, currency := range currencies {
+		active[currency.Code] = true
+	}
+
+	// derived rates are never a component of another one
+	var real []models.ExchangeRate
+	for _, rate := range rates {
+		if synthetic[rate.BaseCurrency.Code] || synthetic[rate.TargetCurrency.Code] {
+			continue
+		}
+		real = append(real, rate)
+		s.generation = max(s.generation, rate.Generation)
+	}
+	s.generation = max(s.generation, generation)
+
+	q := newQuotes(real)
+	now := time.Now()
+
+	var updates []models.RateUpdate
+	for _, basket := range baskets {
+		for _, currency := range currencies {
+			if synthetic[currency.Code] {
+				continue
+			}
+			if rate, updatedAt, ok := s.basketRate(q, basket.Basket, currency.Code, active, now); ok {
+				updates = append(updates, models.RateUpdate{BaseCurrencyCode: basket.Code, TargetCurrencyCode: currency.Code, Rate: rate, UpdatedAt: updatedAt})
+			}
+		}
+	}
+
+	changes, err := s.basketRepo.SetDerivedRates(ctx, models.RateSourceBasket, updates)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		for _, listener := range s.listeners {
+			listener.RateChanged(ctx, change)
+		}
+	}
+
+	return nil
+}
+
+// basketRate is what one unit of a basket is worth in a currency, the rates of the components
+// are resolved the way conversions resolve them, and when the oldest rate used was updated.
+// There is none while a component is inactive.
+func (s *basketService) basketRate(q quotes, basket []models.BasketComponent, toCode string, active map[string]bool, now time.Time) (float64, time.Time, bool) {
+	var rate float64
+	var legs []models.ExchangeRate
+	for _, c := range basket {
+		if !active[c.CurrencyCode] {
+			return 0, time.Time{}, false
+		}
+		if c.CurrencyCode == toCode {
+			rate += c.Amount
+			continue
+		}
+
+		routes := q.routes(c.CurrencyCode, toCode, s.pivots)
+		chosen, _, err := s.stalePolicy.pickRoute(routes, now)
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+		rate += c.Amount * routes[chosen].rate
+		legs = append(legs, routes[chosen].legs...)
+	}
+	return rate, oldest(legs), true
+}
+
+// derivedWrites are the writes that set a rate of a synthetic currency, which only its basket does
+func derivedWrites(baskets []models.Currency, writes []models.RateUpdate) RateErrors {
+	synthetic := make(map[string]bool, len(baskets))
+	for _, basket := range baskets {
+		synthetic[basket.Code] = true
+	}
+
+	var rejected RateErrors
+	for _, w := range writes {
+		if synthetic[w.BaseCurrencyCode] || synthetic[w.TargetCurrencyCode] {
+			rejected = append(rejected, &RateError{Pair: w.BaseCurrencyCode + w.TargetCurrencyCode, Code: "derived",
+				Message: "rates of a synthetic currency are derived from its basket and can not be set"})
+		}
+	}
+	return rejected
+}
+
+// checkDerived rejects writes of the rates of synthetic currencies and the ones that break a peg,
+// a single rejected write is returned as a *RateError and several as RateErrors
+func (s *exchangeRateService) checkDerived(ctx context.Context, writes ...models.RateUpdate) error {
+	baskets, err := s.exchangeRateRepo.GetBasketCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	pegs, err := s.exchangeRateRepo.GetAllPegs(ctx)
+	if err != nil && !errors.Is(err, repository.ErrPegNotFound) {
+		return err
+	}
+
+	rejected := append(derivedWrites(baskets, writes), pegWrites(pegs, writes)...)
+	switch len(rejected) {
+	case 0:
+		return nil
+	case 1:
+		return rejected[0]
+	default:
+		return rejected
+	}
+}
+
+// checkDerivedDelete rejects deleting a rate of a synthetic or pegged currency, the next
+// derivation would bring it back
+func (s *exchangeRateService) checkDerivedDelete(ctx context.Context, baseCode, targetCode string) error {
+	baskets, err := s.exchangeRateRepo.GetBasketCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	pegs, err := s.exchangeRateRepo.GetAllPegs(ctx)
+	if err != nil && !errors.Is(err, repository.ErrPegNotFound) {
+		return err
+	}
+
+	for _, code := range []string{baseCode, targetCode} {
+		for _, basket := range baskets {
+			if basket.Code == code {
+				return &RateError{Pair: baseCode + targetCode, Code: "derived",
+					Message: "rates of a synthetic currency are derived from its basket and can not be deleted"}
+			}
+		}
+		for _, peg := range pegs {
+			if peg.CurrencyCode == code {
+				return &RateError{Pair: baseCode + targetCode, Code: "derived",
+					Message: fmt.Sprintf("rates of %s are kept by its peg and can not be deleted, delete the peg instead", code)}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"exchanger/internal/models"
+)
+
+func TestBasketRatesFollowTheirComponents(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	addRate(t, repo, "USDRUB", 90)
+	addRate(t, repo, "EURRUB", 100)
+	addRate(t, repo, "EURUSD", 1.1)
+
+	basket := []models.BasketComponent{{CurrencyCode: "USD", Amount: 0.5}, {CurrencyCode: "EUR", Amount: 0.5}}
+	if _, err := repo.AddCurrency(ctx, models.Currency{Code: "XBU", Name: "Basket", Sign: "X", Basket: basket}); err != nil {
+		t.Fatalf("AddCurrency: %v", err)
+	}
+
+	rec := &recorder{}
+	baskets := NewBasketService(repo, StaleRatePolicy{}, nil, rec)
+	rates := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, baskets)
+
+	if err := baskets.UpdateBasketRates(ctx); err != nil {
+		t.Fatalf("UpdateBasketRates: %v", err)
+	}
+	for pair, want := range map[string]float64{"XBURUB": 95, "XBUUSD": 1.05, "XBUEUR": 0.5/1.1 + 0.5} {
+		if er, ok := storedRate(t, repo, pair); !ok || math.Abs(er.Rate-want) > 1e-12 || er.Source != models.RateSourceBasket {
+			t.Errorf("%s = %+v, %v, want %g from the basket", pair, er, ok, want)
+		}
+	}
+
+	var rateErr *RateError
+	if _, err := rates.UpdateExchangeRate(ctx, "XBU", "RUB", 1, 0); !errors.As(err, &rateErr) || rateErr.Code != "derived" {
+		t.Errorf("writing XBURUB: err = %v, want a derived RateError", err)
+	}
+
+	// a component refreshed at the same value refreshes the basket rate
+	if _, err := rates.UpdateExchangeRate(ctx, "EUR", "USD", 1.1, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+	eurusd, _ := storedRate(t, repo, "EURUSD")
+	if xbuusd, _ := storedRate(t, repo, "XBUUSD"); !xbuusd.UpdatedAt.Equal(eurusd.UpdatedAt) {
+		t.Errorf("XBUUSD updated at %v, want %v like EURUSD", xbuusd.UpdatedAt, eurusd.UpdatedAt)
+	}
+
+	// without EURRUB the basket has no rate to RUB, the derived one is deleted instead of kept
+	if err := rates.DeleteExchangeRate(ctx, "EUR", "RUB"); err != nil {
+		t.Fatalf("DeleteExchangeRate: %v", err)
+	}
+	if er, ok := storedRate(t, repo, "XBURUB"); ok {
+		t.Errorf("XBURUB = %+v after EURRUB was deleted, want none", er)
+	}
+	if got := rec.deleted(); !slices.Equal(got, []string{"XBURUB"}) {
+		t.Errorf("deleted pairs = %v, want [XBURUB]", got)
+	}
+
+	if _, err := rates.AddExchangeRate(ctx, "EUR", "RUB", 100); err != nil {
+		t.Fatalf("AddExchangeRate: %v", err)
+	}
+	if er, ok := storedRate(t, repo, "XBURUB"); !ok || er.Rate != 95 {
+		t.Errorf("XBURUB = %+v, %v after EURRUB was created again, want 95", er, ok)
+	}
+}
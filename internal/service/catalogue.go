@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"io"
+	"math"
+)
+
+var (
+	// ErrImportRejected is returned with a report whose Errors tell which records were rejected
+	ErrImportRejected   = errors.New("import rejected")
+	ErrInvalidCatalogue = errors.New("invalid catalogue file")
+)
+
+type catalogueService struct {
+	catalogueRepo catalogueRepository
+	approval      ApprovalPolicy
+	guard         RateGuardPolicy
+	listeners     []RateListener
+	// requireVersion rejects records that change a rate without saying which version they change
+	requireVersion bool
+}
+
+func NewCatalogueService(catalogueRepo catalogueRepository, approval ApprovalPolicy, guard RateGuardPolicy, requireVersion bool, listeners ...RateListener) *catalogueService {
+	return &catalogueService{
+		catalogueRepo:  catalogueRepo,
+		approval:       approval,
+		guard:          guard,
+		requireVersion: requireVersion,
+		listeners:      listeners,
+	}
+}
+
+type catalogueRepository interface {
+	GetCatalogue(ctx context.Context) (models.Catalogue, error)
+	ImportCatalogue(ctx context.Context, catalogue models.Catalogue, dryRun bool) (models.ImportReport, error)
+	GetExchangeRatesByPairs(ctx context.Context, pairs [][2]string) ([]models.ExchangeRate, error)
+	GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	GetBasketCurrencies(ctx context.Context) ([]models.Currency, error)
+	GetAllPegs(ctx context.Context) ([]models.Peg, error)
+}
+
+// ExportCatalogue writes every currency and active rate to w
+func (s *catalogueService) ExportCatalogue(ctx context.Context, w io.Writer, format CatalogueFormat) error {
+	const op = "internal.service.service.ExportCatalogue"
+
+	catalogue, err := s.catalogueRepo.GetCatalogue(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := encodeCatalogue(w, catalogue, format); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ImportCatalogue reads a catalogue file and creates or updates every record of it, or none of
+// them when one is rejected. A dry run reports what would be done without writing anything.
+func (s *catalogueService) ImportCatalogue(ctx context.Context, r io.Reader, format CatalogueFormat, dryRun bool) (models.ImportReport, error) {
+	const op = "internal.service.service.ImportCatalogue"
+
+	report := models.ImportReport{DryRun: dryRun, Rows: []models.ImportRow{}}
+
+	catalogue, err := decodeCatalogue(r, format, &report)
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	validateCatalogue(catalogue, &report)
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("%s: %w", op, ErrImportRejected)
+	}
+
+	if err := s.checkRates(ctx, catalogue, &report); err != nil {
+		return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("%s: %w", op, ErrImportRejected)
+	}
+
+	report, err = s.catalogueRepo.ImportCatalogue(ctx, catalogue, dryRun)
+	if err != nil {
+		return models.ImportReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("%s: %w", op, ErrImportRejected)
+	}
+
+	for _, change := range report.RateChanges {
+		for _, listener := range s.listeners {
+			listener.RateChanged(ctx, change)
+		}
+	}
+
+	return report, nil
+}
+
+// validateCatalogue reports the records that are invalid whatever is stored, fields the
+// decoder already rejected are skipped
+func validateCatalogue(catalogue models.Catalogue, report *models.ImportReport) {
+	rejected := make(map[string]bool)
+	for _, e := range report.Errors {
+		rejected[e.Ref+"."+e.Field] = true
+	}
+
+	codes := make(map[string]string)
+	for _, c := range catalogue.Currencies {
+		if c.Code == "" {
+			report.AddError(c.Ref, "code", "required", "code is required")
+		} else if first, ok := codes[c.Code]; ok {
+			report.AddError(c.Ref, "code", "duplicate", fmt.Sprintf("currency %s is already in %s", c.Code, first))
+		} else {
+			codes[c.Code] = c.Ref
+		}
+		if c.Name == "" {
+			report.AddError(c.Ref, "name", "required", "name is required")
+		}
+		if c.Sign == "" {
+			report.AddError(c.Ref, "sign", "required", "sign is required")
+		}
+	}
+
+	pairs := make(map[string]string)
+	for _, er := range catalogue.ExchangeRates {
+		if er.BaseCurrencyCode == "" {
+			report.AddError(er.Ref, "baseCurrencyCode", "required", "baseCurrencyCode is required")
+		}
+		if er.TargetCurrencyCode == "" {
+			report.AddError(er.Ref, "targetCurrencyCode", "required", "targetCurrencyCode is required")
+		}
+		if er.BaseCurrencyCode != "" && er.BaseCurrencyCode == er.TargetCurrencyCode {
+			report.AddError(er.Ref, "targetCurrencyCode", "invalid", "base and target currencies must differ")
+		}
+		// NaN and Inf are parsed from CSV files
+		if !(er.Rate > 0) || math.IsInf(er.Rate, 1) {
+			if !rejected[er.Ref+".rate"] {
+				report.AddError(er.Ref, "rate", "invalid", "rate must be a finite number greater than zero")
+			}
+		}
+		if er.Version < 0 && !rejected[er.Ref+".version"] {
+			report.AddError(er.Ref, "version", "invalid", "version must be greater than zero")
+		}
+
+		pair := er.BaseCurrencyCode + er.TargetCurrencyCode
+		if first, ok := pairs[pair]; ok {
+			report.AddError(er.Ref, "targetCurrencyCode", "duplicate", fmt.Sprintf("exchange rate %s is already in %s", pair, first))
+		} else {
+			pairs[pair] = er.Ref
+		}
+	}
+}
+
+// checkRates reports the rates the guard rejects, the ones whose change the approval policy
+// holds back, the changed ones without a version when it is required, the ones of synthetic
+// currencies and the ones that make an arbitrage loop, an import can not bypass the checks of a
+// single update
+func (s *catalogueService) checkRates(ctx context.Context, catalogue models.Catalogue, report *models.ImportReport) error {
+	pairs := make([][2]string, len(catalogue.ExchangeRates))
+	for i, er := range catalogue.ExchangeRates {
+		pairs[i] = [2]string{er.BaseCurrencyCode, er.TargetCurrencyCode}
+	}
+
+	current, err := s.catalogueRepo.GetExchangeRatesByPairs(ctx, pairs)
+	if err != nil {
+		return err
+	}
+	rates := make(map[string]models.ExchangeRate, len(current))
+	for _, rate := range current {
+		rates[rate.BaseCurrency.Code+rate.TargetCurrency.Code] = rate
+	}
+
+	for _, er := range catalogue.ExchangeRates {
+		rate, ok := rates[er.BaseCurrencyCode+er.TargetCurrencyCode]
+		var previous *models.ExchangeRate
+		if ok {
+			previous = &rate
+		}
+
+		var rateErr *RateError
+		if errors.As(s.guard.check(er.BaseCurrencyCode, er.TargetCurrencyCode, er.Rate, previous), &rateErr) {
+			report.AddError(er.Ref, "rate", rateErr.Code, rateErr.Message)
+		} else if ok && rate.Rate != er.Rate && s.approval.requiresApproval(rate, er.Rate) {
+			report.AddError(er.Ref, "rate", "approval_required",
+				fmt.Sprintf("changing the rate from %g needs approval, update the pair on its own to propose it", rate.Rate))
+		}
+		if ok && rate.Rate != er.Rate && er.Version == 0 && s.requireVersion {
+			report.AddError(er.Ref, "version", "required", "version of the exchange rate is required to change it")
+		}
+	}
+
+	writes := make([]models.RateUpdate, len(catalogue.ExchangeRates))
+	refs := make(map[string]string, len(catalogue.ExchangeRates))
+	for i, er := range catalogue.ExchangeRates {
+		writes[i] = models.RateUpdate{BaseCurrencyCode: er.BaseCurrencyCode, TargetCurrencyCode: er.TargetCurrencyCode, Rate: er.Rate}
+		refs[er.BaseCurrencyCode+er.TargetCurrencyCode] = er.Ref
+	}
+
+	baskets, err := s.catalogueRepo.GetBasketCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+	pegs, err := s.catalogueRepo.GetAllPegs(ctx)
+	if err != nil && !errors.Is(err, repository.ErrPegNotFound) {
+		return err
+	}
+	for _, rateErr := range append(derivedWrites(baskets, writes), pegWrites(pegs, writes)...) {
+		report.AddError(refs[rateErr.Pair], "rate", rateErr.Code, rateErr.Message)
+	}
+
+	if !s.guard.Arbitrage.Block || len(report.Errors) > 0 {
+		return nil
+	}
+
+	stored, err := s.catalogueRepo.GetAllExchangeRates(ctx)
+	if err != nil && !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return err
+	}
+	for _, rateErr := range s.guard.Arbitrage.checkWrites(stored, writes) {
+		report.AddError(refs[rateErr.Pair], "rate", rateErr.Code, rateErr.Message)
+	}
+
+	return nil
+}
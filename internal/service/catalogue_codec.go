@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type CatalogueFormat string
+
+const (
+	// CatalogueCSV has one record per row, its type column tells currencies from rates
+	CatalogueCSV  CatalogueFormat = "csv"
+	CatalogueJSON CatalogueFormat = "json"
+)
+
+// catalogueColumns are the CSV columns in the order they are exported, an imported file may
+// have any of them in any order but must have type
+var catalogueColumns = []string{"type", "code", "name", "sign", "active", "baseCurrencyCode", "targetCurrencyCode", "rate", "version"}
+
+// decodeCatalogue reads a catalogue file, values that can not be parsed are reported as errors of
+// their record, an error is returned when the file itself can not be read
+func decodeCatalogue(r io.Reader, format CatalogueFormat, report *models.ImportReport) (models.Catalogue, error) {
+	switch format {
+	case CatalogueCSV:
+		return decodeCatalogueCSV(r, report)
+	case CatalogueJSON:
+		return decodeCatalogueJSON(r)
+	default:
+		return models.Catalogue{}, fmt.Errorf("%w: unsupported format %q", ErrInvalidCatalogue, format)
+	}
+}
+
+func decodeCatalogueJSON(r io.Reader) (models.Catalogue, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var catalogue models.Catalogue
+	if err := dec.Decode(&catalogue); err != nil {
+		return models.Catalogue{}, fmt.Errorf("%w: %w", ErrInvalidCatalogue, err)
+	}
+
+	for i := range catalogue.Currencies {
+		catalogue.Currencies[i].Ref = fmt.Sprintf("currencies[%d]", i)
+	}
+	for i := range catalogue.ExchangeRates {
+		catalogue.ExchangeRates[i].Ref = fmt.Sprintf("exchangeRates[%d]", i)
+	}
+
+	return catalogue, nil
+}
+
+func decodeCatalogueCSV(r io.Reader, report *models.ImportReport) (models.Catalogue, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return models.Catalogue{}, fmt.Errorf("%w: file is empty", ErrInvalidCatalogue)
+	} else if err != nil {
+		return models.Catalogue{}, fmt.Errorf("%w: %w", ErrInvalidCatalogue, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// spreadsheets often start the file with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		known := false
+		for _, column := range catalogueColumns {
+			known = known || column == name
+		}
+		if !known {
+			return models.Catalogue{}, fmt.Errorf("%w: unknown column %q", ErrInvalidCatalogue, name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["type"]; !ok {
+		return models.Catalogue{}, fmt.Errorf("%w: type column is required", ErrInvalidCatalogue)
+	}
+
+	var catalogue models.Catalogue
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return models.Catalogue{}, fmt.Errorf("%w: %w", ErrInvalidCatalogue, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		ref := fmt.Sprintf("rows[%d]", line)
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		switch value("type") {
+		case models.RecordCurrency:
+			record := models.CurrencyRecord{Code: value("code"), Name: value("name"), Sign: value("sign"), Ref: ref}
+			if v := value("active"); v != "" {
+				active, err := strconv.ParseBool(v)
+				if err != nil {
+					report.AddError(ref, "active", "invalid_format", "active must be true or false")
+				}
+				record.Active = &active
+			}
+			catalogue.Currencies = append(catalogue.Currencies, record)
+		case models.RecordRate:
+			record := models.RateRecord{BaseCurrencyCode: value("baseCurrencyCode"), TargetCurrencyCode: value("targetCurrencyCode"), Ref: ref}
+			if v := value("rate"); v == "" {
+				report.AddError(ref, "rate", "required", "rate is required")
+			} else if record.Rate, err = strconv.ParseFloat(v, 64); err != nil {
+				report.AddError(ref, "rate", "invalid_format", "invalid rate format")
+			}
+			if v := value("version"); v != "" {
+				if record.Version, err = strconv.ParseInt(v, 10, 64); err != nil || record.Version <= 0 {
+					report.AddError(ref, "version", "invalid_format", "version must be a positive integer")
+				}
+			}
+			catalogue.ExchangeRates = append(catalogue.ExchangeRates, record)
+		default:
+			report.AddError(ref, "type", "invalid", fmt.Sprintf("type must be %s or %s", models.RecordCurrency, models.RecordRate))
+		}
+	}
+
+	return catalogue, nil
+}
+
+func encodeCatalogue(w io.Writer, catalogue models.Catalogue, format CatalogueFormat) error {
+	switch format {
+	case CatalogueCSV:
+		return encodeCatalogueCSV(w, catalogue)
+	case CatalogueJSON:
+		return json.NewEncoder(w).Encode(catalogue)
+	default:
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidCatalogue, format)
+	}
+}
+
+func encodeCatalogueCSV(w io.Writer, catalogue models.Catalogue) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(catalogueColumns); err != nil {
+		return err
+	}
+
+	for _, c := range catalogue.Currencies {
+		active := ""
+		if c.Active != nil {
+			active = strconv.FormatBool(*c.Active)
+		}
+		if err := writer.Write([]string{models.RecordCurrency, c.Code, c.Name, c.Sign, active, "", "", "", ""}); err != nil {
+			return err
+		}
+	}
+
+	for _, er := range catalogue.ExchangeRates {
+		rate := strconv.FormatFloat(er.Rate, 'g', -1, 64)
+		version := ""
+		if er.Version != 0 {
+			version = strconv.FormatInt(er.Version, 10)
+		}
+		if err := writer.Write([]string{models.RecordRate, "", "", "", "", er.BaseCurrencyCode, er.TargetCurrencyCode, rate, version}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
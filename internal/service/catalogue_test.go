@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const catalogueFile = `type,code,name,sign,baseCurrencyCode,targetCurrencyCode,rate
+currency,GBP,Pound,£,,,
+rate,,,,USD,EUR,0.91
+rate,,,,USD,GBP,0.8
+`
+
+func TestImportCatalogueDryRun(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	changes := &recorder{}
+	s := NewCatalogueService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, changes)
+
+	report, err := s.ImportCatalogue(ctx, strings.NewReader(catalogueFile), CatalogueCSV, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !report.DryRun || report.Created != 2 || report.Updated != 1 || len(report.Rows) != 3 {
+		t.Errorf("dry run report = %+v, want 2 created and 1 updated records", report)
+	}
+	if _, err := repo.GetCurrencyByCode(ctx, "GBP"); err == nil {
+		t.Error("the dry run created GBP")
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.9 {
+		t.Errorf("USDEUR = %v after the dry run, want 0.9", got.Rate)
+	}
+	if len(changes.changes) != 0 {
+		t.Errorf("the dry run notified %d rate changes", len(changes.changes))
+	}
+
+	applied, err := s.ImportCatalogue(ctx, strings.NewReader(catalogueFile), CatalogueCSV, false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if applied.Created != report.Created || applied.Updated != report.Updated {
+		t.Errorf("import report = %+v, want what the dry run reported", applied)
+	}
+	if got, ok := storedRate(t, repo, "USDGBP"); !ok || got.Rate != 0.8 {
+		t.Errorf("USDGBP = %v, %v, want 0.8", got.Rate, ok)
+	}
+	if len(changes.changes) != 2 {
+		t.Errorf("the import notified %d rate changes, want 2", len(changes.changes))
+	}
+}
+
+func TestImportCatalogueRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	changes := &recorder{}
+	s := NewCatalogueService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, changes)
+
+	// the last record names a currency that exists neither in the file nor in the database
+	file := catalogueFile + "rate,,,,USD,JPY,150\n"
+	report, err := s.ImportCatalogue(ctx, strings.NewReader(file), CatalogueCSV, false)
+	if !errors.Is(err, ErrImportRejected) {
+		t.Fatalf("err = %v, want ErrImportRejected", err)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Field != "targetCurrencyCode" || report.Errors[0].Code != "currency_not_found" {
+		t.Errorf("errors = %+v, want currency_not_found of the targetCurrencyCode", report.Errors)
+	}
+
+	if _, err := repo.GetCurrencyByCode(ctx, "GBP"); err == nil {
+		t.Error("the rejected import created GBP")
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.9 {
+		t.Errorf("USDEUR = %v after the rejected import, want 0.9", got.Rate)
+	}
+	if len(changes.changes) != 0 {
+		t.Errorf("the rejected import notified %d rate changes", len(changes.changes))
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"fmt"
+)
+
+// ExplainConversion converts like ConvertCurrency and tells how the result was computed: the
+// route, every stored rate it used and the operations that give Rate and ConvertedAmount
+func (s *convertService) ExplainConversion(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, error) {
+	const op = "internal.service.service.ExplainConversion"
+
+	res, err := s.convert(ctx, fromCode, toCode, amount)
+	if err != nil {
+		return models.CurrencyConversion{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r := res.routes[res.chosen]
+	explanation := &models.ConversionExplanation{
+		Strategy:   r.kind,
+		Pivot:      r.pivot,
+		Steps:      []models.ConversionStep{},
+		ComputedAt: res.at,
+	}
+
+	current, legAmount := fromCode, amount
+	for _, leg := range r.legs {
+		explained := models.ConversionLeg{
+			FromCurrencyCode: current,
+			Pair:             leg.BaseCurrency.Code + leg.TargetCurrency.Code,
+			ExchangeRateID:   leg.ID,
+			StoredRate:       leg.Rate,
+			UpdatedAt:        leg.UpdatedAt,
+			Version:          leg.Version,
+			Generation:       leg.Generation,
+			Stale:            s.stalePolicy.isStale(leg, res.at),
+		}
+		if leg.BaseCurrency.Code == current {
+			current = leg.TargetCurrency.Code
+			legAmount *= leg.Rate
+		} else {
+			current = leg.BaseCurrency.Code
+			legAmount /= leg.Rate
+			explained.Inverted = true
+		}
+		explained.ToCurrencyCode = current
+		explained.Amount = legAmount
+
+		explanation.Legs = append(explanation.Legs, explained)
+	}
+
+	// the last of these steps gives the rate, a direct rate needs none
+	rate := chainRate(fromCode, r.legs, func(step models.ConversionStep) {
+		explanation.Steps = append(explanation.Steps, step)
+	})
+	// the rate of a route is computed the same way, a difference would make the steps a lie
+	if rate != res.conversion.Rate {
+		return models.CurrencyConversion{}, fmt.Errorf("%s: steps give rate %v instead of %v", op, rate, res.conversion.Rate)
+	}
+	explanation.Steps = append(explanation.Steps, models.ConversionStep{
+		Description: "amount × rate",
+		Operation:   "multiply",
+		Left:        amount,
+		Right:       rate,
+		Result:      res.conversion.ConvertedAmount,
+	})
+
+	for _, skipped := range res.routes[:res.chosen] {
+		route := models.SkippedRoute{Strategy: skipped.kind, Pivot: skipped.pivot, Reason: "stale"}
+		for _, leg := range skipped.legs {
+			route.Pairs = append(route.Pairs, leg.BaseCurrency.Code+leg.TargetCurrency.Code)
+		}
+		explanation.Skipped = append(explanation.Skipped, route)
+	}
+
+	conversion := res.conversion
+	conversion.Explanation = explanation
+
+	return conversion, nil
+}
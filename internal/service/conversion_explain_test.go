@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+// replay computes a step again, it is what a client reproducing a conversion does
+func replay(t *testing.T, step models.ConversionStep) float64 {
+	t.Helper()
+	switch step.Operation {
+	case "multiply":
+		return step.Left * step.Right
+	case "divide":
+		return step.Left / step.Right
+	}
+	t.Fatalf("step %q has an unknown operation %q", step.Description, step.Operation)
+	return 0
+}
+
+func TestExplainConversion(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+	usdrub := addRate(t, repo, "USDRUB", 90)
+
+	// EURRUB went stale while the server was down
+	if _, err := repo.AddScheduledRate(ctx, models.ScheduledRate{
+		BaseCurrencyCode: "EUR", TargetCurrencyCode: "RUB", Rate: 95, EffectiveFrom: time.Now().Add(-2 * time.Hour),
+	}); err != nil {
+		t.Fatalf("AddScheduledRate: %v", err)
+	}
+	if err := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false).ApplyDueScheduledRates(ctx); err != nil {
+		t.Fatalf("ApplyDueScheduledRates: %v", err)
+	}
+
+	s := NewConvertService(repo, repo, StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateFallback}, []string{"USD"})
+
+	amount := 7.3
+	conversion, err := s.ExplainConversion(ctx, "EUR", "RUB", amount)
+	if err != nil {
+		t.Fatalf("ExplainConversion: %v", err)
+	}
+	plain, err := s.ConvertCurrency(ctx, "EUR", "RUB", amount)
+	if err != nil {
+		t.Fatalf("ConvertCurrency: %v", err)
+	}
+	if conversion.Rate != plain.Rate || conversion.ConvertedAmount != plain.ConvertedAmount {
+		t.Errorf("explained conversion = %v, %v, want what ConvertCurrency gives: %v, %v",
+			conversion.Rate, conversion.ConvertedAmount, plain.Rate, plain.ConvertedAmount)
+	}
+
+	e := conversion.Explanation
+	if e == nil || e.Strategy != models.RouteCross || e.Pivot != "USD" {
+		t.Fatalf("explanation = %+v, want a cross route through USD", e)
+	}
+
+	if len(e.Skipped) != 1 || e.Skipped[0].Strategy != models.RouteDirect || e.Skipped[0].Reason != "stale" ||
+		!slices.Equal(e.Skipped[0].Pairs, []string{"EURRUB"}) {
+		t.Errorf("skipped = %+v, want the stale EURRUB", e.Skipped)
+	}
+
+	want := []models.ConversionLeg{
+		{FromCurrencyCode: "EUR", ToCurrencyCode: "USD", Pair: "USDEUR", ExchangeRateID: usdeur.ID, StoredRate: 0.9, Inverted: true, Version: usdeur.Version},
+		{FromCurrencyCode: "USD", ToCurrencyCode: "RUB", Pair: "USDRUB", ExchangeRateID: usdrub.ID, StoredRate: 90, Version: usdrub.Version},
+	}
+	if len(e.Legs) != len(want) {
+		t.Fatalf("legs = %+v, want %d", e.Legs, len(want))
+	}
+	for i, leg := range e.Legs {
+		got := leg
+		got.Amount, got.UpdatedAt, got.Generation = 0, time.Time{}, 0
+		if got != want[i] {
+			t.Errorf("legs[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if e.Legs[1].Amount != amount/0.9*90 {
+		t.Errorf("amount after the last leg = %v, want %v", e.Legs[1].Amount, amount/0.9*90)
+	}
+
+	// the steps reproduce the result bit for bit: (USDRUB) / (USDEUR), then amount × rate
+	if len(e.Steps) != 2 {
+		t.Fatalf("steps = %+v, want 2", e.Steps)
+	}
+	for _, step := range e.Steps {
+		if got := replay(t, step); got != step.Result {
+			t.Errorf("step %q gives %v, not %v", step.Description, got, step.Result)
+		}
+	}
+	if e.Steps[0].Result != conversion.Rate || e.Steps[1].Result != conversion.ConvertedAmount {
+		t.Errorf("steps = %+v, want them to end in the rate and the converted amount", e.Steps)
+	}
+}
@@ -3,32 +3,101 @@ package service
 import (
 	"context"
 	"exchanger/internal/models"
+	"log"
 )
 
 type currencyService struct {
 	currencyRepo currencyRepository
+	// pegs and baskets derive their rates again when a currency is created, deactivated or activated
+	pegs      *pegService
+	baskets   *basketService
+	listeners []RateListener
 }
 
-func NewCurrencyService(currencyRepo currencyRepository) *currencyService {
+func NewCurrencyService(currencyRepo currencyRepository, pegs *pegService, baskets *basketService, listeners ...RateListener) *currencyService {
 	return &currencyService{
 		currencyRepo: currencyRepo,
+		pegs:         pegs,
+		baskets:      baskets,
+		listeners:    listeners,
 	}
 }
 
 type currencyRepository interface {
 	GetAllCurrencies(ctx context.Context) ([]models.Currency, error)
+	ListCurrencies(ctx context.Context, filter models.CurrencyFilter, page models.PageRequest) (models.Page[models.Currency], error)
 	GetCurrencyByCode(ctx context.Context, code string) (models.Currency, error)
 	AddCurrency(ctx context.Context, currency models.Currency) (models.Currency, error)
+	DeactivateCurrency(ctx context.Context, code string, cascade bool) (models.Currency, []models.RateChange, error)
+	ActivateCurrency(ctx context.Context, code string) (models.Currency, error)
 }
 
 func (s *currencyService) GetAllCurrencies(ctx context.Context) ([]models.Currency, error) {
 	return s.currencyRepo.GetAllCurrencies(ctx)
 }
 
+func (s *currencyService) ListCurrencies(ctx context.Context, filter models.CurrencyFilter, page models.PageRequest) (models.Page[models.Currency], error) {
+	return s.currencyRepo.ListCurrencies(ctx, filter, page)
+}
+
 func (s *currencyService) GetCurrencyByCode(ctx context.Context, code string) (models.Currency, error) {
 	return s.currencyRepo.GetCurrencyByCode(ctx, code)
 }
 
+// AddCurrency creates a currency, one with a basket is synthetic and gets its rates right away
 func (s *currencyService) AddCurrency(ctx context.Context, currency models.Currency) (models.Currency, error) {
-	return s.currencyRepo.AddCurrency(ctx, currency)
+	created, err := s.currencyRepo.AddCurrency(ctx, currency)
+	if err != nil {
+		return models.Currency{}, err
+	}
+
+	if len(created.Basket) > 0 {
+		s.updateDerivedRates(ctx)
+	}
+
+	return created, nil
+}
+
+// DeactivateCurrency hides a currency, the listeners are told about the rates a cascade deleted
+// and the pegs and baskets drop the rates that can no longer be derived
+func (s *currencyService) DeactivateCurrency(ctx context.Context, code string, cascade bool) (models.Currency, error) {
+	deactivated, changes, err := s.currencyRepo.DeactivateCurrency(ctx, code, cascade)
+	if err != nil {
+		return models.Currency{}, err
+	}
+
+	for _, change := range changes {
+		for _, listener := range s.listeners {
+			listener.RateChanged(ctx, change)
+		}
+	}
+	s.updateDerivedRates(ctx)
+
+	return deactivated, nil
+}
+
+// ActivateCurrency brings a currency back, the rates of the pegs and baskets it is part of or
+// that it is converted to are derived again
+func (s *currencyService) ActivateCurrency(ctx context.Context, code string) (models.Currency, error) {
+	activated, err := s.currencyRepo.ActivateCurrency(ctx, code)
+	if err != nil {
+		return models.Currency{}, err
+	}
+
+	s.updateDerivedRates(ctx)
+
+	return activated, nil
+}
+
+// updateDerivedRates only logs a failure, the currency was written and the rates follow with the
+// next rate change. Pegs go first, their rates are components of baskets.
+func (s *currencyService) updateDerivedRates(ctx context.Context) {
+	const op = "internal.service.service.updateDerivedRates"
+
+	if err := s.pegs.UpdatePegRates(ctx); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+	if err := s.baskets.UpdateBasketRates(ctx); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
 }
@@ -5,80 +5,252 @@ import (
 	"exchanger/internal/models"
 	"exchanger/internal/repository"
 	"fmt"
+	"math"
+	"time"
 )
 
 type convertService struct {
 	currencyRepo     currencyRepository
 	exchangeRateRepo exchangeRateRepository
+	stalePolicy      StaleRatePolicy
+	// pivots are the cross currencies of conversions without a rate of the pair, in order of preference
+	pivots []string
+	matrix rateMatrixCache
 }
 
-func NewConvertService(currencyRepo currencyRepository, exchangeRateRepo exchangeRateRepository) *convertService {
+func NewConvertService(currencyRepo currencyRepository, exchangeRateRepo exchangeRateRepository, stalePolicy StaleRatePolicy, pivots []string) *convertService {
 	return &convertService{
 		currencyRepo:     currencyRepo,
 		exchangeRateRepo: exchangeRateRepo,
+		stalePolicy:      stalePolicy,
+		pivots:           pivots,
 	}
 }
 
-const usdCode = "USD"
+// route is a way to get from one currency to another through stored rates
+type route struct {
+	rate float64
+	legs []models.ExchangeRate
+	kind models.RouteKind
+	// pivot is the currency a cross route goes through
+	pivot string
+}
 
 func (s *convertService) ConvertCurrency(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, error) {
-	const op = "internal.service.service.ConvertCurrency"
+	conversion, _, err := s.ConvertCurrencyWithLegs(ctx, fromCode, toCode, amount)
+	return conversion, err
+}
+
+// ConvertCurrencyWithLegs also returns the stored rates the conversion was computed from
+func (s *convertService) ConvertCurrencyWithLegs(ctx context.Context, fromCode, toCode string, amount float64) (models.CurrencyConversion, []models.ExchangeRate, error) {
+	const op = "internal.service.service.ConvertCurrencyWithLegs"
+
+	res, err := s.convert(ctx, fromCode, toCode, amount)
+	if err != nil {
+		return models.CurrencyConversion{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
 
+	return res.conversion, res.routes[res.chosen].legs, nil
+}
+
+// resolution is a conversion with the routes it was chosen from, the ones before the chosen
+// route were passed over because their rates are stale
+type resolution struct {
+	conversion models.CurrencyConversion
+	routes     []route
+	chosen     int
+	at         time.Time
+}
+
+func (s *convertService) convert(ctx context.Context, fromCode, toCode string, amount float64) (resolution, error) {
 	baseCurrency, err := s.currencyRepo.GetCurrencyByCode(ctx, fromCode)
 	if err != nil {
-		return models.CurrencyConversion{}, fmt.Errorf("%s: %w", op, err)
+		return resolution{}, err
 	}
 
 	targetCurrency, err := s.currencyRepo.GetCurrencyByCode(ctx, toCode)
 	if err != nil {
-		return models.CurrencyConversion{}, fmt.Errorf("%s: %w", op, err)
+		return resolution{}, err
+	}
+
+	if !baseCurrency.Active || !targetCurrency.Active {
+		return resolution{}, repository.ErrCurrencyInactive
+	}
+
+	// every rate a route could use is read by one query, so rates updated together are never
+	// seen half applied
+	pairs := [][2]string{{fromCode, toCode}, {toCode, fromCode}}
+	for _, pivot := range s.pivots {
+		pairs = append(pairs, [2]string{fromCode, pivot}, [2]string{pivot, fromCode}, [2]string{pivot, toCode}, [2]string{toCode, pivot})
+	}
+	rates, err := s.exchangeRateRepo.GetExchangeRatesByPairs(ctx, pairs)
+	if err != nil {
+		return resolution{}, err
+	}
+
+	res := resolution{routes: newQuotes(rates).routes(fromCode, toCode, s.pivots), at: time.Now()}
+
+	var stale bool
+	res.chosen, stale, err = s.stalePolicy.pickRoute(res.routes, res.at)
+	if err != nil {
+		return resolution{}, err
+	}
+
+	r := res.routes[res.chosen]
+	res.conversion = models.CurrencyConversion{
+		BaseCurrency:    baseCurrency,
+		TargetCurrency:  targetCurrency,
+		Rate:            r.rate,
+		Amount:          amount,
+		ConvertedAmount: amount * r.rate,
+		Stale:           stale,
+		Pivot:           r.pivot,
+	}
+
+	return res, nil
+}
+
+// quotes are the stored rates of a conversion by base and target code
+type quotes map[[2]string]models.ExchangeRate
+
+func newQuotes(rates []models.ExchangeRate) quotes {
+	q := make(quotes, len(rates))
+	for _, rate := range rates {
+		// rates stored before the guard could be zero or negative, a route through them is no route
+		if !(rate.Rate > 0) || math.IsInf(rate.Rate, 1) {
+			continue
+		}
+		q[[2]string{rate.BaseCurrency.Code, rate.TargetCurrency.Code}] = rate
+	}
+	return q
+}
+
+// routes are the ways from one currency to another in order of preference: the stored rate,
+// the inverse of the reverse one, then through each pivot in order
+func (q quotes) routes(fromCode, toCode string, pivots []string) []route {
+	var routes []route
+	for _, resolve := range []func(fromCode, toCode string) (route, bool){
+		q.directRoute,
+		q.reverseRoute,
+	} {
+		if r, ok := resolve(fromCode, toCode); ok {
+			routes = append(routes, r)
+		}
+	}
+	for _, pivot := range pivots {
+		routes = append(routes, q.crossRoutes(fromCode, toCode, pivot)...)
+	}
+	return routes
+}
+
+// in ExchangeRates we have currency pair AB
+func (q quotes) directRoute(fromCode, toCode string) (route, bool) {
+	exchangeRate, ok := q[[2]string{fromCode, toCode}]
+	if !ok {
+		return route{}, false
+	}
+
+	return route{
+		rate: exchangeRate.Rate,
+		legs: []models.ExchangeRate{exchangeRate},
+		kind: models.RouteDirect,
+	}, true
+}
+
+// in ExchangeRates we have currency pair BA
+func (q quotes) reverseRoute(fromCode, toCode string) (route, bool) {
+	reverseRate, ok := q[[2]string{toCode, fromCode}]
+	if !ok {
+		return route{}, false
 	}
 
-	// in ExchangeRates we have currency pair AB
-	exchangeRate, err := s.exchangeRateRepo.GetExchangeRate(ctx, fromCode, toCode)
-	if err == nil {
-		convertedAmount := amount * exchangeRate.Rate
+	return route{
+		rate: 1 / reverseRate.Rate,
+		legs: []models.ExchangeRate{reverseRate},
+		kind: models.RouteInverse,
+	}, true
+}
+
+// in ExchangeRates we have a rate between A and the pivot and one between the pivot and B, either
+// way round; every combination is a route, legs quoted from A and to B first
+func (q quotes) crossRoutes(fromCode, toCode, pivot string) []route {
+	if pivot == fromCode || pivot == toCode {
+		return nil
+	}
 
-		return models.CurrencyConversion{
-			BaseCurrency:    baseCurrency,
-			TargetCurrency:  targetCurrency,
-			Rate:            exchangeRate.Rate,
-			Amount:          amount,
-			ConvertedAmount: convertedAmount,
-		}, nil
+	var routes []route
+	for _, first := range q.legs(fromCode, pivot) {
+		for _, second := range q.legs(pivot, toCode) {
+			legs := []models.ExchangeRate{first, second}
+			routes = append(routes, route{
+				rate:  chainRate(fromCode, legs, nil),
+				legs:  legs,
+				kind:  models.RouteCross,
+				pivot: pivot,
+			})
+		}
 	}
+	return routes
+}
 
-	// in ExchangeRates we have currency pair BA
-	reverseRate, err := s.exchangeRateRepo.GetExchangeRate(ctx, toCode, fromCode)
-	if err == nil {
-		rate := 1 / reverseRate.Rate
-		convertedAmount := amount * rate
+// legs are the stored rates that convert one currency to another, the rate of the pair first
+// and then the one of the reverse pair
+func (q quotes) legs(fromCode, toCode string) []models.ExchangeRate {
+	var legs []models.ExchangeRate
+	for _, pair := range [][2]string{{fromCode, toCode}, {toCode, fromCode}} {
+		if rate, ok := q[pair]; ok {
+			legs = append(legs, rate)
+		}
+	}
+	return legs
+}
 
-		return models.CurrencyConversion{
-			BaseCurrency:    baseCurrency,
-			TargetCurrency:  targetCurrency,
-			Rate:            rate,
-			Amount:          amount,
-			ConvertedAmount: convertedAmount,
-		}, nil
+// chainRate is the rate along legs starting at fromCode, legs quoted the other way round divide
+// it so the result is rounded once. It gives the rate of every route bit for bit, record is
+// passed each operation that can round, nil skips that.
+func chainRate(fromCode string, legs []models.ExchangeRate, record func(models.ConversionStep)) float64 {
+	// a factor is a product of rates and the names of the pairs in it, 1 while it has none
+	type factor struct {
+		value float64
+		name  string
 	}
+	multiply := func(f factor, leg models.ExchangeRate) factor {
+		pair := leg.BaseCurrency.Code + leg.TargetCurrency.Code
+		if f.name == "" {
+			return factor{value: leg.Rate, name: pair}
+		}
 
-	// in ExchangeRates we have currency pairs USD-A and USD-B
-	usdToBase, errBase := s.exchangeRateRepo.GetExchangeRate(ctx, usdCode, fromCode)
-	usdToTarget, errTarget := s.exchangeRateRepo.GetExchangeRate(ctx, usdCode, toCode)
+		product := factor{value: f.value * leg.Rate, name: f.name + " × " + pair}
+		if record != nil {
+			record(models.ConversionStep{Description: product.name, Operation: "multiply", Left: f.value, Right: leg.Rate, Result: product.value})
+		}
+		return product
+	}
 
-	if errBase == nil && errTarget == nil {
-		rate := usdToTarget.Rate / usdToBase.Rate
-		convertedAmount := amount * rate
+	numerator, denominator := factor{value: 1}, factor{value: 1}
+	code := fromCode
+	for _, leg := range legs {
+		if leg.BaseCurrency.Code == code {
+			numerator = multiply(numerator, leg)
+			code = leg.TargetCurrency.Code
+		} else {
+			denominator = multiply(denominator, leg)
+			code = leg.BaseCurrency.Code
+		}
+	}
 
-		return models.CurrencyConversion{
-			BaseCurrency:    baseCurrency,
-			TargetCurrency:  targetCurrency,
-			Rate:            rate,
-			Amount:          amount,
-			ConvertedAmount: convertedAmount,
-		}, nil
+	// x / 1 is x exactly
+	if denominator.name == "" {
+		return numerator.value
+	}
+	if numerator.name == "" {
+		numerator.name = "1"
 	}
 
-	return models.CurrencyConversion{}, fmt.Errorf("%s: %w", op, repository.ErrExchangeRateNotFound)
+	rate := numerator.value / denominator.value
+	if record != nil {
+		record(models.ConversionStep{Description: "(" + numerator.name + ") / (" + denominator.name + ")", Operation: "divide",
+			Left: numerator.value, Right: denominator.value, Result: rate})
+	}
+	return rate
 }
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"math"
+	"testing"
+)
+
+func TestQuotesRoutesOrder(t *testing.T) {
+	rate := func(pair string, value float64) models.ExchangeRate {
+		return models.ExchangeRate{BaseCurrency: models.Currency{Code: pair[:3]}, TargetCurrency: models.Currency{Code: pair[3:]}, Rate: value}
+	}
+	q := newQuotes([]models.ExchangeRate{
+		rate("EURRUB", 100), rate("RUBEUR", 0.01),
+		rate("EURUSD", 1.1), rate("USDRUB", 90),
+		// the GBP legs are quoted the other way round
+		rate("GBPEUR", 1.2), rate("RUBGBP", 0.0095),
+		// no route goes through a rate that is not positive
+		rate("EURJPY", 0), rate("JPYRUB", 0.6),
+	})
+
+	routes := q.routes("EUR", "RUB", []string{"GBP", "JPY", "USD"})
+
+	want := []struct {
+		kind  models.RouteKind
+		pivot string
+	}{
+		{models.RouteDirect, ""},
+		{models.RouteInverse, ""},
+		{models.RouteCross, "GBP"},
+		{models.RouteCross, "USD"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("routes = %+v, want %d", routes, len(want))
+	}
+	for i, w := range want {
+		if routes[i].kind != w.kind || routes[i].pivot != w.pivot {
+			t.Errorf("routes[%d] = %s through %q, want %s through %q", i, routes[i].kind, routes[i].pivot, w.kind, w.pivot)
+		}
+	}
+	if got, want := routes[2].rate, 1/1.2/0.0095; math.Abs(got-want) > 1e-9 {
+		t.Errorf("rate through GBP = %v, want %v", got, want)
+	}
+}
+
+func TestConvertCurrencyPivots(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB", "GBP")
+	addRate(t, repo, "EURUSD", 1.1)
+	addRate(t, repo, "USDRUB", 90)
+	addRate(t, repo, "EURGBP", 0.85)
+	addRate(t, repo, "GBPRUB", 105)
+
+	tests := []struct {
+		pivots []string
+		pivot  string
+		rate   float64
+	}{
+		{[]string{"USD", "GBP"}, "USD", 1.1 * 90},
+		{[]string{"GBP", "USD"}, "GBP", 0.85 * 105},
+		// a pivot without legs is passed over
+		{[]string{"RUB", "GBP"}, "GBP", 0.85 * 105},
+	}
+	for _, tt := range tests {
+		s := NewConvertService(repo, repo, StaleRatePolicy{}, tt.pivots)
+		conversion, err := s.ConvertCurrency(ctx, "EUR", "RUB", 10)
+		if err != nil {
+			t.Fatalf("pivots %v: %v", tt.pivots, err)
+		}
+		if conversion.Pivot != tt.pivot || math.Abs(conversion.Rate-tt.rate) > 1e-9 || conversion.ConvertedAmount != 10*conversion.Rate {
+			t.Errorf("pivots %v: conversion = %+v, want rate %v through %s", tt.pivots, conversion, tt.rate, tt.pivot)
+		}
+	}
+
+	// the rate of the pair comes before every pivot
+	addRate(t, repo, "RUBEUR", 0.01)
+	s := NewConvertService(repo, repo, StaleRatePolicy{}, []string{"USD", "GBP"})
+	conversion, err := s.ConvertCurrency(ctx, "EUR", "RUB", 10)
+	if err != nil {
+		t.Fatalf("ConvertCurrency: %v", err)
+	}
+	if conversion.Pivot != "" || conversion.Rate != 1/0.01 {
+		t.Errorf("conversion = %+v, want the inverse rate without a pivot", conversion)
+	}
+}
@@ -2,38 +2,282 @@ package service
 
 import (
 	"context"
+	"errors"
 	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"time"
 )
 
+// ErrVersionRequired is returned for a rate update that does not say which version of the rate
+// it changes while the service requires it
+var ErrVersionRequired = errors.New("version of the exchange rate is required")
+
+// RateListener is notified after a rate has been created, updated or deleted
+type RateListener interface {
+	RateChanged(ctx context.Context, change models.RateChange)
+}
+
 type exchangeRateService struct {
 	exchangeRateRepo exchangeRateRepository
+	approval         ApprovalPolicy
+	guard            RateGuardPolicy
+	listeners        []RateListener
+	// requireVersion rejects updates of existing rates that do not say which version they change
+	requireVersion bool
+	// scheduled wakes RunScheduler when a change is scheduled
+	scheduled chan struct{}
 }
 
-func NewExchangeRateService(exchangeRateRepo exchangeRateRepository) *exchangeRateService {
+func NewExchangeRateService(exchangeRateRepo exchangeRateRepository, approval ApprovalPolicy, guard RateGuardPolicy, requireVersion bool, listeners ...RateListener) *exchangeRateService {
 	return &exchangeRateService{
 		exchangeRateRepo: exchangeRateRepo,
+		approval:         approval,
+		guard:            guard,
+		listeners:        listeners,
+		requireVersion:   requireVersion,
+		scheduled:        make(chan struct{}, 1),
 	}
 }
 
 type exchangeRateRepository interface {
 	GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	ListExchangeRates(ctx context.Context, filter models.ExchangeRateFilter, page models.PageRequest) (models.Page[models.ExchangeRate], error)
 	GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error)
 	AddExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error)
-	UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error)
+	UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, version int64) (models.ExchangeRate, error)
+	UpdateExchangeRates(ctx context.Context, updates []models.RateUpdate) ([]models.RateChange, error)
+	DeleteExchangeRate(ctx context.Context, baseCode, targetCode string) (models.RateChange, error)
+	GetExchangeRatesByPairs(ctx context.Context, pairs [][2]string) ([]models.ExchangeRate, error)
+	GetExchangeRatesByCurrencyCodes(ctx context.Context, codes []string) ([]models.ExchangeRate, error)
+	GetExchangeRateHistory(ctx context.Context, exchangeRateIDs []int, since time.Time, limit int) ([]models.RateHistoryPoint, error)
+	AddRateProposal(ctx context.Context, proposal models.RateProposal) (models.RateProposal, error)
+	GetRateProposal(ctx context.Context, id int) (models.RateProposal, error)
+	ListRateProposals(ctx context.Context, filter models.RateProposalFilter, page models.PageRequest) (models.Page[models.RateProposal], error)
+	ApproveRateProposal(ctx context.Context, id int, decidedBy string) (models.RateProposal, models.RateChange, error)
+	RejectRateProposal(ctx context.Context, id int, decidedBy, reason string) (models.RateProposal, error)
+	AddScheduledRate(ctx context.Context, scheduled models.ScheduledRate) (models.ScheduledRate, error)
+	GetScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error)
+	ListScheduledRates(ctx context.Context, filter models.ScheduledRateFilter, page models.PageRequest) (models.Page[models.ScheduledRate], error)
+	CancelScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error)
+	NextScheduledRateAt(ctx context.Context) (time.Time, error)
+	DueScheduledRates(ctx context.Context, now time.Time) ([]models.ScheduledRate, error)
+	ApplyScheduledRate(ctx context.Context, id int, now time.Time) (models.RateChange, error)
+	FailScheduledRate(ctx context.Context, id int, reason string, now time.Time) (models.ScheduledRate, error)
+	LastAuditID(ctx context.Context) (int64, error)
+	GetRateCandles(ctx context.Context, baseCode, targetCode string, from, to time.Time, interval time.Duration) ([]models.RateCandle, error)
+	GetBasketCurrencies(ctx context.Context) ([]models.Currency, error)
+	GetAllPegs(ctx context.Context) ([]models.Peg, error)
 }
 
 func (s *exchangeRateService) GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
 	return s.exchangeRateRepo.GetAllExchangeRates(ctx)
 }
 
+func (s *exchangeRateService) ListExchangeRates(ctx context.Context, filter models.ExchangeRateFilter, page models.PageRequest) (models.Page[models.ExchangeRate], error) {
+	return s.exchangeRateRepo.ListExchangeRates(ctx, filter, page)
+}
+
 func (s *exchangeRateService) GetExchangeRate(ctx context.Context, baseCode, targetCode string) (models.ExchangeRate, error) {
 	return s.exchangeRateRepo.GetExchangeRate(ctx, baseCode, targetCode)
 }
 
+func (s *exchangeRateService) GetExchangeRatesByCurrencyCodes(ctx context.Context, codes []string) ([]models.ExchangeRate, error) {
+	return s.exchangeRateRepo.GetExchangeRatesByCurrencyCodes(ctx, codes)
+}
+
+func (s *exchangeRateService) GetExchangeRateHistory(ctx context.Context, exchangeRateIDs []int, since time.Time, limit int) ([]models.RateHistoryPoint, error) {
+	return s.exchangeRateRepo.GetExchangeRateHistory(ctx, exchangeRateIDs, since, limit)
+}
+
 func (s *exchangeRateService) AddExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error) {
-	return s.exchangeRateRepo.AddExchangeRate(ctx, baseCode, targetCode, rate)
+	if err := s.guard.check(baseCode, targetCode, rate, nil); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if err := s.checkDerived(ctx, models.RateUpdate{BaseCurrencyCode: baseCode, TargetCurrencyCode: targetCode, Rate: rate}); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if err := s.checkArbitrage(ctx, models.RateUpdate{BaseCurrencyCode: baseCode, TargetCurrencyCode: targetCode, Rate: rate}); err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	created, err := s.exchangeRateRepo.AddExchangeRate(ctx, baseCode, targetCode, rate)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	s.notify(ctx, models.RateChange{Current: created})
+	created.Warnings = s.inverseWarnings(ctx, created)
+
+	return created, nil
+}
+
+// UpdateExchangeRate sets the rate of a pair, unless version is 0 only when the pair is still at it.
+// Version 0 fails with ErrVersionRequired when the service requires it. A change the approval
+// policy holds back is stored as a proposal and returned as a *PendingApprovalError instead.
+func (s *exchangeRateService) UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, version int64) (models.ExchangeRate, error) {
+	if version == 0 && s.requireVersion {
+		return models.ExchangeRate{}, ErrVersionRequired
+	}
+
+	var previous *models.ExchangeRate
+	if current, err := s.exchangeRateRepo.GetExchangeRate(ctx, baseCode, targetCode); err == nil {
+		previous = &current
+	}
+
+	if err := s.guard.check(baseCode, targetCode, rate, previous); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if err := s.checkDerived(ctx, models.RateUpdate{BaseCurrencyCode: baseCode, TargetCurrencyCode: targetCode, Rate: rate}); err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if err := s.checkArbitrage(ctx, models.RateUpdate{BaseCurrencyCode: baseCode, TargetCurrencyCode: targetCode, Rate: rate}); err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	if previous != nil && s.approval.requiresApproval(*previous, rate) {
+		if version != 0 && version != previous.Version {
+			return models.ExchangeRate{}, repository.ErrVersionMismatch
+		}
+
+		now := time.Now()
+		proposal, err := s.exchangeRateRepo.AddRateProposal(ctx, models.RateProposal{
+			BaseCurrencyCode:   baseCode,
+			TargetCurrencyCode: targetCode,
+			Rate:               rate,
+			PreviousRate:       previous.Rate,
+			RateVersion:        previous.Version,
+			ProposedBy:         models.ActorFromContext(ctx).Name,
+			ProposedAt:         now,
+			ExpiresAt:          now.Add(s.approval.TTL),
+		})
+		if err != nil {
+			return models.ExchangeRate{}, err
+		}
+
+		return models.ExchangeRate{}, &PendingApprovalError{Proposal: proposal}
+	}
+
+	updated, err := s.exchangeRateRepo.UpdateExchangeRate(ctx, baseCode, targetCode, rate, version)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	s.notify(ctx, models.RateChange{Previous: previous, Current: updated})
+	updated.Warnings = s.inverseWarnings(ctx, updated)
+
+	return updated, nil
+}
+
+// UpdateExchangeRates writes the rates of existing pairs as one generation, all of them or none.
+// Rates the guard rejects, including the ones that make an arbitrage loop or belong to a
+// synthetic currency, and changes that need approval can not be part of a generation, when there
+// are any nothing is written and the error names their pairs. So do updates without a version
+// when the service requires it and ones whose pair is no longer at their version.
+func (s *exchangeRateService) UpdateExchangeRates(ctx context.Context, updates []models.RateUpdate) (models.RateGeneration, error) {
+	if err := s.checkUpdates(ctx, updates); err != nil {
+		return models.RateGeneration{}, err
+	}
+
+	changes, err := s.exchangeRateRepo.UpdateExchangeRates(ctx, updates)
+	if err != nil {
+		return models.RateGeneration{}, err
+	}
+
+	generation := models.RateGeneration{ExchangeRates: make([]models.ExchangeRate, len(changes))}
+	for i, change := range changes {
+		generation.ExchangeRates[i] = change.Current
+		generation.ID = change.Current.Generation
+		generation.CreatedAt = change.Current.UpdatedAt
+	}
+
+	for _, change := range changes {
+		s.notify(ctx, change)
+	}
+	for i := range generation.ExchangeRates {
+		generation.ExchangeRates[i].Warnings = s.inverseWarnings(ctx, generation.ExchangeRates[i])
+	}
+
+	return generation, nil
+}
+
+func (s *exchangeRateService) checkUpdates(ctx context.Context, updates []models.RateUpdate) error {
+	if s.requireVersion {
+		var unversioned []string
+		for _, u := range updates {
+			if u.Version == 0 {
+				unversioned = append(unversioned, u.BaseCurrencyCode+u.TargetCurrencyCode)
+			}
+		}
+		if len(unversioned) > 0 {
+			return &repository.PairsError{Err: ErrVersionRequired, Pairs: unversioned}
+		}
+	}
+
+	pairs := make([][2]string, len(updates))
+	for i, u := range updates {
+		pairs[i] = [2]string{u.BaseCurrencyCode, u.TargetCurrencyCode}
+	}
+
+	current, err := s.exchangeRateRepo.GetExchangeRatesByPairs(ctx, pairs)
+	if err != nil {
+		return err
+	}
+	rates := make(map[string]models.ExchangeRate, len(current))
+	for _, rate := range current {
+		rates[rate.BaseCurrency.Code+rate.TargetCurrency.Code] = rate
+	}
+
+	var rejected RateErrors
+	var held []string
+	for _, u := range updates {
+		pair := u.BaseCurrencyCode + u.TargetCurrencyCode
+		var previous *models.ExchangeRate
+		if rate, ok := rates[pair]; ok {
+			previous = &rate
+		}
+
+		var rateErr *RateError
+		if errors.As(s.guard.check(u.BaseCurrencyCode, u.TargetCurrencyCode, u.Rate, previous), &rateErr) {
+			rejected = append(rejected, rateErr)
+		} else if previous != nil && s.approval.requiresApproval(*previous, u.Rate) {
+			held = append(held, pair)
+		}
+	}
+	if len(rejected) > 0 {
+		return rejected
+	}
+	if err := s.checkDerived(ctx, updates...); err != nil {
+		return err
+	}
+	if err := s.checkArbitrage(ctx, updates...); err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		return &repository.PairsError{Err: ErrApprovalRequired, Pairs: held}
+	}
+
+	return nil
 }
 
-func (s *exchangeRateService) UpdateExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64) (models.ExchangeRate, error) {
-	return s.exchangeRateRepo.UpdateExchangeRate(ctx, baseCode, targetCode, rate)
+// DeleteExchangeRate deletes the rate of a pair, the rates of synthetic and pegged currencies are
+// derived and can not be deleted
+func (s *exchangeRateService) DeleteExchangeRate(ctx context.Context, baseCode, targetCode string) error {
+	if err := s.checkDerivedDelete(ctx, baseCode, targetCode); err != nil {
+		return err
+	}
+
+	change, err := s.exchangeRateRepo.DeleteExchangeRate(ctx, baseCode, targetCode)
+	if err != nil {
+		return err
+	}
+
+	s.notify(ctx, change)
+
+	return nil
+}
+
+func (s *exchangeRateService) notify(ctx context.Context, change models.RateChange) {
+	for _, listener := range s.listeners {
+		listener.RateChanged(ctx, change)
+	}
 }
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUpdateExchangeRateVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	er := addRate(t, repo, "USDEUR", 0.9)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, true)
+
+	if _, err := s.UpdateExchangeRate(ctx, "USD", "EUR", 0.91, 0); !errors.Is(err, ErrVersionRequired) {
+		t.Fatalf("update without a version: err = %v, want ErrVersionRequired", err)
+	}
+	updated, err := s.UpdateExchangeRate(ctx, "USD", "EUR", 0.91, er.Version)
+	if err != nil {
+		t.Fatalf("update at the current version: %v", err)
+	}
+	if _, err := s.UpdateExchangeRate(ctx, "USD", "EUR", 0.92, er.Version); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("update at an old version: err = %v, want ErrVersionMismatch", err)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.91 || got.Version != updated.Version {
+		t.Errorf("stored rate = %v at version %d, want 0.91 at %d", got.Rate, got.Version, updated.Version)
+	}
+}
+
+func TestUpdateExchangeRatesVersions(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+	usdrub := addRate(t, repo, "USDRUB", 90)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, true)
+
+	var pairsErr *repository.PairsError
+	_, err := s.UpdateExchangeRates(ctx, []models.RateUpdate{
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: 0.91, Version: usdeur.Version},
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "RUB", Rate: 91},
+	})
+	if !errors.Is(err, ErrVersionRequired) || !errors.As(err, &pairsErr) || !slices.Equal(pairsErr.Pairs, []string{"USDRUB"}) {
+		t.Fatalf("update with a missing version: err = %v, want ErrVersionRequired for USDRUB", err)
+	}
+
+	_, err = s.UpdateExchangeRates(ctx, []models.RateUpdate{
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: 0.91, Version: usdeur.Version},
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "RUB", Rate: 91, Version: usdrub.Version + 1},
+	})
+	if !errors.Is(err, repository.ErrVersionMismatch) || !errors.As(err, &pairsErr) || !slices.Equal(pairsErr.Pairs, []string{"USDRUB"}) {
+		t.Fatalf("update with an old version: err = %v, want ErrVersionMismatch for USDRUB", err)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.9 {
+		t.Errorf("USDEUR = %v after a rejected generation, want 0.9", got.Rate)
+	}
+
+	if _, err := s.UpdateExchangeRates(ctx, []models.RateUpdate{
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: 0.91, Version: usdeur.Version},
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "RUB", Rate: 91, Version: usdrub.Version},
+	}); err != nil {
+		t.Fatalf("update at the current versions: %v", err)
+	}
+}
+
+func TestImportCatalogueVersions(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+
+	s := NewCatalogueService(repo, ApprovalPolicy{}, RateGuardPolicy{}, true)
+
+	tests := []struct {
+		name  string
+		file  string
+		codes []string
+	}{
+		{"changed rate without a version", "type,baseCurrencyCode,targetCurrencyCode,rate\nrate,USD,EUR,0.91\n", []string{"required"}},
+		{"changed rate at an old version", "type,baseCurrencyCode,targetCurrencyCode,rate,version\nrate,USD,EUR,0.91,99\n", []string{"version_mismatch"}},
+		{"invalid version", "type,baseCurrencyCode,targetCurrencyCode,rate,version\nrate,USD,EUR,0.91,x\n", []string{"invalid_format"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := s.ImportCatalogue(ctx, strings.NewReader(tt.file), CatalogueCSV, false)
+			if !errors.Is(err, ErrImportRejected) {
+				t.Fatalf("err = %v, want ErrImportRejected", err)
+			}
+			var codes []string
+			for _, e := range report.Errors {
+				if e.Field == "version" {
+					codes = append(codes, e.Code)
+				}
+			}
+			if !slices.Equal(codes, tt.codes) {
+				t.Errorf("version errors = %v, want %v", codes, tt.codes)
+			}
+		})
+	}
+
+	// unchanged and new rates do not need a version
+	file := "type,baseCurrencyCode,targetCurrencyCode,rate,version\nrate,USD,EUR,0.9,\nrate,USD,RUB,90,\n"
+	if _, err := s.ImportCatalogue(ctx, strings.NewReader(file), CatalogueCSV, false); err != nil {
+		t.Fatalf("import of unchanged and new rates: %v", err)
+	}
+
+	file = "type,baseCurrencyCode,targetCurrencyCode,rate,version\nrate,USD,EUR,0.91," + strconv.FormatInt(usdeur.Version, 10) + "\n"
+	if _, err := s.ImportCatalogue(ctx, strings.NewReader(file), CatalogueCSV, false); err != nil {
+		t.Fatalf("import at the current version: %v", err)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.91 {
+		t.Errorf("USDEUR = %v, want 0.91", got.Rate)
+	}
+}
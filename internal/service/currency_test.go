@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDeleteExchangeRateNotifiesListeners(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	rec := &recorder{}
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, rec)
+
+	if err := s.DeleteExchangeRate(ctx, "USD", "EUR"); err != nil {
+		t.Fatalf("DeleteExchangeRate: %v", err)
+	}
+
+	if got := rec.deleted(); !slices.Equal(got, []string{"USDEUR"}) {
+		t.Fatalf("deleted pairs = %v, want [USDEUR]", got)
+	}
+	if change := rec.changes[0]; change.Previous == nil || change.Previous.Rate != 0.9 || change.Current.Version != change.Previous.Version+1 {
+		t.Errorf("change = %+v, want the last rate and the bumped version", change)
+	}
+}
+
+func TestDeactivateCurrencyCascadeNotifiesListeners(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "RUBEUR", 0.01)
+	addRate(t, repo, "USDRUB", 90)
+
+	rec := &recorder{}
+	s := NewCurrencyService(repo, NewPegService(repo, StaleRatePolicy{}, nil), NewBasketService(repo, StaleRatePolicy{}, nil), rec)
+
+	if _, err := s.DeactivateCurrency(ctx, "EUR", false); err == nil {
+		t.Fatal("DeactivateCurrency without cascade of a currency in use succeeded")
+	}
+	if len(rec.changes) != 0 {
+		t.Fatalf("a failed deactivation notified %v", rec.changes)
+	}
+
+	if _, err := s.DeactivateCurrency(ctx, "EUR", true); err != nil {
+		t.Fatalf("DeactivateCurrency: %v", err)
+	}
+
+	got := rec.deleted()
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"RUBEUR", "USDEUR"}) {
+		t.Errorf("deleted pairs = %v, want [RUBEUR USDEUR]", got)
+	}
+	if _, ok := storedRate(t, repo, "USDRUB"); !ok {
+		t.Error("USDRUB was deleted with EUR")
+	}
+}
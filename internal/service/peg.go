@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// pegService keeps the rates of pegged currencies: the rate of the anchor to the pegged currency
+// stays in its band and the pegged currency is quoted in everything the anchor is quoted in
+type pegService struct {
+	pegRepo     pegRepository
+	stalePolicy StaleRatePolicy
+	// pivots are the cross currencies of the anchor without a rate to a currency
+	pivots    []string
+	listeners []RateListener
+
+	mu sync.Mutex
+	// generation is the latest generation of the rates the derived ones were computed from
+	generation int64
+}
+
+func NewPegService(pegRepo pegRepository, stalePolicy StaleRatePolicy, pivots []string, listeners ...RateListener) *pegService {
+	return &pegService{
+		pegRepo:     pegRepo,
+		stalePolicy: stalePolicy,
+		pivots:      pivots,
+		listeners:   listeners,
+	}
+}
+
+type pegRepository interface {
+	GetAllCurrencies(ctx context.Context) ([]models.Currency, error)
+	GetAllExchangeRates(ctx context.Context) ([]models.ExchangeRate, error)
+	GetBasketCurrencies(ctx context.Context) ([]models.Currency, error)
+	GetAllPegs(ctx context.Context) ([]models.Peg, error)
+	GetPeg(ctx context.Context, code string) (models.Peg, error)
+	AddPeg(ctx context.Context, peg models.Peg) (models.Peg, error)
+	DeletePeg(ctx context.Context, code string) error
+	SetDerivedRates(ctx context.Context, source string, rates []models.RateUpdate) ([]models.RateChange, error)
+}
+
+func (s *pegService) GetPegs(ctx context.Context) ([]models.Peg, error) {
+	return s.pegRepo.GetAllPegs(ctx)
+}
+
+func (s *pegService) GetPeg(ctx context.Context, code string) (models.Peg, error) {
+	return s.pegRepo.GetPeg(ctx, code)
+}
+
+// AddPeg pegs a currency and derives its rates right away
+func (s *pegService) AddPeg(ctx context.Context, peg models.Peg) (models.Peg, error) {
+	const op = "internal.service.service.AddPeg"
+
+	peg.CreatedBy = models.ActorFromContext(ctx).Name
+	peg.CreatedAt = time.Now()
+
+	peg, err := s.pegRepo.AddPeg(ctx, peg)
+	if err != nil {
+		return models.Peg{}, err
+	}
+
+	if err := s.UpdatePegRates(ctx); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+
+	return peg, nil
+}
+
+// DeletePeg drops a peg and deletes the rates it derived, a rate of the anchor to the currency
+// written within the band stays
+func (s *pegService) DeletePeg(ctx context.Context, code string) error {
+	const op = "internal.service.service.DeletePeg"
+
+	if err := s.pegRepo.DeletePeg(ctx, code); err != nil {
+		return err
+	}
+
+	if err := s.UpdatePegRates(ctx); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+
+	return nil
+}
+
+// RateChanged derives the rates of the pegged currencies again after a rate changed, the changes
+// of a generation are handled once and every delete on its own
+func (s *pegService) RateChanged(ctx context.Context, change models.RateChange) {
+	const op = "internal.service.service.RateChanged"
+
+	if change.Current.Source == models.RateSourcePeg || change.Current.Source == models.RateSourceBasket {
+		return
+	}
+
+	generation := change.Current.Generation
+	if change.Deleted {
+		generation = 0
+	}
+	if err := s.updatePegRates(ctx, generation); err != nil {
+		log.Printf("%s: %v", op, err)
+	}
+}
+
+// UpdatePegRates derives the rates of every pegged currency, the ones that can not be derived
+// because the rate of the anchor is missing or stale are deleted until they can be derived again
+func (s *pegService) UpdatePegRates(ctx context.Context) error {
+	return s.updatePegRates(ctx, 0)
+}
+
+// updatePegRates does nothing when the rates of generation were already seen, zero forces it
+func (s *pegService) updatePegRates(ctx context.Context, generation int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if generation != 0 && generation <= s.generation {
+		return nil
+	}
+
+	pegs, err := s.pegRepo.GetAllPegs(ctx)
+	if err != nil && !errors.Is(err, repository.ErrPegNotFound) {
+		return err
+	}
+
+	currencies, err := s.pegRepo.GetAllCurrencies(ctx)
+	if err != nil && !errors.Is(err, repository.ErrCurrencyNotFound) {
+		return err
+	}
+
+	baskets, err := s.pegRepo.GetBasketCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	rates, err := s.pegRepo.GetAllExchangeRates(ctx)
+	if err != nil && !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return err
+	}
+
+	synthetic := make(map[string]bool, len(baskets))
+	for _, basket := range baskets {
+		synthetic[basket.Code] = true
+	}
+	active := make(map[string]bool, len(currencies))
+	for _, currency := range currencies {
+		active[currency.Code] = true
+	}
+
+	var quoted []models.ExchangeRate
+	for _, rate := range rates {
+		if synthetic[rate.BaseCurrency.Code] || synthetic[rate.TargetCurrency.Code] {
+			continue
+		}
+		quoted = append(quoted, rate)
+		if rate.Source != models.RateSourcePeg {
+			s.generation = max(s.generation, rate.Generation)
+		}
+	}
+	s.generation = max(s.generation, generation)
+
+	q := newQuotes(quoted)
+	now := time.Now()
+
+	var updates []models.RateUpdate
+	for _, peg := range pegs {
+		if !active[peg.CurrencyCode] || !active[peg.AnchorCurrencyCode] {
+			continue
+		}
+
+		// a rate set within the band holds, anything else falls back to the central rate, which
+		// is as fresh as the peg itself and written again every time
+		current, asOf := peg.CentralRate, time.Time{}
+		if rate, ok := q[[2]string{peg.AnchorCurrencyCode, peg.CurrencyCode}]; ok && rate.Source != models.RateSourcePeg && inBand(peg, rate.Rate) {
+			current, asOf = rate.Rate, rate.UpdatedAt
+		} else {
+			updates = append(updates, models.RateUpdate{BaseCurrencyCode: peg.AnchorCurrencyCode, TargetCurrencyCode: peg.CurrencyCode, Rate: current})
+		}
+		updates = append(updates, models.RateUpdate{BaseCurrencyCode: peg.CurrencyCode, TargetCurrencyCode: peg.AnchorCurrencyCode, Rate: 1 / current, UpdatedAt: asOf})
+
+		for _, currency := range currencies {
+			if synthetic[currency.Code] || currency.Code == peg.CurrencyCode || currency.Code == peg.AnchorCurrencyCode {
+				continue
+			}
+
+			// the rates of the pegged currency are the ones being derived, it is no pivot of its own
+			var routes []route
+			for _, r := range q.routes(peg.AnchorCurrencyCode, currency.Code, s.pivots) {
+				if r.pivot != peg.CurrencyCode {
+					routes = append(routes, r)
+				}
+			}
+			chosen, _, err := s.stalePolicy.pickRoute(routes, now)
+			if err != nil {
+				continue
+			}
+			updatedAt := oldest(routes[chosen].legs)
+			if !asOf.IsZero() && asOf.Before(updatedAt) {
+				updatedAt = asOf
+			}
+			updates = append(updates, models.RateUpdate{BaseCurrencyCode: peg.CurrencyCode, TargetCurrencyCode: currency.Code,
+				Rate: routes[chosen].rate / current, UpdatedAt: updatedAt})
+		}
+	}
+
+	changes, err := s.pegRepo.SetDerivedRates(ctx, models.RateSourcePeg, updates)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		for _, listener := range s.listeners {
+			listener.RateChanged(ctx, change)
+		}
+	}
+
+	return nil
+}
+
+// oldest is the update time of the oldest of rates, a derived rate is as stale as it
+func oldest(rates []models.ExchangeRate) time.Time {
+	var at time.Time
+	for _, rate := range rates {
+		if at.IsZero() || rate.UpdatedAt.Before(at) {
+			at = rate.UpdatedAt
+		}
+	}
+	return at
+}
+
+func inBand(peg models.Peg, rate float64) bool {
+	return rate >= peg.CentralRate*(1-peg.Band) && rate <= peg.CentralRate*(1+peg.Band)
+}
+
+// pegWrites are the writes that break a peg: a rate of the anchor to the pegged currency outside
+// the band or any other rate of the pegged currency, which the peg derives
+func pegWrites(pegs []models.Peg, writes []models.RateUpdate) RateErrors {
+	pegged := make(map[string]models.Peg, len(pegs))
+	for _, peg := range pegs {
+		pegged[peg.CurrencyCode] = peg
+	}
+
+	var rejected RateErrors
+	for _, w := range writes {
+		pair := w.BaseCurrencyCode + w.TargetCurrencyCode
+		if peg, ok := pegged[w.TargetCurrencyCode]; ok && peg.AnchorCurrencyCode == w.BaseCurrencyCode {
+			if !inBand(peg, w.Rate) {
+				rejected = append(rejected, &RateError{Pair: pair, Code: "peg_band",
+					Message: fmt.Sprintf("%s is pegged at %g with a band of %g, the rate must be within %g and %g", w.TargetCurrencyCode,
+						peg.CentralRate, peg.Band, peg.CentralRate*(1-peg.Band), peg.CentralRate*(1+peg.Band))})
+			}
+			continue
+		}
+
+		for _, code := range []string{w.BaseCurrencyCode, w.TargetCurrencyCode} {
+			if peg, ok := pegged[code]; ok {
+				rejected = append(rejected, &RateError{Pair: pair, Code: "derived",
+					Message: fmt.Sprintf("rates of %s are derived from its peg, set the rate of %s%s instead", code, peg.AnchorCurrencyCode, code)})
+				break
+			}
+		}
+	}
+	return rejected
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"exchanger/internal/models"
+)
+
+func TestPegRatesFollowTheirSourcesFreshness(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "HKD")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	policy := StaleRatePolicy{MaxAge: 300 * time.Millisecond, Mode: StaleRateFail}
+	pegs := NewPegService(repo, policy, nil)
+	rates := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, pegs)
+	convert := NewConvertService(repo, repo, policy, nil)
+
+	if _, err := pegs.AddPeg(ctx, models.Peg{CurrencyCode: "HKD", AnchorCurrencyCode: "USD", CentralRate: 7.8, Band: 0.01}); err != nil {
+		t.Fatalf("AddPeg: %v", err)
+	}
+	if er, ok := storedRate(t, repo, "HKDEUR"); !ok || math.Abs(er.Rate-0.9/7.8) > 1e-12 || er.Source != models.RateSourcePeg {
+		t.Fatalf("HKDEUR = %+v, %v, want 0.9/7.8 from the peg", er, ok)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if _, err := convert.ConvertCurrency(ctx, "HKD", "EUR", 1); !errors.Is(err, ErrExchangeRateStale) {
+		t.Fatalf("HKD to EUR with a stale USDEUR: err = %v, want %v", err, ErrExchangeRateStale)
+	}
+
+	// the same value again refreshes USDEUR and with it the rates derived from it
+	if _, err := rates.UpdateExchangeRate(ctx, "USD", "EUR", 0.9, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+	for _, pair := range []string{"HKDEUR", "USDHKD", "HKDUSD"} {
+		if _, err := convert.ConvertCurrency(ctx, pair[:3], pair[3:], 1); err != nil {
+			t.Errorf("%s after USDEUR was refreshed: %v", pair, err)
+		}
+	}
+
+	usdeur, _ := storedRate(t, repo, "USDEUR")
+	if hkdeur, _ := storedRate(t, repo, "HKDEUR"); !hkdeur.UpdatedAt.Equal(usdeur.UpdatedAt) {
+		t.Errorf("HKDEUR updated at %v, want %v like USDEUR it was derived from", hkdeur.UpdatedAt, usdeur.UpdatedAt)
+	}
+}
+
+func TestPegRatesCanOnlyBeChangedThroughThePeg(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "HKD")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	pegs := NewPegService(repo, StaleRatePolicy{}, nil)
+	rates := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, pegs)
+
+	if _, err := pegs.AddPeg(ctx, models.Peg{CurrencyCode: "HKD", AnchorCurrencyCode: "USD", CentralRate: 7.8, Band: 0.01}); err != nil {
+		t.Fatalf("AddPeg: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		do   func() error
+		code string
+	}{
+		{"anchor rate outside the band", func() error { _, err := rates.UpdateExchangeRate(ctx, "USD", "HKD", 8, 0); return err }, "peg_band"},
+		{"derived rate", func() error { _, err := rates.UpdateExchangeRate(ctx, "HKD", "EUR", 0.1, 0); return err }, "derived"},
+		{"delete of the anchor rate", func() error { return rates.DeleteExchangeRate(ctx, "USD", "HKD") }, "derived"},
+		{"delete of a derived rate", func() error { return rates.DeleteExchangeRate(ctx, "HKD", "EUR") }, "derived"},
+	}
+	for _, tt := range tests {
+		var rateErr *RateError
+		if err := tt.do(); !errors.As(err, &rateErr) || rateErr.Code != tt.code {
+			t.Errorf("%s: err = %v, want a RateError with code %s", tt.name, err, tt.code)
+		}
+	}
+
+	// a rate within the band holds and the derived rates follow it
+	if _, err := rates.UpdateExchangeRate(ctx, "USD", "HKD", 7.85, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate within the band: %v", err)
+	}
+	if er, _ := storedRate(t, repo, "HKDEUR"); math.Abs(er.Rate-0.9/7.85) > 1e-12 {
+		t.Errorf("HKDEUR = %g, want 0.9/7.85", er.Rate)
+	}
+
+	if err := pegs.DeletePeg(ctx, "HKD"); err != nil {
+		t.Fatalf("DeletePeg: %v", err)
+	}
+	for _, pair := range []string{"HKDEUR", "HKDUSD"} {
+		if er, ok := storedRate(t, repo, pair); ok {
+			t.Errorf("%s = %+v after the peg was deleted, want none", pair, er)
+		}
+	}
+	if _, ok := storedRate(t, repo, "USDHKD"); !ok {
+		t.Error("USDHKD written within the band was deleted with the peg")
+	}
+}
+
+func TestPegRatesUseThePivots(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB", "DKK")
+	addRate(t, repo, "EURUSD", 1.1)
+	addRate(t, repo, "USDRUB", 90)
+
+	pegs := NewPegService(repo, StaleRatePolicy{}, []string{"USD"})
+	if _, err := pegs.AddPeg(ctx, models.Peg{CurrencyCode: "DKK", AnchorCurrencyCode: "EUR", CentralRate: 7.5}); err != nil {
+		t.Fatalf("AddPeg: %v", err)
+	}
+
+	// EUR has no rate to RUB, only a cross rate through USD
+	if er, ok := storedRate(t, repo, "DKKRUB"); !ok || math.Abs(er.Rate-1.1*90/7.5) > 1e-12 {
+		t.Errorf("DKKRUB = %+v, %v, want 1.1*90/7.5 through USD", er, ok)
+	}
+}
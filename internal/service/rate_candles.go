@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidInterval = errors.New("invalid candle interval")
+	ErrNoRateHistory   = errors.New("no rate history in the window")
+)
+
+// CandleIntervals are the lengths of candles by name
+var CandleIntervals = map[string]time.Duration{
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+	"1w": 7 * 24 * time.Hour,
+}
+
+// candleOrigin is a Monday midnight UTC, candles start a whole number of intervals after it so
+// days start at midnight and weeks on Monday
+var candleOrigin = time.Date(1970, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+// candleStart is the start of the candle t falls in
+func candleStart(t time.Time, interval time.Duration) time.Time {
+	return candleOrigin.Add(t.Sub(candleOrigin).Truncate(interval))
+}
+
+// GetRateCandles returns the candles of a pair from the one from falls in until to
+func (s *exchangeRateService) GetRateCandles(ctx context.Context, baseCode, targetCode, interval string, from, to time.Time) ([]models.RateCandle, error) {
+	length, ok := CandleIntervals[interval]
+	if !ok {
+		return nil, ErrInvalidInterval
+	}
+
+	return s.exchangeRateRepo.GetRateCandles(ctx, baseCode, targetCode, candleStart(from, length), to, length)
+}
+
+// GetRateStats sums up the last window candles of a pair until to. The moving averages are taken
+// over the closes, the exponential one weighs them by 2/(window+1).
+func (s *exchangeRateService) GetRateStats(ctx context.Context, baseCode, targetCode, interval string, window int, to time.Time) (models.RateStats, error) {
+	length, ok := CandleIntervals[interval]
+	if !ok {
+		return models.RateStats{}, ErrInvalidInterval
+	}
+
+	from := candleStart(to, length).Add(-time.Duration(window-1) * length)
+	candles, err := s.exchangeRateRepo.GetRateCandles(ctx, baseCode, targetCode, from, to, length)
+	if err != nil {
+		return models.RateStats{}, err
+	}
+	if len(candles) == 0 {
+		return models.RateStats{}, ErrNoRateHistory
+	}
+
+	first, last := candles[0], candles[len(candles)-1]
+	stats := models.RateStats{
+		Pair:     baseCode + targetCode,
+		Interval: interval,
+		From:     first.Start,
+		To:       to.UTC(),
+		Periods:  len(candles),
+		Open:     first.Open,
+		High:     first.High,
+		Low:      first.Low,
+		Close:    last.Close,
+		EMA:      first.Close,
+	}
+
+	alpha := 2 / float64(window+1)
+	var sum float64
+	var changes []float64
+	for i, candle := range candles {
+		stats.Updates += candle.Updates
+		stats.High = max(stats.High, candle.High)
+		stats.Low = min(stats.Low, candle.Low)
+		sum += candle.Close
+		if i > 0 {
+			stats.EMA = alpha*candle.Close + (1-alpha)*stats.EMA
+			if previous := candles[i-1].Close; previous != 0 {
+				changes = append(changes, (candle.Close/previous-1)*100)
+			}
+		}
+	}
+	stats.SMA = sum / float64(len(candles))
+	if stats.Open != 0 {
+		stats.ChangePercent = (stats.Close/stats.Open - 1) * 100
+	}
+	stats.Volatility = stddev(changes)
+
+	return stats, nil
+}
+
+// stddev is the sample standard deviation of values, zero for less than two
+func stddev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(squares / float64(len(values)-1))
+}
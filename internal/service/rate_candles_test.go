@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"maps"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCandleStart(t *testing.T) {
+	// a Wednesday afternoon
+	at := time.Date(2026, time.March, 11, 15, 42, 7, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		want     time.Time
+	}{
+		{"1h", time.Date(2026, time.March, 11, 15, 0, 0, 0, time.UTC)},
+		{"1d", time.Date(2026, time.March, 11, 0, 0, 0, 0, time.UTC)},
+		{"1w", time.Date(2026, time.March, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := candleStart(at, CandleIntervals[tt.interval]); !got.Equal(tt.want) {
+			t.Errorf("candleStart(%s) = %v, want %v", tt.interval, got, tt.want)
+		}
+	}
+}
+
+// rateHistory records the rates of USDEUR at past times the way the scheduler catches up on them
+func rateHistory(t *testing.T, repo testRepository, rates map[time.Time]float64) {
+	t.Helper()
+	ctx := context.Background()
+
+	times := slices.SortedFunc(maps.Keys(rates), time.Time.Compare)
+	for i, at := range times {
+		// the first change creates the pair at version 1, every other one updates the version before
+		scheduled := models.ScheduledRate{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: rates[at], RateVersion: int64(i), EffectiveFrom: at}
+		if _, err := repo.AddScheduledRate(ctx, scheduled); err != nil {
+			t.Fatalf("AddScheduledRate: %v", err)
+		}
+	}
+	if err := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false).ApplyDueScheduledRates(ctx); err != nil {
+		t.Fatalf("ApplyDueScheduledRates: %v", err)
+	}
+}
+
+func TestRateCandlesAndStats(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+
+	start := candleStart(time.Now(), time.Hour).Add(-5 * time.Hour)
+	rateHistory(t, repo, map[time.Time]float64{
+		// the rate in effect when the first candle opens
+		start.Add(-30 * time.Minute): 1,
+		start.Add(10 * time.Minute):  1.1,
+		start.Add(20 * time.Minute):  0.95,
+		start.Add(40 * time.Minute):  1.05,
+		// nothing happens in the second hour
+		start.Add(150 * time.Minute): 1.2,
+	})
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false)
+
+	candles, err := s.GetRateCandles(ctx, "USD", "EUR", "1h", start.Add(5*time.Minute), start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("GetRateCandles: %v", err)
+	}
+	want := []models.RateCandle{
+		{Start: start, Open: 1, High: 1.1, Low: 0.95, Close: 1.05, Updates: 3},
+		{Start: start.Add(time.Hour), Open: 1.05, High: 1.05, Low: 1.05, Close: 1.05},
+		{Start: start.Add(2 * time.Hour), Open: 1.05, High: 1.2, Low: 1.05, Close: 1.2, Updates: 1},
+	}
+	if len(candles) != len(want) {
+		t.Fatalf("candles = %+v, want %d", candles, len(want))
+	}
+	for i := range want {
+		if got := candles[i]; !got.Start.Equal(want[i].Start) || got.Open != want[i].Open || got.High != want[i].High ||
+			got.Low != want[i].Low || got.Close != want[i].Close || got.Updates != want[i].Updates {
+			t.Errorf("candles[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	stats, err := s.GetRateStats(ctx, "USD", "EUR", "1h", 3, start.Add(165*time.Minute))
+	if err != nil {
+		t.Fatalf("GetRateStats: %v", err)
+	}
+	if !stats.From.Equal(start) || stats.Periods != 3 || stats.Updates != 4 || stats.Open != 1 || stats.Close != 1.2 ||
+		stats.High != 1.2 || stats.Low != 0.95 {
+		t.Errorf("stats = %+v, want 3 periods from %v with 4 updates", stats, start)
+	}
+	for name, got := range map[string][2]float64{
+		"changePercent": {stats.ChangePercent, 20},
+		"sma":           {stats.SMA, (1.05 + 1.05 + 1.2) / 3},
+		"ema":           {stats.EMA, 1.125},
+		"volatility":    {stats.Volatility, (1.2/1.05 - 1) * 100 / math.Sqrt2},
+	} {
+		if math.Abs(got[0]-got[1]) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got[0], got[1])
+		}
+	}
+
+	if _, err := s.GetRateStats(ctx, "USD", "EUR", "1h", 2, start.Add(-2*time.Hour)); !errors.Is(err, ErrNoRateHistory) {
+		t.Errorf("stats before the first rate: err = %v, want ErrNoRateHistory", err)
+	}
+	if _, err := s.GetRateCandles(ctx, "USD", "EUR", "2h", start, start.Add(time.Hour)); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("candles of 2h: err = %v, want ErrInvalidInterval", err)
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrRateSubscriberDropped = errors.New("rate subscriber dropped")
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped
+const subscriberBuffer = 64
+
+type RateSubscription struct {
+	// Events is closed when the subscriber is too slow or unsubscribed
+	Events <-chan models.RateEvent
+	events chan models.RateEvent
+	filter func(models.RateEvent) bool
+}
+
+// rateFeed keeps the last events in a bounded log and fans new ones out to subscribers
+type rateFeed struct {
+	mu          sync.Mutex
+	nextID      uint64
+	log         []models.RateEvent
+	size        int
+	subscribers map[*RateSubscription]struct{}
+}
+
+func NewRateFeed(size int) *rateFeed {
+	return &rateFeed{
+		// ids continue from the start time, so ids from before a restart are always
+		// older than the log and resuming with them is reported as a gap
+		nextID:      uint64(time.Now().UnixMicro()),
+		size:        size,
+		subscribers: make(map[*RateSubscription]struct{}),
+	}
+}
+
+func (f *rateFeed) RateChanged(ctx context.Context, change models.RateChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	event := models.RateEvent{ID: f.nextID, RateChange: change}
+	f.nextID++
+
+	if len(f.log) == f.size {
+		f.log = f.log[1:]
+	}
+	f.log = append(f.log, event)
+
+	for sub := range f.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(f.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the logged events after lastEventID,
+// complete is false when some of them have already left the log
+func (f *rateFeed) Subscribe(lastEventID uint64, resume bool, filter func(models.RateEvent) bool) (sub *RateSubscription, backlog []models.RateEvent, complete bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	complete = true
+	if resume {
+		oldest := f.nextID
+		if len(f.log) > 0 {
+			oldest = f.log[0].ID
+		}
+		complete = lastEventID+1 >= oldest && lastEventID < f.nextID
+
+		for _, event := range f.log {
+			if event.ID > lastEventID && (filter == nil || filter(event)) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	events := make(chan models.RateEvent, subscriberBuffer)
+	sub = &RateSubscription{Events: events, events: events, filter: filter}
+	f.subscribers[sub] = struct{}{}
+
+	return sub, backlog, complete
+}
+
+func (f *rateFeed) Unsubscribe(sub *RateSubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// RateEventFilter matches events by pair codes like "USDRUB" or by a currency on either side,
+// nil means every event passes
+func RateEventFilter(pairs, currencies []string) func(models.RateEvent) bool {
+	pairSet := make(map[string]bool)
+	for _, pair := range pairs {
+		if pair = strings.TrimSpace(pair); pair != "" {
+			pairSet[strings.ToUpper(pair)] = true
+		}
+	}
+
+	currencySet := make(map[string]bool)
+	for _, code := range currencies {
+		if code = strings.TrimSpace(code); code != "" {
+			currencySet[strings.ToUpper(code)] = true
+		}
+	}
+
+	if len(pairSet) == 0 && len(currencySet) == 0 {
+		return nil
+	}
+
+	return func(event models.RateEvent) bool {
+		baseCode := event.Current.BaseCurrency.Code
+		targetCode := event.Current.TargetCurrency.Code
+
+		return pairSet[baseCode+targetCode] || currencySet[baseCode] || currencySet[targetCode]
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// RateError is a rate the guard rejected, Code is meant for the field errors of the API:
+// invalid, same_currency, out_of_range, jump_too_large, arbitrage, derived or peg_band
+type RateError struct {
+	Pair    string
+	Code    string
+	Message string
+}
+
+func (e *RateError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrInvalidRate, e.Pair, e.Message)
+}
+
+func (e *RateError) Unwrap() error {
+	return ErrInvalidRate
+}
+
+// RateErrors are the rejected rates of a write of several
+type RateErrors []*RateError
+
+func (e RateErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, rateErr := range e {
+		messages[i] = rateErr.Pair + ": " + rateErr.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidRate, strings.Join(messages, ", "))
+}
+
+func (e RateErrors) Unwrap() error {
+	return ErrInvalidRate
+}
+
+// RateBounds limit the rate of a pair, a zero Max leaves it unbounded above
+type RateBounds struct {
+	Min float64
+	Max float64
+}
+
+type RateGuardPolicy struct {
+	// Bounds limit the rates of pairs, keyed by base and target codes, e.g. "USDRUB"
+	Bounds map[string]RateBounds
+	// MaxJump is the largest relative change from the previous rate, e.g. 0.5 for 50%, zero
+	// disables the check
+	MaxJump float64
+	// InverseTolerance is how far the product of the rates of AB and BA may be off 1 before
+	// a write of either is answered with a warning, zero disables the check
+	InverseTolerance float64
+	// Arbitrage configures the search for loops of rates that return more than they take
+	Arbitrage ArbitragePolicy
+}
+
+// check rejects a rate that is not a finite positive number, is set on a pair of one currency,
+// is out of the bounds of its pair or moves too far from previous, which is nil for a new pair
+func (p RateGuardPolicy) check(baseCode, targetCode string, rate float64, previous *models.ExchangeRate) error {
+	pair := baseCode + targetCode
+
+	switch {
+	case math.IsNaN(rate) || math.IsInf(rate, 0):
+		return &RateError{Pair: pair, Code: "invalid", Message: "rate must be a finite number"}
+	case rate <= 0:
+		return &RateError{Pair: pair, Code: "invalid", Message: "rate must be greater than zero"}
+	case baseCode == targetCode:
+		return &RateError{Pair: pair, Code: "same_currency", Message: "base and target currencies must differ"}
+	}
+
+	if bounds, ok := p.Bounds[pair]; ok && (rate < bounds.Min || bounds.Max > 0 && rate > bounds.Max) {
+		message := fmt.Sprintf("rate of %s must be at least %g", pair, bounds.Min)
+		if bounds.Max > 0 {
+			message = fmt.Sprintf("rate of %s must be between %g and %g", pair, bounds.Min, bounds.Max)
+		}
+		return &RateError{Pair: pair, Code: "out_of_range", Message: message}
+	}
+
+	if previous != nil && p.MaxJump > 0 && previous.Rate > 0 {
+		if jump := math.Abs(rate-previous.Rate) / previous.Rate; jump > p.MaxJump {
+			return &RateError{Pair: pair, Code: "jump_too_large",
+				Message: fmt.Sprintf("rate moves %.2f%% from %g, at most %.2f%% is allowed", jump*100, previous.Rate, p.MaxJump*100)}
+		}
+	}
+
+	return nil
+}
+
+// inverseWarning describes how far rate and the rate of the reverse pair disagree, it is empty
+// while they agree within the tolerance
+func (p RateGuardPolicy) inverseWarning(rate, reverse models.ExchangeRate) string {
+	if p.InverseTolerance <= 0 {
+		return ""
+	}
+
+	product := rate.Rate * reverse.Rate
+	if math.Abs(product-1) <= p.InverseTolerance {
+		return ""
+	}
+
+	return fmt.Sprintf("%s%s × %s%s = %g, it should be 1 within %g",
+		rate.BaseCurrency.Code, rate.TargetCurrency.Code, reverse.BaseCurrency.Code, reverse.TargetCurrency.Code, product, p.InverseTolerance)
+}
+
+// inverseWarnings compares a written rate with the stored rate of the reverse pair
+func (s *exchangeRateService) inverseWarnings(ctx context.Context, rate models.ExchangeRate) []string {
+	const op = "internal.service.service.inverseWarnings"
+
+	if s.guard.InverseTolerance <= 0 {
+		return nil
+	}
+
+	reverse, err := s.exchangeRateRepo.GetExchangeRate(ctx, rate.TargetCurrency.Code, rate.BaseCurrency.Code)
+	if err != nil {
+		return nil
+	}
+
+	warning := s.guard.inverseWarning(rate, reverse)
+	if warning == "" {
+		return nil
+	}
+
+	log.Printf("%s: %s", op, warning)
+	return []string{warning}
+}
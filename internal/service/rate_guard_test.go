@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"math"
+	"testing"
+)
+
+func TestRateGuardPolicyCheck(t *testing.T) {
+	policy := RateGuardPolicy{
+		Bounds:  map[string]RateBounds{"USDRUB": {Min: 50, Max: 150}, "USDJPY": {Min: 100}},
+		MaxJump: 0.5,
+	}
+	previous := &models.ExchangeRate{Rate: 1}
+
+	tests := []struct {
+		name     string
+		pair     string
+		rate     float64
+		previous *models.ExchangeRate
+		code     string
+	}{
+		{"valid", "USDEUR", 0.9, nil, ""},
+		{"zero", "USDEUR", 0, nil, "invalid"},
+		{"negative", "USDEUR", -1, nil, "invalid"},
+		{"NaN", "USDEUR", math.NaN(), nil, "invalid"},
+		{"infinity", "USDEUR", math.Inf(1), nil, "invalid"},
+		{"same currency", "USDUSD", 1, nil, "same_currency"},
+		{"within the bounds", "USDRUB", 150, nil, ""},
+		{"below the bounds", "USDRUB", 49, nil, "out_of_range"},
+		{"above the bounds", "USDRUB", 151, nil, "out_of_range"},
+		{"no upper bound", "USDJPY", 1e6, nil, ""},
+		{"below the lower bound", "USDJPY", 99, nil, "out_of_range"},
+		{"small jump", "USDEUR", 1.5, previous, ""},
+		{"jump up", "USDEUR", 1.6, previous, "jump_too_large"},
+		{"jump down", "USDEUR", 0.4, previous, "jump_too_large"},
+		{"new pair can not jump", "USDEUR", 100, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.check(tt.pair[:3], tt.pair[3:], tt.rate, tt.previous)
+
+			var rateErr *RateError
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("err = %v, want none", err)
+				}
+			} else if !errors.As(err, &rateErr) || rateErr.Code != tt.code || rateErr.Pair != tt.pair {
+				t.Errorf("err = %v, want %s of %s", err, tt.code, tt.pair)
+			}
+		})
+	}
+}
+
+func TestRateGuardRejectsBulkUpdate(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "USDRUB", 90)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{MaxJump: 0.1}, false)
+
+	_, err := s.UpdateExchangeRates(ctx, []models.RateUpdate{
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: 0.91},
+		{BaseCurrencyCode: "USD", TargetCurrencyCode: "RUB", Rate: 900},
+	})
+	var rateErrs RateErrors
+	if !errors.As(err, &rateErrs) || len(rateErrs) != 1 || rateErrs[0].Pair != "USDRUB" || rateErrs[0].Code != "jump_too_large" {
+		t.Fatalf("err = %v, want jump_too_large of USDRUB", err)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.9 {
+		t.Errorf("USDEUR = %v after a rejected update, want 0.9", got.Rate)
+	}
+}
+
+func TestInverseWarning(t *testing.T) {
+	rate := models.ExchangeRate{BaseCurrency: models.Currency{Code: "USD"}, TargetCurrency: models.Currency{Code: "EUR"}, Rate: 0.9}
+	reverse := models.ExchangeRate{BaseCurrency: models.Currency{Code: "EUR"}, TargetCurrency: models.Currency{Code: "USD"}}
+
+	tests := []struct {
+		tolerance float64
+		reverse   float64
+		warn      bool
+	}{
+		{0, 2, false},
+		{0.01, 1 / 0.9, false},
+		{0.01, 1.2, true},
+		{0.01, 1, true},
+	}
+	for _, tt := range tests {
+		reverse.Rate = tt.reverse
+		warning := RateGuardPolicy{InverseTolerance: tt.tolerance}.inverseWarning(rate, reverse)
+		if (warning != "") != tt.warn {
+			t.Errorf("tolerance %v, reverse %v: warning = %q, want a warning %v", tt.tolerance, tt.reverse, warning, tt.warn)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MaxMatrixCurrencies bounds the currencies of a matrix, its cells grow with the square
+const MaxMatrixCurrencies = 200
+
+// ErrMatrixTooLarge is returned for a matrix of more than MaxMatrixCurrencies currencies
+var ErrMatrixTooLarge = errors.New("rate matrix has too many currencies")
+
+// rateMatrix holds the routes between every two active currencies for one state of the stored
+// rates, the stale rate policy is applied when it is read since rates age without writes
+type rateMatrix struct {
+	auditID int64
+	codes   []string
+	index   map[string]int
+	// routes[i][j] are the routes from codes[i] to codes[j] in order of preference
+	routes [][][]route
+}
+
+type rateMatrixCache struct {
+	mu      sync.Mutex
+	current *rateMatrix
+}
+
+func newRateMatrix(auditID int64, currencies []models.Currency, rates []models.ExchangeRate, pivots []string) *rateMatrix {
+	m := &rateMatrix{auditID: auditID, index: make(map[string]int, len(currencies))}
+	for _, currency := range currencies {
+		m.codes = append(m.codes, currency.Code)
+	}
+	slices.Sort(m.codes)
+	for i, code := range m.codes {
+		m.index[code] = i
+	}
+
+	q := newQuotes(rates)
+	m.routes = make([][][]route, len(m.codes))
+	for i, fromCode := range m.codes {
+		m.routes[i] = make([][]route, len(m.codes))
+		for j, toCode := range m.codes {
+			if i != j {
+				m.routes[i][j] = q.routes(fromCode, toCode, pivots)
+			}
+		}
+	}
+
+	return m
+}
+
+// GetRateMatrix returns the rates between every two of codes, or of every active currency when
+// codes is empty, resolved the way ConvertCurrency resolves a single pair. Either may be at most
+// MaxMatrixCurrencies currencies, ErrMatrixTooLarge is returned otherwise.
+func (s *convertService) GetRateMatrix(ctx context.Context, codes []string) (models.RateMatrix, error) {
+	const op = "internal.service.service.GetRateMatrix"
+
+	if len(codes) > MaxMatrixCurrencies {
+		return models.RateMatrix{}, fmt.Errorf("%s: %w", op, ErrMatrixTooLarge)
+	}
+
+	m, err := s.rateMatrix(ctx)
+	if err != nil {
+		return models.RateMatrix{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(codes) == 0 {
+		codes = m.codes
+	}
+	if len(codes) > MaxMatrixCurrencies {
+		return models.RateMatrix{}, fmt.Errorf("%s: %d active currencies: %w", op, len(codes), ErrMatrixTooLarge)
+	}
+
+	rows := make([]int, len(codes))
+	for i, code := range codes {
+		row, ok := m.index[code]
+		if !ok {
+			// a currency missing from the matrix is unknown or inactive, the repository tells which
+			if _, err := s.currencyRepo.GetCurrencyByCode(ctx, code); err != nil {
+				return models.RateMatrix{}, fmt.Errorf("%s: %s: %w", op, code, err)
+			}
+			return models.RateMatrix{}, fmt.Errorf("%s: %s: %w", op, code, repository.ErrCurrencyInactive)
+		}
+		rows[i] = row
+	}
+
+	now := time.Now()
+	matrix := models.RateMatrix{
+		Currencies: append([]string{}, codes...),
+		Cells:      make([][]models.RateMatrixCell, len(rows)),
+	}
+	for i, from := range rows {
+		matrix.Cells[i] = make([]models.RateMatrixCell, len(rows))
+		for j, to := range rows {
+			matrix.Cells[i][j] = s.matrixCell(m, from, to, now)
+		}
+	}
+
+	return matrix, nil
+}
+
+func (s *convertService) matrixCell(m *rateMatrix, from, to int, now time.Time) models.RateMatrixCell {
+	if from == to {
+		rate := 1.0
+		return models.RateMatrixCell{Rate: &rate, Kind: models.RouteIdentity}
+	}
+
+	chosen, stale, err := s.stalePolicy.pickRoute(m.routes[from][to], now)
+	switch {
+	case errors.Is(err, ErrExchangeRateStale):
+		return models.RateMatrixCell{Kind: models.RouteUnavailable, Reason: "stale"}
+	case err != nil:
+		return models.RateMatrixCell{Kind: models.RouteUnavailable, Reason: "not_found"}
+	}
+
+	r := m.routes[from][to][chosen]
+	return models.RateMatrixCell{Rate: &r.rate, Kind: r.kind, Pivot: r.pivot, Stale: stale}
+}
+
+// rateMatrix returns the cached matrix, computed again once currencies or rates have changed.
+// Every such write adds an audit entry in its transaction, also when an import command makes it
+// in another process, so the latest entry tells whether the cached matrix is current.
+func (s *convertService) rateMatrix(ctx context.Context) (*rateMatrix, error) {
+	// the ID is read before the rates, a write in between only makes the next read compute again
+	auditID, err := s.exchangeRateRepo.LastAuditID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.matrix.mu.Lock()
+	defer s.matrix.mu.Unlock()
+
+	if s.matrix.current != nil && s.matrix.current.auditID == auditID {
+		return s.matrix.current, nil
+	}
+
+	currencies, err := s.currencyRepo.GetAllCurrencies(ctx)
+	if err != nil && !errors.Is(err, repository.ErrCurrencyNotFound) {
+		return nil, err
+	}
+	rates, err := s.exchangeRateRepo.GetAllExchangeRates(ctx)
+	if err != nil && !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return nil, err
+	}
+
+	s.matrix.current = newRateMatrix(auditID, currencies, rates, s.pivots)
+
+	return s.matrix.current, nil
+}
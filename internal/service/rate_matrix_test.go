@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"fmt"
+	"testing"
+)
+
+// matrixRate is the rate of the cell from one currency to another, 0 when it is unavailable
+func matrixRate(t *testing.T, matrix models.RateMatrix, from, to string) float64 {
+	t.Helper()
+	index := make(map[string]int, len(matrix.Currencies))
+	for i, code := range matrix.Currencies {
+		index[code] = i
+	}
+	cell := matrix.Cells[index[from]][index[to]]
+	if cell.Rate == nil {
+		return 0
+	}
+	return *cell.Rate
+}
+
+func TestRateMatrixFollowsWrites(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "USDRUB", 90)
+
+	s := NewConvertService(repo, repo, StaleRatePolicy{}, []string{"USD"})
+
+	matrix, err := s.GetRateMatrix(ctx, nil)
+	if err != nil {
+		t.Fatalf("GetRateMatrix: %v", err)
+	}
+	if got := matrixRate(t, matrix, "EUR", "RUB"); got != 90/0.9 {
+		t.Errorf("EURRUB = %v, want the cross rate %v", got, 90/0.9)
+	}
+
+	// a rate write invalidates the cached routes
+	if _, err := repo.UpdateExchangeRate(ctx, "USD", "RUB", 99, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+	matrix, err = s.GetRateMatrix(ctx, []string{"EUR", "RUB"})
+	if err != nil {
+		t.Fatalf("GetRateMatrix: %v", err)
+	}
+	if got := matrixRate(t, matrix, "EUR", "RUB"); got != 99/0.9 {
+		t.Errorf("EURRUB after the update = %v, want %v", got, 99/0.9)
+	}
+
+	// so does a new currency
+	addCurrencies(t, repo, "GBP")
+	matrix, err = s.GetRateMatrix(ctx, nil)
+	if err != nil {
+		t.Fatalf("GetRateMatrix: %v", err)
+	}
+	if len(matrix.Currencies) != 4 || matrixRate(t, matrix, "GBP", "USD") != 0 {
+		t.Errorf("matrix = %v, want GBP without rates", matrix.Currencies)
+	}
+}
+
+func TestRateMatrixIsCapped(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	codes := make([]string, MaxMatrixCurrencies+1)
+	for i := range codes {
+		codes[i] = fmt.Sprintf("C%02d", i)
+	}
+	addCurrencies(t, repo, codes...)
+
+	s := NewConvertService(repo, repo, StaleRatePolicy{}, nil)
+
+	if _, err := s.GetRateMatrix(ctx, codes); !errors.Is(err, ErrMatrixTooLarge) {
+		t.Errorf("matrix of the listed currencies: err = %v, want ErrMatrixTooLarge", err)
+	}
+	if _, err := s.GetRateMatrix(ctx, nil); !errors.Is(err, ErrMatrixTooLarge) {
+		t.Errorf("matrix of every active currency: err = %v, want ErrMatrixTooLarge", err)
+	}
+	if _, err := s.GetRateMatrix(ctx, codes[:MaxMatrixCurrencies]); err != nil {
+		t.Errorf("matrix of %d currencies: %v", MaxMatrixCurrencies, err)
+	}
+}
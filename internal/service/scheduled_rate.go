@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"log"
+	"time"
+)
+
+var ErrEffectiveFromPast = errors.New("effective time is not in the future")
+
+// schedulerMaxWait bounds how long the scheduler sleeps, so a change of the system clock or a
+// change scheduled by another process is picked up
+const schedulerMaxWait = time.Minute
+
+// schedulerActor is recorded in the audit log for rates set by scheduled changes
+const schedulerActor = "scheduler"
+
+// ScheduleExchangeRate stores a rate to set at effectiveFrom on a pair that has no rate, the pair
+// is created then. A pair with a rate is changed with ScheduleExchangeRateUpdate.
+func (s *exchangeRateService) ScheduleExchangeRate(ctx context.Context, baseCode, targetCode string, rate float64, effectiveFrom time.Time) (models.ScheduledRate, error) {
+	if _, err := s.exchangeRateRepo.GetExchangeRate(ctx, baseCode, targetCode); err == nil {
+		return models.ScheduledRate{}, repository.ErrExchangeRateExists
+	} else if !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return models.ScheduledRate{}, err
+	}
+
+	return s.schedule(ctx, baseCode, targetCode, rate, effectiveFrom, nil)
+}
+
+// ScheduleExchangeRateUpdate schedules a new rate of an existing pair. A change the approval
+// policy would hold back can not be scheduled, it has to be proposed. Like UpdateExchangeRate it
+// is made against version, the current one when it is 0, and only set while the pair is at it.
+func (s *exchangeRateService) ScheduleExchangeRateUpdate(ctx context.Context, baseCode, targetCode string, rate float64, version int64, effectiveFrom time.Time) (models.ScheduledRate, error) {
+	if version == 0 && s.requireVersion {
+		return models.ScheduledRate{}, ErrVersionRequired
+	}
+
+	current, err := s.exchangeRateRepo.GetExchangeRate(ctx, baseCode, targetCode)
+	if err != nil {
+		return models.ScheduledRate{}, err
+	}
+	if version != 0 && version != current.Version {
+		return models.ScheduledRate{}, repository.ErrVersionMismatch
+	}
+
+	return s.schedule(ctx, baseCode, targetCode, rate, effectiveFrom, &current)
+}
+
+// schedule checks a change like a direct write against previous, nil for a new pair, and stores
+// it against the version of previous
+func (s *exchangeRateService) schedule(ctx context.Context, baseCode, targetCode string, rate float64, effectiveFrom time.Time, previous *models.ExchangeRate) (models.ScheduledRate, error) {
+	now := time.Now()
+	if !effectiveFrom.After(now) {
+		return models.ScheduledRate{}, ErrEffectiveFromPast
+	}
+
+	if err := s.checkScheduledRate(ctx, baseCode, targetCode, rate, previous); err != nil {
+		return models.ScheduledRate{}, err
+	}
+
+	scheduled := models.ScheduledRate{
+		BaseCurrencyCode:   baseCode,
+		TargetCurrencyCode: targetCode,
+		Rate:               rate,
+		EffectiveFrom:      effectiveFrom,
+		CreatedBy:          models.ActorFromContext(ctx).Name,
+		CreatedAt:          now,
+	}
+	if previous != nil {
+		scheduled.RateVersion = previous.Version
+	}
+
+	scheduled, err := s.exchangeRateRepo.AddScheduledRate(ctx, scheduled)
+	if err != nil {
+		return models.ScheduledRate{}, err
+	}
+
+	s.wakeScheduler()
+
+	return scheduled, nil
+}
+
+// checkScheduledRate runs the checks of a direct write of rate over previous, nil for a new pair,
+// both when a change is scheduled and when it becomes effective
+func (s *exchangeRateService) checkScheduledRate(ctx context.Context, baseCode, targetCode string, rate float64, previous *models.ExchangeRate) error {
+	if err := s.guard.check(baseCode, targetCode, rate, previous); err != nil {
+		return err
+	}
+	if previous != nil && s.approval.requiresApproval(*previous, rate) {
+		return ErrApprovalRequired
+	}
+	if err := s.checkDerived(ctx, models.RateUpdate{BaseCurrencyCode: baseCode, TargetCurrencyCode: targetCode, Rate: rate}); err != nil {
+		return err
+	}
+	return s.checkArbitrage(ctx, models.RateUpdate{BaseCurrencyCode: baseCode, TargetCurrencyCode: targetCode, Rate: rate})
+}
+
+func (s *exchangeRateService) ListScheduledRates(ctx context.Context, filter models.ScheduledRateFilter, page models.PageRequest) (models.Page[models.ScheduledRate], error) {
+	return s.exchangeRateRepo.ListScheduledRates(ctx, filter, page)
+}
+
+func (s *exchangeRateService) GetScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error) {
+	return s.exchangeRateRepo.GetScheduledRate(ctx, id)
+}
+
+func (s *exchangeRateService) CancelScheduledRate(ctx context.Context, id int) (models.ScheduledRate, error) {
+	return s.exchangeRateRepo.CancelScheduledRate(ctx, id)
+}
+
+// scheduledRateFailures are the errors that fail a due change for good, the others are retried
+var scheduledRateFailures = []error{
+	repository.ErrCurrencyNotFound,
+	repository.ErrCurrencyInactive,
+	repository.ErrExchangeRateExists,
+	repository.ErrExchangeRateNotFound,
+	repository.ErrVersionMismatch,
+	repository.ErrScheduledRateOutdated,
+	ErrApprovalRequired,
+}
+
+// scheduledRateFailure tells why err fails a due change for good, ok is false when it does not
+func scheduledRateFailure(err error) (reason string, ok bool) {
+	if errors.Is(err, ErrInvalidRate) {
+		return err.Error(), true
+	}
+	for _, failure := range scheduledRateFailures {
+		if errors.Is(err, failure) {
+			return failure.Error(), true
+		}
+	}
+	return "", false
+}
+
+// ApplyDueScheduledRates sets the scheduled rates whose time has come and notifies the listeners.
+// Run at startup it catches up on the changes that became effective while the server was down.
+// Every change is checked again like a direct write, one that fails the checks or whose pair was
+// written since it was scheduled is marked failed with the reason and logged.
+func (s *exchangeRateService) ApplyDueScheduledRates(ctx context.Context) error {
+	const op = "internal.service.service.ApplyDueScheduledRates"
+
+	ctx = models.ContextWithActor(ctx, models.Actor{Name: schedulerActor})
+	now := time.Now()
+
+	due, err := s.exchangeRateRepo.DueScheduledRates(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, scheduled := range due {
+		change, err := s.applyScheduledRate(ctx, scheduled, now)
+		if err == nil {
+			s.notify(ctx, change)
+			continue
+		}
+		// canceled meanwhile
+		if errors.Is(err, repository.ErrScheduledRateNotPending) {
+			continue
+		}
+
+		reason, ok := scheduledRateFailure(err)
+		if !ok {
+			return err
+		}
+		log.Printf("%s: scheduled rate %d of %s%s failed: %s", op, scheduled.ID, scheduled.BaseCurrencyCode, scheduled.TargetCurrencyCode, reason)
+		if _, err := s.exchangeRateRepo.FailScheduledRate(ctx, scheduled.ID, reason, now); err != nil && !errors.Is(err, repository.ErrScheduledRateNotPending) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyScheduledRate checks a due change against the current rate of its pair and sets it
+func (s *exchangeRateService) applyScheduledRate(ctx context.Context, scheduled models.ScheduledRate, now time.Time) (models.RateChange, error) {
+	var previous *models.ExchangeRate
+	if current, err := s.exchangeRateRepo.GetExchangeRate(ctx, scheduled.BaseCurrencyCode, scheduled.TargetCurrencyCode); err == nil {
+		previous = &current
+	} else if !errors.Is(err, repository.ErrExchangeRateNotFound) {
+		return models.RateChange{}, err
+	}
+
+	if err := s.checkScheduledRate(ctx, scheduled.BaseCurrencyCode, scheduled.TargetCurrencyCode, scheduled.Rate, previous); err != nil {
+		return models.RateChange{}, err
+	}
+
+	return s.exchangeRateRepo.ApplyScheduledRate(ctx, scheduled.ID, now)
+}
+
+// RunScheduler applies scheduled rates as they become effective until ctx is done
+func (s *exchangeRateService) RunScheduler(ctx context.Context) {
+	const op = "internal.service.service.RunScheduler"
+
+	for {
+		if err := s.ApplyDueScheduledRates(ctx); err != nil {
+			log.Printf("%s: %v", op, err)
+		}
+
+		wait := schedulerMaxWait
+		next, err := s.exchangeRateRepo.NextScheduledRateAt(ctx)
+		if err == nil {
+			wait = min(max(time.Until(next), 0), schedulerMaxWait)
+		} else if !errors.Is(err, repository.ErrScheduledRateNotFound) {
+			log.Printf("%s: %v", op, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.scheduled:
+			timer.Stop()
+		}
+	}
+}
+
+// wakeScheduler makes RunScheduler look at the next change again, a wake-up that is already
+// queued covers this one
+func (s *exchangeRateService) wakeScheduler() {
+	select {
+	case s.scheduled <- struct{}{}:
+	default:
+	}
+}
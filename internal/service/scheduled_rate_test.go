@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addScheduledRate stores a change directly, so it can be due already
+func addScheduledRate(t *testing.T, repo testRepository, scheduled models.ScheduledRate) models.ScheduledRate {
+	t.Helper()
+	scheduled.CreatedAt = scheduled.EffectiveFrom.Add(-time.Hour)
+	scheduled, err := repo.AddScheduledRate(context.Background(), scheduled)
+	if err != nil {
+		t.Fatalf("AddScheduledRate: %v", err)
+	}
+	return scheduled
+}
+
+// scheduledStatus is the status of a scheduled change and why it failed
+func scheduledStatus(t *testing.T, repo testRepository, id int) (models.ScheduledRateStatus, string) {
+	t.Helper()
+	scheduled, err := repo.GetScheduledRate(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetScheduledRate(%d): %v", id, err)
+	}
+	return scheduled.Status, scheduled.Error
+}
+
+func TestScheduleExchangeRateInThePast(t *testing.T) {
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false)
+	if _, err := s.ScheduleExchangeRate(context.Background(), "USD", "EUR", 0.9, time.Now().Add(-time.Second)); !errors.Is(err, ErrEffectiveFromPast) {
+		t.Errorf("err = %v, want ErrEffectiveFromPast", err)
+	}
+}
+
+func TestScheduleExchangeRateChecks(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{Pairs: map[string]bool{"USDRUB": true}}, RateGuardPolicy{MaxJump: 0.1}, false)
+	at := time.Now().Add(time.Hour)
+
+	if _, err := s.ScheduleExchangeRate(ctx, "USD", "EUR", 0.91, at); !errors.Is(err, repository.ErrExchangeRateExists) {
+		t.Errorf("scheduled create of a pair with a rate: err = %v, want ErrExchangeRateExists", err)
+	}
+	var rateErr *RateError
+	if _, err := s.ScheduleExchangeRateUpdate(ctx, "USD", "EUR", 2, 0, at); !errors.As(err, &rateErr) || rateErr.Code != "jump_too_large" {
+		t.Errorf("scheduled jump: err = %v, want jump_too_large", err)
+	}
+
+	scheduled, err := s.ScheduleExchangeRateUpdate(ctx, "USD", "EUR", 0.91, 0, at)
+	if err != nil {
+		t.Fatalf("ScheduleExchangeRateUpdate: %v", err)
+	}
+	if scheduled.RateVersion != usdeur.Version {
+		t.Errorf("scheduled change against version %d, want %d", scheduled.RateVersion, usdeur.Version)
+	}
+	if _, err := s.ScheduleExchangeRateUpdate(ctx, "USD", "EUR", 0.91, usdeur.Version+1, at); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("scheduled change against another version: err = %v, want ErrVersionMismatch", err)
+	}
+	versioned := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, true)
+	if _, err := versioned.ScheduleExchangeRateUpdate(ctx, "USD", "EUR", 0.91, 0, at); !errors.Is(err, ErrVersionRequired) {
+		t.Errorf("scheduled change without a version: err = %v, want ErrVersionRequired", err)
+	}
+
+	created, err := s.ScheduleExchangeRate(ctx, "USD", "RUB", 90, at)
+	if err != nil {
+		t.Fatalf("ScheduleExchangeRate: %v", err)
+	}
+	if created.RateVersion != 0 {
+		t.Errorf("scheduled create against version %d, want 0", created.RateVersion)
+	}
+	addRate(t, repo, "USDRUB", 90)
+	if _, err := s.ScheduleExchangeRateUpdate(ctx, "USD", "RUB", 91, 0, at); !errors.Is(err, ErrApprovalRequired) {
+		t.Errorf("scheduled change that needs approval: err = %v, want ErrApprovalRequired", err)
+	}
+}
+
+func TestApplyDueScheduledRatesKeepsNewerWrites(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB", "GBP")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+	addRate(t, repo, "USDGBP", 0.8)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	// while the server was down: an update scheduled against the current version, a create of a
+	// pair that got a rate meanwhile, and a create of a pair deleted after the change became effective
+	update := addScheduledRate(t, repo, models.ScheduledRate{
+		BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: 0.95, RateVersion: usdeur.Version, EffectiveFrom: now.Add(-time.Hour),
+	})
+	create := addScheduledRate(t, repo, models.ScheduledRate{
+		BaseCurrencyCode: "USD", TargetCurrencyCode: "RUB", Rate: 90, EffectiveFrom: now.Add(-time.Hour),
+	})
+	recreate := addScheduledRate(t, repo, models.ScheduledRate{
+		BaseCurrencyCode: "USD", TargetCurrencyCode: "GBP", Rate: 0.7, EffectiveFrom: now.Add(-time.Hour),
+	})
+
+	manual, err := repo.UpdateExchangeRate(ctx, "USD", "EUR", 0.92, 0)
+	if err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+	addRate(t, repo, "USDRUB", 95)
+	if _, err := repo.DeleteExchangeRate(ctx, "USD", "GBP"); err != nil {
+		t.Fatalf("DeleteExchangeRate: %v", err)
+	}
+
+	changes := &recorder{}
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, changes)
+	if err := s.ApplyDueScheduledRates(ctx); err != nil {
+		t.Fatalf("ApplyDueScheduledRates: %v", err)
+	}
+
+	if len(changes.changes) != 0 {
+		t.Errorf("changes = %+v, want none", changes.changes)
+	}
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.92 || !got.UpdatedAt.Equal(manual.UpdatedAt) {
+		t.Errorf("USDEUR = %v updated at %v, want the manual 0.92 at %v", got.Rate, got.UpdatedAt, manual.UpdatedAt)
+	}
+	if got, _ := storedRate(t, repo, "USDRUB"); got.Rate != 95 {
+		t.Errorf("USDRUB = %v, want the manual 95", got.Rate)
+	}
+	if _, ok := storedRate(t, repo, "USDGBP"); ok {
+		t.Error("USDGBP deleted after the change became effective was created again")
+	}
+
+	failures := []struct {
+		scheduled models.ScheduledRate
+		reason    error
+	}{
+		{update, repository.ErrVersionMismatch},
+		{create, repository.ErrExchangeRateExists},
+		{recreate, repository.ErrScheduledRateOutdated},
+	}
+	for _, f := range failures {
+		if status, reason := scheduledStatus(t, repo, f.scheduled.ID); status != models.ScheduledRateFailed || reason != f.reason.Error() {
+			t.Errorf("scheduled %s%s = %s (%q), want failed with %q", f.scheduled.BaseCurrencyCode, f.scheduled.TargetCurrencyCode, status, reason, f.reason)
+		}
+	}
+}
+
+func TestApplyDueScheduledRatesChecksAgain(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR")
+	usdeur := addRate(t, repo, "USDEUR", 0.9)
+
+	// the guard was tightened after the change was scheduled
+	scheduled := addScheduledRate(t, repo, models.ScheduledRate{
+		BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Rate: 1.2, RateVersion: usdeur.Version, EffectiveFrom: time.Now().Add(-time.Minute),
+	})
+
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{MaxJump: 0.1}, false)
+	if err := s.ApplyDueScheduledRates(ctx); err != nil {
+		t.Fatalf("ApplyDueScheduledRates: %v", err)
+	}
+
+	if got, _ := storedRate(t, repo, "USDEUR"); got.Rate != 0.9 {
+		t.Errorf("USDEUR = %v, want 0.9", got.Rate)
+	}
+	if status, reason := scheduledStatus(t, repo, scheduled.ID); status != models.ScheduledRateFailed || !strings.Contains(reason, "moves") {
+		t.Errorf("scheduled change = %s (%q), want it failed by the jump guard", status, reason)
+	}
+}
+
+func TestApplyDueScheduledRatesCatchesUp(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	addCurrencies(t, repo, "USD", "EUR", "RUB", "GBP")
+
+	// the changes were scheduled before the server went down, so they are stored directly
+	now := time.Now().UTC().Truncate(time.Second)
+	schedule := func(pair string, rate float64, version int64, effectiveFrom time.Time) models.ScheduledRate {
+		t.Helper()
+		return addScheduledRate(t, repo, models.ScheduledRate{
+			BaseCurrencyCode: pair[:3], TargetCurrencyCode: pair[3:], Rate: rate, RateVersion: version, EffectiveFrom: effectiveFrom,
+		})
+	}
+	// added out of order, they are applied oldest first: USDEUR is created at version 1 and then
+	// updated twice
+	later := schedule("USDEUR", 0.97, 2, now.Add(-time.Hour))
+	earlier := schedule("USDEUR", 0.95, 1, now.Add(-2*time.Hour))
+	usdeur := schedule("USDEUR", 0.9, 0, now.Add(-3*time.Hour))
+	created := schedule("USDRUB", 90, 0, now.Add(-90*time.Minute))
+	canceled := schedule("USDEUR", 1.5, 3, now.Add(-30*time.Minute))
+	failed := schedule("USDGBP", 0.8, 0, now.Add(-30*time.Minute))
+	future := schedule("USDEUR", 0.99, 3, now.Add(time.Hour))
+
+	if _, err := repo.CancelScheduledRate(ctx, canceled.ID); err != nil {
+		t.Fatalf("CancelScheduledRate: %v", err)
+	}
+	if _, _, err := repo.DeactivateCurrency(ctx, "GBP", false); err != nil {
+		t.Fatalf("DeactivateCurrency: %v", err)
+	}
+
+	changes := &recorder{}
+	s := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, changes)
+	if err := s.ApplyDueScheduledRates(ctx); err != nil {
+		t.Fatalf("ApplyDueScheduledRates: %v", err)
+	}
+
+	if len(changes.changes) != 4 {
+		t.Fatalf("changes = %+v, want the 4 applied ones", changes.changes)
+	}
+	got, _ := storedRate(t, repo, "USDEUR")
+	if got.Rate != 0.97 || !got.UpdatedAt.Equal(later.EffectiveFrom) || got.Source != models.RateSourceSchedule {
+		t.Errorf("USDEUR = %v updated at %v by %q, want 0.97 at %v by the schedule", got.Rate, got.UpdatedAt, got.Source, later.EffectiveFrom)
+	}
+	if got, ok := storedRate(t, repo, "USDRUB"); !ok || got.Rate != 90 || !got.UpdatedAt.Equal(created.EffectiveFrom) {
+		t.Errorf("USDRUB = %+v, %v, want 90 created at %v", got, ok, created.EffectiveFrom)
+	}
+
+	// the history is as if the server had been up at every effective time
+	history, err := repo.GetExchangeRateHistory(ctx, []int{got.ID}, now.Add(-4*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("GetExchangeRateHistory: %v", err)
+	}
+	recorded := make(map[float64]time.Time)
+	for _, point := range history {
+		recorded[point.Rate] = point.RecordedAt
+	}
+	for _, scheduled := range []models.ScheduledRate{usdeur, earlier, later} {
+		if at, ok := recorded[scheduled.Rate]; !ok || !at.Equal(scheduled.EffectiveFrom) {
+			t.Errorf("%v recorded at %v, want %v", scheduled.Rate, at, scheduled.EffectiveFrom)
+		}
+	}
+
+	statuses := map[int]models.ScheduledRateStatus{
+		usdeur.ID: models.ScheduledRateApplied, earlier.ID: models.ScheduledRateApplied, later.ID: models.ScheduledRateApplied,
+		created.ID:  models.ScheduledRateApplied,
+		canceled.ID: models.ScheduledRateCanceled, failed.ID: models.ScheduledRateFailed, future.ID: models.ScheduledRatePending,
+	}
+	for id, want := range statuses {
+		scheduled, err := repo.GetScheduledRate(ctx, id)
+		if err != nil || scheduled.Status != want {
+			t.Errorf("scheduled rate %d = %+v, %v, want it %s", id, scheduled, err, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"sync"
+	"testing"
+)
+
+// testRepository is everything the services need from the SQLite repository
+type testRepository interface {
+	alertRepository
+	rateHistoryRepository
+	auditRepository
+	basketRepository
+	catalogueRepository
+	currencyRepository
+	exchangeRateRepository
+	pegRepository
+	Close() error
+}
+
+// newTestRepository opens a fresh database in a temporary working directory
+func newTestRepository(t *testing.T) testRepository {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	repo, err := repository.New(context.Background())
+	if err != nil {
+		t.Fatalf("repository.New: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return repo
+}
+
+func addCurrencies(t *testing.T, repo testRepository, codes ...string) {
+	t.Helper()
+	for _, code := range codes {
+		if _, err := repo.AddCurrency(context.Background(), models.Currency{Code: code, Name: code, Sign: code}); err != nil {
+			t.Fatalf("AddCurrency(%s): %v", code, err)
+		}
+	}
+}
+
+func addRate(t *testing.T, repo testRepository, pair string, rate float64) models.ExchangeRate {
+	t.Helper()
+	er, err := repo.AddExchangeRate(context.Background(), pair[:3], pair[3:], rate)
+	if err != nil {
+		t.Fatalf("AddExchangeRate(%s): %v", pair, err)
+	}
+	return er
+}
+
+// storedRate is the active rate of pair, ok is false when it has none
+func storedRate(t *testing.T, repo testRepository, pair string) (models.ExchangeRate, bool) {
+	t.Helper()
+	er, err := repo.GetExchangeRate(context.Background(), pair[:3], pair[3:])
+	if err != nil {
+		return models.ExchangeRate{}, false
+	}
+	return er, true
+}
+
+// recorder is a RateListener that keeps every change it is told about
+type recorder struct {
+	mu      sync.Mutex
+	changes []models.RateChange
+}
+
+func (r *recorder) RateChanged(ctx context.Context, change models.RateChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func (r *recorder) deleted() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pairs []string
+	for _, change := range r.changes {
+		if change.Deleted {
+			pairs = append(pairs, change.Current.BaseCurrency.Code+change.Current.TargetCurrency.Code)
+		}
+	}
+	return pairs
+}
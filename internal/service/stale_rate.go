@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"time"
+)
+
+var ErrExchangeRateStale = errors.New("exchange rate is stale")
+
+type StaleRateMode string
+
+const (
+	// StaleRateFail rejects conversions that need a stale rate
+	StaleRateFail StaleRateMode = "fail"
+	// StaleRateWarn performs the conversion and marks the result as stale
+	StaleRateWarn StaleRateMode = "warn"
+	// StaleRateFallback tries the next conversion route and fails only if every route is stale
+	StaleRateFallback StaleRateMode = "fallback"
+)
+
+type StaleRatePolicy struct {
+	// MaxAge applies to every pair without an override, zero disables the check
+	MaxAge time.Duration
+	// PairMaxAge overrides MaxAge for a pair, keyed by base and target codes, e.g. "USDRUB"
+	PairMaxAge map[string]time.Duration
+	Mode       StaleRateMode
+}
+
+func (p StaleRatePolicy) maxAge(baseCode, targetCode string) time.Duration {
+	if maxAge, ok := p.PairMaxAge[baseCode+targetCode]; ok {
+		return maxAge
+	}
+	return p.MaxAge
+}
+
+func (p StaleRatePolicy) isStale(rate models.ExchangeRate, now time.Time) bool {
+	maxAge := p.maxAge(rate.BaseCurrency.Code, rate.TargetCurrency.Code)
+	if maxAge <= 0 {
+		return false
+	}
+	return now.Sub(rate.UpdatedAt) > maxAge
+}
+
+func (p StaleRatePolicy) anyStale(rates []models.ExchangeRate, now time.Time) bool {
+	for _, rate := range rates {
+		if p.isStale(rate, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickRoute returns the index of the first of routes the policy allows, stale tells that its
+// rates are stale
+func (p StaleRatePolicy) pickRoute(routes []route, now time.Time) (chosen int, stale bool, err error) {
+	hasStaleRoute := false
+
+	for i, r := range routes {
+		if !p.anyStale(r.legs, now) {
+			return i, false, nil
+		}
+
+		switch p.Mode {
+		case StaleRateWarn:
+			return i, true, nil
+		case StaleRateFallback:
+			hasStaleRoute = true
+		default:
+			return 0, false, ErrExchangeRateStale
+		}
+	}
+
+	if hasStaleRoute {
+		return 0, false, ErrExchangeRateStale
+	}
+
+	return 0, false, repository.ErrExchangeRateNotFound
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"exchanger/internal/models"
+	"exchanger/internal/repository"
+	"testing"
+	"time"
+)
+
+func testLeg(pair string, age time.Duration, now time.Time) models.ExchangeRate {
+	return models.ExchangeRate{
+		BaseCurrency:   models.Currency{Code: pair[:3]},
+		TargetCurrency: models.Currency{Code: pair[3:]},
+		UpdatedAt:      now.Add(-age),
+	}
+}
+
+func TestStaleRatePolicyPickRoute(t *testing.T) {
+	now := time.Now()
+	fresh := route{legs: []models.ExchangeRate{testLeg("EURUSD", time.Minute, now)}}
+	stale := route{legs: []models.ExchangeRate{testLeg("EURUSD", 2*time.Hour, now)}}
+	// a cross route is stale when any of its legs is
+	staleCross := route{legs: []models.ExchangeRate{testLeg("EURUSD", time.Minute, now), testLeg("USDRUB", 2*time.Hour, now)}}
+
+	tests := []struct {
+		name    string
+		policy  StaleRatePolicy
+		routes  []route
+		chosen  int
+		stale   bool
+		wantErr error
+	}{
+		{"no max age", StaleRatePolicy{Mode: StaleRateFail}, []route{stale}, 0, false, nil},
+		{"fresh", StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateFail}, []route{fresh}, 0, false, nil},
+		{"fail", StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateFail}, []route{stale, fresh}, 0, false, ErrExchangeRateStale},
+		{"warn", StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateWarn}, []route{stale, fresh}, 0, true, nil},
+		{"fallback to a fresh route", StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateFallback}, []route{staleCross, fresh}, 1, false, nil},
+		{"fallback without a fresh route", StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateFallback}, []route{stale, staleCross}, 0, false, ErrExchangeRateStale},
+		{"pair override", StaleRatePolicy{MaxAge: time.Hour, PairMaxAge: map[string]time.Duration{"EURUSD": 3 * time.Hour}, Mode: StaleRateFail},
+			[]route{stale}, 0, false, nil},
+		{"pair override is stricter", StaleRatePolicy{MaxAge: time.Hour, PairMaxAge: map[string]time.Duration{"EURUSD": time.Second}, Mode: StaleRateFail},
+			[]route{fresh}, 0, false, ErrExchangeRateStale},
+		{"no route", StaleRatePolicy{MaxAge: time.Hour, Mode: StaleRateFallback}, nil, 0, false, repository.ErrExchangeRateNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chosen, stale, err := tt.policy.pickRoute(tt.routes, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (chosen != tt.chosen || stale != tt.stale) {
+				t.Errorf("pickRoute = %d, stale %v, want %d, stale %v", chosen, stale, tt.chosen, tt.stale)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists every rejected field of an input, Err is the error
+// callers match with errors.Is
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// errOrNil returns e when at least one field was rejected
+func (e *ValidationError) errOrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"exchanger/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAlertBackoff(t *testing.T) {
+	s := NewAlertService(nil, nil, nil, nil, AlertConfig{RetryBackoff: 10 * time.Second, MaxRetryBackoff: time.Minute})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{20, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := s.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// webhookReceiver checks the signature of every webhook like a subscriber would and answers
+// with the next of statuses, the last one is repeated
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	events   []models.AlertEvent
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	timestamp := r.Header.Get(webhookTimestampHeader)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		rcv.t.Errorf("invalid timestamp header %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(rcv.secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(webhookSignatureHeader) != want {
+		rcv.t.Errorf("signature = %q, want %q", r.Header.Get(webhookSignatureHeader), want)
+	}
+	if r.Header.Get(webhookDeliveryHeader) == "" {
+		rcv.t.Error("webhook has no delivery ID")
+	}
+
+	var event models.AlertEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		rcv.t.Errorf("webhook body: %v", err)
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.events = append(rcv.events, event)
+	status := rcv.statuses[0]
+	if len(rcv.statuses) > 1 {
+		rcv.statuses = rcv.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// triggerThreshold subscribes url to USDEUR crossing 1 and moves the rate across it
+func triggerThreshold(t *testing.T, repo testRepository, alerts *alertService, url string) {
+	t.Helper()
+	ctx := context.Background()
+	addCurrencies(t, repo, "USD", "EUR")
+	addRate(t, repo, "USDEUR", 0.9)
+
+	_, err := alerts.AddAlertSubscription(ctx, models.AlertSubscription{
+		BaseCurrencyCode: "USD", TargetCurrencyCode: "EUR", Condition: models.AlertThreshold, Level: 1, URL: url, Secret: "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("AddAlertSubscription: %v", err)
+	}
+
+	rates := NewExchangeRateService(repo, ApprovalPolicy{}, RateGuardPolicy{}, false, alerts)
+	if _, err := rates.UpdateExchangeRate(ctx, "USD", "EUR", 1.1, 0); err != nil {
+		t.Fatalf("UpdateExchangeRate: %v", err)
+	}
+}
+
+func TestWebhookIsSignedAndRetried(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	rcv := &webhookReceiver{t: t, secret: "s3cret", statuses: []int{http.StatusInternalServerError, http.StatusOK}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	alerts := NewAlertService(repo, repo, repo, repo, AlertConfig{MaxAttempts: 3, RetryBackoff: time.Millisecond, MaxRetryBackoff: time.Millisecond, Timeout: time.Second})
+	triggerThreshold(t, repo, alerts, srv.URL)
+
+	alerts.deliverDue(ctx)
+	pending, err := repo.GetAlertDeliveriesByStatus(ctx, models.AlertDeliveryPending)
+	if err != nil || len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Fatalf("after a failed attempt: pending = %+v, %v, want one delivery with an error", pending, err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	alerts.deliverDue(ctx)
+	delivered, err := repo.GetAlertDeliveriesByStatus(ctx, models.AlertDeliveryDelivered)
+	if err != nil || len(delivered) != 1 || delivered[0].Attempts != 2 {
+		t.Fatalf("after the retry: delivered = %+v, %v, want one delivery after 2 attempts", delivered, err)
+	}
+
+	if len(rcv.events) != 2 || rcv.events[1].Rate != 1.1 || rcv.events[1].ReferenceRate != 0.9 {
+		t.Errorf("events = %+v, want the same crossing twice", rcv.events)
+	}
+}
+
+func TestWebhookGoesToDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	rcv := &webhookReceiver{t: t, secret: "s3cret", statuses: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	alerts := NewAlertService(repo, repo, repo, repo, AlertConfig{MaxAttempts: 2, RetryBackoff: time.Millisecond, MaxRetryBackoff: time.Millisecond, Timeout: time.Second})
+	triggerThreshold(t, repo, alerts, srv.URL)
+
+	alerts.deliverDue(ctx)
+	time.Sleep(5 * time.Millisecond)
+	alerts.deliverDue(ctx)
+
+	dead, err := alerts.GetDeadLetters(ctx)
+	if err != nil || len(dead) != 1 || dead[0].Attempts != 2 {
+		t.Fatalf("dead letters = %+v, %v, want one delivery after 2 attempts", dead, err)
+	}
+
+	retried, err := alerts.RetryDeadLetter(ctx, dead[0].ID)
+	if err != nil {
+		t.Fatalf("RetryDeadLetter: %v", err)
+	}
+	if retried.Status != models.AlertDeliveryPending || retried.Attempts != 0 {
+		t.Errorf("retried delivery = %+v, want it pending with no attempts", retried)
+	}
+	if _, err := alerts.RetryDeadLetter(ctx, dead[0].ID); !errors.Is(err, ErrAlertDeliveryNotDead) {
+		t.Errorf("retry of a pending delivery: err = %v, want ErrAlertDeliveryNotDead", err)
+	}
+}